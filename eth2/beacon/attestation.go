@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/protolambda/zrnt/eth2/util/bls"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
 	. "github.com/protolambda/ztyp/view"
@@ -85,22 +86,54 @@ func (li Attestations) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
 	}, length, spec.MAX_ATTESTATIONS)
 }
 
+// ProcessAttestationsLimited processes at most limit attestations, and at most MAX_ATTESTATIONS,
+// and returns the remainder that was not processed. A negative limit processes as many as a block can contain.
+// On error, the attestations before the failing attestation stay applied,
+// and the remainder starts at the failing attestation.
+func (spec *Spec) ProcessAttestationsLimited(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Attestation, mode ValidationMode, limit int) ([]Attestation, error) {
+	n := limitOperations(len(ops), limit, spec.MAX_ATTESTATIONS)
+	done, err := spec.processAttestations(ctx, epc, state, ops[:n], mode)
+	if err != nil {
+		return ops[done:], err
+	}
+	return ops[n:], nil
+}
+
 func (spec *Spec) ProcessAttestations(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Attestation) error {
+	return spec.ProcessAttestationsWithMode(ctx, epc, state, ops, FullValidation)
+}
+
+// ProcessAttestationsWithMode is like ProcessAttestations, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessAttestationsWithMode(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Attestation, mode ValidationMode) error {
+	if err := checkOperationsLimit("attestations", len(ops), spec.MAX_ATTESTATIONS); err != nil {
+		return err
+	}
+	_, err := spec.processAttestations(ctx, epc, state, ops, mode)
+	return err
+}
+
+// processAttestations processes the attestations in order, and returns how many were processed before any error.
+func (spec *Spec) processAttestations(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Attestation, mode ValidationMode) (int, error) {
 	for i := range ops {
 		select {
 		case <-ctx.Done():
-			return TransitionCancelErr
+			return i, TransitionCancelErr
 		default: // Don't block.
 			break
 		}
-		if err := spec.ProcessAttestation(epc, state, &ops[i]); err != nil {
-			return err
+		if err := spec.ProcessAttestationWithMode(epc, state, &ops[i], mode); err != nil {
+			return i, err
 		}
 	}
-	return nil
+	return len(ops), nil
 }
 
 func (spec *Spec) ProcessAttestation(epc *EpochsContext, state *BeaconStateView, attestation *Attestation) error {
+	return spec.ProcessAttestationWithMode(epc, state, attestation, FullValidation)
+}
+
+// ProcessAttestationWithMode is like ProcessAttestation, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessAttestationWithMode(epc *EpochsContext, state *BeaconStateView, attestation *Attestation, mode ValidationMode) error {
 	data := &attestation.Data
 
 	// Check slot
@@ -164,13 +197,9 @@ func (spec *Spec) ProcessAttestation(epc *EpochsContext, state *BeaconStateView,
 	}
 
 	// Check signature and bitfields
-	committee, err := epc.GetBeaconCommittee(data.Slot, data.Index)
-	if err != nil {
-		return err
-	}
-	if indexedAtt, err := attestation.ConvertToIndexed(spec, committee); err != nil {
+	if indexedAtt, err := spec.GetIndexedAttestation(epc, attestation); err != nil {
 		return fmt.Errorf("attestation could not be converted to an indexed attestation: %v", err)
-	} else if err := spec.ValidateIndexedAttestation(epc, state, indexedAtt); err != nil {
+	} else if err := spec.validateIndexedAttestation(epc, state, indexedAtt, mode.verifySignatures()); err != nil {
 		return fmt.Errorf("attestation could not be verified in its indexed form: %v", err)
 	}
 
@@ -207,6 +236,16 @@ func (spec *Spec) ProcessAttestation(epc *EpochsContext, state *BeaconStateView,
 	return nil
 }
 
+// GetIndexedAttestation looks up the committee of the attestation, and converts the attestation to its indexed form,
+// with the sorted indices of the participants, like get_indexed_attestation in the spec.
+func (spec *Spec) GetIndexedAttestation(epc *EpochsContext, attestation *Attestation) (*IndexedAttestation, error) {
+	committee, err := epc.GetBeaconCommittee(attestation.Data.Slot, attestation.Data.Index)
+	if err != nil {
+		return nil, err
+	}
+	return attestation.ConvertToIndexed(spec, committee)
+}
+
 // Convert attestation to (almost) indexed-verifiable form
 func (attestation *Attestation) ConvertToIndexed(spec *Spec, committee []ValidatorIndex) (*IndexedAttestation, error) {
 	bitLen := attestation.AggregationBits.BitLen()
@@ -231,10 +270,63 @@ func (attestation *Attestation) ConvertToIndexed(spec *Spec, committee []Validat
 	}, nil
 }
 
+// AggregateAttestations combines attestations with the same data into a single attestation.
+// The attestations must not have any participants in common, the aggregate would not be valid.
+// The inputs are not modified.
+func AggregateAttestations(atts []*Attestation) (*Attestation, error) {
+	if len(atts) == 0 {
+		return nil, errors.New("no attestations to aggregate")
+	}
+	out := &Attestation{
+		AggregationBits: atts[0].AggregationBits.Copy(),
+		Data:            atts[0].Data,
+	}
+	signatures := make([]BLSSignature, 0, len(atts))
+	signatures = append(signatures, atts[0].Signature)
+	for i, att := range atts[1:] {
+		if att.Data != out.Data {
+			return nil, fmt.Errorf("attestation %d has different data", i+1)
+		}
+		if overlaps, err := out.AggregationBits.Overlaps(att.AggregationBits); err != nil {
+			return nil, fmt.Errorf("attestation %d cannot be aggregated: %v", i+1, err)
+		} else if overlaps {
+			return nil, fmt.Errorf("attestation %d has participants in common with previous attestations", i+1)
+		}
+		out.AggregationBits.Or(att.AggregationBits)
+		signatures = append(signatures, att.Signature)
+	}
+	sig, err := bls.AggregateSignatures(signatures)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate attestation signatures: %v", err)
+	}
+	out.Signature = sig
+	return out, nil
+}
+
+// GroupAttestationsByData groups attestations by the hash-tree-root of their data, for aggregation.
+// The root is computed once for each distinct data, attestations with equal data share the cached root.
+// Within a group, the attestations keep the order of the input.
+func GroupAttestationsByData(atts []*Attestation) map[Root][]*Attestation {
+	hFn := tree.GetHashFn()
+	dataRoots := make(map[AttestationData]Root)
+	out := make(map[Root][]*Attestation)
+	for _, att := range atts {
+		root, ok := dataRoots[att.Data]
+		if !ok {
+			root = att.Data.HashTreeRoot(hFn)
+			dataRoots[att.Data] = root
+		}
+		out[root] = append(out[root], att)
+	}
+	return out
+}
+
+// ComputeSubnetForAttestation returns the attestation subnet of a committee, like compute_subnet_for_attestation.
+// The committees per slot are those of the epoch of the slot, see EpochsContext.CommitteesPerSlot.
+// An error is returned if the committee index is not below the committees per slot.
 func (spec *Spec) ComputeSubnetForAttestation(committeesPerSlot uint64, slot Slot, committeeIndex CommitteeIndex) (uint64, error) {
-	maxCommitteeIndex := CommitteeIndex(committeesPerSlot * uint64(spec.SLOTS_PER_EPOCH))
-	if committeeIndex >= maxCommitteeIndex {
-		return 0, fmt.Errorf("committee index %d >= maximum %d", committeeIndex, maxCommitteeIndex)
+	if uint64(committeeIndex) >= committeesPerSlot {
+		return 0, fmt.Errorf("committee index %d >= committees per slot %d", committeeIndex, committeesPerSlot)
 	}
 	slotsSinceEpochStart := uint64(slot % spec.SLOTS_PER_EPOCH)
 	committeesSinceEpochStart := committeesPerSlot * slotsSinceEpochStart
@@ -0,0 +1,244 @@
+package beacon
+
+import (
+	"errors"
+	"fmt"
+	"github.com/protolambda/ztyp/tree"
+	"sort"
+)
+
+type packCandidate struct {
+	att       *Attestation
+	committee []ValidatorIndex
+	delay     Slot
+	// participants that are not covered yet, updated as attestations are selected.
+	newCount uint64
+}
+
+// checkPackable checks if the attestation can be included in a block on top of the state,
+// without checking the signature.
+func (spec *Spec) checkPackable(epc *EpochsContext, state *BeaconStateView, att *Attestation) ([]ValidatorIndex, error) {
+	data := &att.Data
+	currentSlot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	if !(currentSlot <= data.Slot+spec.SLOTS_PER_EPOCH) {
+		return nil, errors.New("attestation slot is too old")
+	}
+	if !(data.Slot+spec.MIN_ATTESTATION_INCLUSION_DELAY <= currentSlot) {
+		return nil, errors.New("attestation is too new")
+	}
+	currentEpoch := spec.SlotToEpoch(currentSlot)
+	if data.Target.Epoch < currentEpoch.Previous() || data.Target.Epoch > currentEpoch {
+		return nil, errors.New("attestation target is not the previous or current epoch")
+	}
+	if data.Target.Epoch != spec.SlotToEpoch(data.Slot) {
+		return nil, errors.New("attestation slot epoch does not match target epoch")
+	}
+	var justified *CheckpointView
+	if data.Target.Epoch == currentEpoch {
+		justified, err = state.CurrentJustifiedCheckpoint()
+	} else {
+		justified, err = state.PreviousJustifiedCheckpoint()
+	}
+	if err != nil {
+		return nil, err
+	}
+	source, err := justified.Raw()
+	if err != nil {
+		return nil, err
+	}
+	if data.Source != source {
+		return nil, errors.New("attestation source does not match justified checkpoint")
+	}
+	targetRoot, err := spec.GetBlockRoot(state, data.Target.Epoch)
+	if err != nil {
+		return nil, err
+	}
+	if data.Target.Root != targetRoot {
+		return nil, errors.New("attestation target root does not match the chain of the state")
+	}
+	committee, err := epc.GetBeaconCommittee(data.Slot, data.Index)
+	if err != nil {
+		return nil, err
+	}
+	if bitLen := att.AggregationBits.BitLen(); bitLen != uint64(len(committee)) {
+		return nil, fmt.Errorf("committee size does not match bits size: %d <> %d", len(committee), bitLen)
+	}
+	return committee, nil
+}
+
+// PackAttestations selects the attestations to include in a block on top of the given state,
+// up to maxCount, and no more than MAX_ATTESTATIONS.
+//
+// Attestations with the same data and no overlapping participants are aggregated.
+// Then the aggregates are selected one by one, preferring the most participants that are not covered
+// by the state or previously selected aggregates, and then the smallest inclusion delay.
+// Aggregates that add no participants are not included. The result is in order of selection.
+//
+// Attestations that cannot be included on top of the state are skipped. Signatures are not verified,
+// the pool is expected to only contain verified attestations.
+func (spec *Spec) PackAttestations(epc *EpochsContext, state *BeaconStateView, pool []*Attestation, maxCount uint64) ([]*Attestation, error) {
+	if maxCount > spec.MAX_ATTESTATIONS {
+		maxCount = spec.MAX_ATTESTATIONS
+	}
+	currentSlot, err := state.Slot()
+	if err != nil {
+		return nil, err
+	}
+	currentEpoch := spec.SlotToEpoch(currentSlot)
+
+	// Group the includable attestations by data, largest first, for better aggregation.
+	hFn := tree.GetHashFn()
+	groups := make(map[Root][]*Attestation)
+	committees := make(map[Root][]ValidatorIndex)
+	var dataRoots []Root
+	for _, att := range pool {
+		committee, err := spec.checkPackable(epc, state, att)
+		if err != nil {
+			continue
+		}
+		dataRoot := att.Data.HashTreeRoot(hFn)
+		if _, ok := groups[dataRoot]; !ok {
+			dataRoots = append(dataRoots, dataRoot)
+			committees[dataRoot] = committee
+		}
+		groups[dataRoot] = append(groups[dataRoot], att)
+	}
+
+	var candidates []*packCandidate
+	for _, dataRoot := range dataRoots {
+		atts := groups[dataRoot]
+		sort.SliceStable(atts, func(i, j int) bool {
+			return atts[i].AggregationBits.OnesCount() > atts[j].AggregationBits.OnesCount()
+		})
+		// The bits of each aggregate, and the attestations that make up the aggregate.
+		var aggregates []CommitteeBits
+		var members [][]*Attestation
+	attLoop:
+		for _, att := range atts {
+			for i, bits := range aggregates {
+				if covers, err := bits.Covers(att.AggregationBits); err != nil {
+					return nil, err
+				} else if covers {
+					continue attLoop
+				}
+				if overlaps, err := bits.Overlaps(att.AggregationBits); err != nil {
+					return nil, err
+				} else if !overlaps {
+					bits.Or(att.AggregationBits)
+					members[i] = append(members[i], att)
+					continue attLoop
+				}
+			}
+			aggregates = append(aggregates, att.AggregationBits.Copy())
+			members = append(members, []*Attestation{att})
+		}
+		for i := range aggregates {
+			agg := members[i][0]
+			if len(members[i]) > 1 {
+				agg, err = AggregateAttestations(members[i])
+				if err != nil {
+					return nil, err
+				}
+			}
+			candidates = append(candidates, &packCandidate{
+				att:       agg,
+				committee: committees[dataRoot],
+				delay:     currentSlot - agg.Data.Slot,
+			})
+		}
+	}
+
+	// Participants are rewarded once per target epoch, the state may already include some of them.
+	covered, err := spec.includedAttesters(epc, state, currentEpoch)
+	if err != nil {
+		return nil, err
+	}
+	participants := make([]ValidatorIndex, 0, spec.MAX_VALIDATORS_PER_COMMITTEE)
+	out := make([]*Attestation, 0, maxCount)
+	for uint64(len(out)) < maxCount {
+		var best *packCandidate
+		for _, c := range candidates {
+			set := covered[c.att.Data.Target.Epoch]
+			participants = c.att.AggregationBits.FilterParticipantsInto(participants, c.committee)
+			c.newCount = 0
+			for _, p := range participants {
+				if _, ok := set[p]; !ok {
+					c.newCount++
+				}
+			}
+			if c.newCount == 0 {
+				continue
+			}
+			if best == nil || c.newCount > best.newCount || (c.newCount == best.newCount && c.delay < best.delay) {
+				best = c
+			}
+		}
+		if best == nil {
+			break
+		}
+		out = append(out, best.att)
+		set := covered[best.att.Data.Target.Epoch]
+		participants = best.att.AggregationBits.FilterParticipantsInto(participants, best.committee)
+		for _, p := range participants {
+			set[p] = struct{}{}
+		}
+	}
+	return out, nil
+}
+
+// includedAttesters returns, for the previous and the current epoch, the set of validators
+// that are already included in the pending attestations of the state.
+func (spec *Spec) includedAttesters(epc *EpochsContext, state *BeaconStateView, currentEpoch Epoch) (map[Epoch]map[ValidatorIndex]struct{}, error) {
+	covered := map[Epoch]map[ValidatorIndex]struct{}{
+		currentEpoch.Previous(): make(map[ValidatorIndex]struct{}),
+		currentEpoch:            make(map[ValidatorIndex]struct{}),
+	}
+	prevAtts, err := state.PreviousEpochAttestations()
+	if err != nil {
+		return nil, err
+	}
+	currAtts, err := state.CurrentEpochAttestations()
+	if err != nil {
+		return nil, err
+	}
+	participants := make([]ValidatorIndex, 0, spec.MAX_VALIDATORS_PER_COMMITTEE)
+	for _, atts := range []*PendingAttestationsView{prevAtts, currAtts} {
+		attIter := atts.ReadonlyIter()
+		for {
+			el, ok, err := attIter.Next()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			att, err := AsPendingAttestation(el, nil)
+			if err != nil {
+				return nil, err
+			}
+			raw, err := att.Raw()
+			if err != nil {
+				return nil, err
+			}
+			set, ok := covered[raw.Data.Target.Epoch]
+			if !ok {
+				continue
+			}
+			committee, err := epc.GetBeaconCommittee(raw.Data.Slot, raw.Data.Index)
+			if err != nil {
+				return nil, err
+			}
+			if raw.AggregationBits.BitLen() != uint64(len(committee)) {
+				return nil, fmt.Errorf("pending attestation of slot %d does not match committee size", raw.Data.Slot)
+			}
+			participants = raw.AggregationBits.FilterParticipantsInto(participants, committee)
+			for _, p := range participants {
+				set[p] = struct{}{}
+			}
+		}
+	}
+	return covered, nil
+}
@@ -0,0 +1,174 @@
+// +build !bls_off
+
+package beacon_test
+
+import (
+	"context"
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestPackAttestations(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	if err := spec.ProcessSlots(context.Background(), epc, state, 3); err != nil {
+		t.Fatal(err)
+	}
+	justified, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := justified.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetRoot, err := spec.GetBlockRoot(state, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key hbls.SecretKey
+	key.SetByCSPRNG()
+	sigs := make(map[*beacon.Attestation]hbls.Sign)
+	att := func(slot beacon.Slot, bits ...uint64) *beacon.Attestation {
+		committee, err := epc.GetBeaconCommittee(slot, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := uint64(len(committee))
+		cb := make(beacon.CommitteeBits, n/8+1)
+		cb.SetBit(n, true) // delimiter bit
+		for _, b := range bits {
+			cb.SetBit(b, true)
+		}
+		a := &beacon.Attestation{
+			AggregationBits: cb,
+			Data: beacon.AttestationData{
+				Slot:   slot,
+				Source: source,
+				Target: beacon.Checkpoint{Epoch: 0, Root: targetRoot},
+			},
+		}
+		// Any valid point will do, the signatures are not verified.
+		sig := key.SignHash([]byte{byte(len(sigs))})
+		sigs[a] = *sig
+		copy(a.Signature[:], sig.Serialize())
+		return a
+	}
+	// Minimal config: committees of 4 validators.
+	a := att(1, 0, 1)
+	b := att(1, 1, 2) // overlaps with a
+	c := att(1, 3)    // aggregates with a
+	d := att(1, 1)    // covered by a
+	e := att(2, 0)    // smaller inclusion delay than b
+	invalid := att(1, 2, 3)
+	invalid.Data.Target.Root = beacon.Root{1}
+	pool := []*beacon.Attestation{a, invalid, d, c, e, b}
+
+	out, err := spec.PackAttestations(epc, state, pool, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 attestations, got %d", len(out))
+	}
+	if out[0].AggregationBits.OnesCount() != 3 || out[0].Data.Slot != 1 {
+		t.Fatalf("expected aggregate of a and c first, got %s at slot %d", out[0].AggregationBits, out[0].Data.Slot)
+	}
+	var expectedSig hbls.Sign
+	expectedSig.Aggregate([]hbls.Sign{sigs[a], sigs[c]})
+	var aggSig beacon.BLSSignature
+	copy(aggSig[:], expectedSig.Serialize())
+	if out[0].Signature != aggSig {
+		t.Fatal("unexpected aggregate signature")
+	}
+	if out[1] == nil || out[1].Data.Slot != 2 {
+		t.Fatal("expected attestation with smallest inclusion delay to be second")
+	}
+	if a.AggregationBits.OnesCount() != 2 {
+		t.Fatal("pool attestation was modified")
+	}
+
+	out, err = spec.PackAttestations(epc, state, pool, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 3 || out[2].Data.Slot != 1 || out[2].AggregationBits.OnesCount() != 2 {
+		t.Fatalf("expected the overlapping attestation last, got %d attestations", len(out))
+	}
+
+	// Once the state includes the only new participant of b, b is not worth including anymore.
+	pending := beacon.PendingAttestation{
+		AggregationBits: att(1, 2).AggregationBits,
+		Data:            b.Data,
+		InclusionDelay:  1,
+	}
+	currAtts, err := state.CurrentEpochAttestations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := currAtts.Append(pending.View(spec)); err != nil {
+		t.Fatal(err)
+	}
+	out, err = spec.PackAttestations(epc, state, pool, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 attestations, got %d", len(out))
+	}
+}
+
+func TestAggregateAttestations(t *testing.T) {
+	var key hbls.SecretKey
+	key.SetByCSPRNG()
+	data := beacon.AttestationData{Slot: 3, BeaconBlockRoot: beacon.Root{1}}
+	att := func(data beacon.AttestationData, bits ...uint64) *beacon.Attestation {
+		cb := make(beacon.CommitteeBits, 1)
+		cb.SetBit(4, true) // delimiter bit
+		for _, b := range bits {
+			cb.SetBit(b, true)
+		}
+		a := &beacon.Attestation{AggregationBits: cb, Data: data}
+		msg := []byte{byte(len(bits)), byte(bits[0])}
+		copy(a.Signature[:], key.SignByte(msg).Serialize())
+		return a
+	}
+	a, b := att(data, 0, 2), att(data, 3)
+	agg, err := beacon.AggregateAttestations([]*beacon.Attestation{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expected := range []bool{true, false, true, true} {
+		if agg.AggregationBits.GetBit(uint64(i)) != expected {
+			t.Fatalf("unexpected bit %d", i)
+		}
+	}
+	if a.AggregationBits.GetBit(3) {
+		t.Fatal("input attestation was modified")
+	}
+	var sigA, sigB, expected hbls.Sign
+	if err := sigA.Deserialize(a.Signature[:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := sigB.Deserialize(b.Signature[:]); err != nil {
+		t.Fatal(err)
+	}
+	expected.Aggregate([]hbls.Sign{sigA, sigB})
+	if string(agg.Signature[:]) != string(expected.Serialize()) {
+		t.Fatal("unexpected aggregate signature")
+	}
+
+	if _, err := beacon.AggregateAttestations([]*beacon.Attestation{a, att(data, 2)}); err == nil {
+		t.Fatal("expected error for overlapping participants")
+	}
+	otherData := data
+	otherData.Slot++
+	if _, err := beacon.AggregateAttestations([]*beacon.Attestation{a, att(otherData, 1)}); err == nil {
+		t.Fatal("expected error for different data")
+	}
+	if _, err := beacon.AggregateAttestations(nil); err == nil {
+		t.Fatal("expected error for no attestations")
+	}
+}
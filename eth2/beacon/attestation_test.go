@@ -0,0 +1,26 @@
+package beacon_test
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestGroupAttestationsByData(t *testing.T) {
+	dataA := beacon.AttestationData{Slot: 3, BeaconBlockRoot: beacon.Root{1}}
+	dataB := dataA
+	dataB.Index = 1
+	atts := []*beacon.Attestation{{Data: dataA}, {Data: dataB}, {Data: dataA}}
+	groups := beacon.GroupAttestationsByData(atts)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	groupA := groups[dataA.HashTreeRoot(tree.GetHashFn())]
+	if len(groupA) != 2 || groupA[0] != atts[0] || groupA[1] != atts[2] {
+		t.Fatal("unexpected group for data A")
+	}
+	groupB := groups[dataB.HashTreeRoot(tree.GetHashFn())]
+	if len(groupB) != 1 || groupB[0] != atts[1] {
+		t.Fatal("unexpected group for data B")
+	}
+}
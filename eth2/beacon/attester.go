@@ -1,6 +1,9 @@
 package beacon
 
-import "github.com/protolambda/ztyp/view"
+import (
+	"fmt"
+	"github.com/protolambda/ztyp/view"
+)
 
 type AttesterFlag uint8
 
@@ -34,6 +37,75 @@ func (v *FlatValidator) IsActive(epoch Epoch) bool {
 	return v.ActivationEpoch <= epoch && epoch < v.ExitEpoch
 }
 
+// ValidatorStatus is the status of a validator at an epoch, as defined in the beacon API.
+type ValidatorStatus uint8
+
+const (
+	// Deposited, but not yet eligible for activation.
+	ValidatorPendingInitialized ValidatorStatus = iota
+	// Eligible for activation, waiting in the activation queue.
+	ValidatorPendingQueued
+	ValidatorActiveOngoing
+	// Active, but the exit has been initiated.
+	ValidatorActiveExiting
+	// Active, but slashed and exiting.
+	ValidatorActiveSlashed
+	ValidatorExitedUnslashed
+	ValidatorExitedSlashed
+	ValidatorWithdrawalPossible
+	ValidatorWithdrawalDone
+)
+
+var validatorStatusNames = [...]string{
+	ValidatorPendingInitialized: "pending_initialized",
+	ValidatorPendingQueued:      "pending_queued",
+	ValidatorActiveOngoing:      "active_ongoing",
+	ValidatorActiveExiting:      "active_exiting",
+	ValidatorActiveSlashed:      "active_slashed",
+	ValidatorExitedUnslashed:    "exited_unslashed",
+	ValidatorExitedSlashed:      "exited_slashed",
+	ValidatorWithdrawalPossible: "withdrawal_possible",
+	ValidatorWithdrawalDone:     "withdrawal_done",
+}
+
+func (s ValidatorStatus) String() string {
+	if int(s) < len(validatorStatusNames) {
+		return validatorStatusNames[s]
+	}
+	return fmt.Sprintf("unknown_status_%d", uint8(s))
+}
+
+// Status classifies the validator at the given epoch.
+// The flat validator does not include the balance: a withdrawable validator is considered
+// to have withdrawn once its effective balance is zero.
+func (v *FlatValidator) Status(epoch Epoch) ValidatorStatus {
+	if epoch < v.ActivationEpoch {
+		if v.ActivationEligibilityEpoch == FAR_FUTURE_EPOCH {
+			return ValidatorPendingInitialized
+		}
+		return ValidatorPendingQueued
+	}
+	if epoch < v.ExitEpoch {
+		if v.Slashed {
+			return ValidatorActiveSlashed
+		}
+		if v.ExitEpoch != FAR_FUTURE_EPOCH {
+			return ValidatorActiveExiting
+		}
+		return ValidatorActiveOngoing
+	}
+	if epoch < v.WithdrawableEpoch {
+		if v.Slashed {
+			return ValidatorExitedSlashed
+		}
+		return ValidatorExitedUnslashed
+	}
+	if v.EffectiveBalance == 0 {
+		return ValidatorWithdrawalDone
+	}
+	return ValidatorWithdrawalPossible
+}
+
 func ToFlatValidator(v *ValidatorView) (*FlatValidator, error) {
 	/*
 	   pubkey: BLSPubkey
@@ -68,6 +140,44 @@ func ToFlatValidator(v *ValidatorView) (*FlatValidator, error) {
 	}, nil
 }
 
+// FlatValidators reads the whole registry once, into a flat slice, indexed by validator index.
+// Use it for many checks over the registry, e.g. IsActive for every validator,
+// instead of reading the fields from the tree for every check.
+//
+// The slice is a copy: it costs 48 bytes per validator (about 24 MB for 500,000 validators),
+// and does not reflect later changes to the state.
+func (state *BeaconStateView) FlatValidators() ([]FlatValidator, error) {
+	validators, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	count, err := validators.ValidatorCount()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FlatValidator, 0, count)
+	valIter := validators.ReadonlyIter()
+	for {
+		valContainer, ok, err := valIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		val, err := AsValidator(valContainer, nil)
+		if err != nil {
+			return nil, err
+		}
+		flat, err := ToFlatValidator(val)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *flat)
+	}
+	return out, nil
+}
+
 type AttesterStatus struct {
 	// The delay of inclusion of the latest attestation by the attester.
 	// No delay (i.e. 0) by default
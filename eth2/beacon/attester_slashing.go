@@ -7,9 +7,18 @@ import (
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
 	. "github.com/protolambda/ztyp/view"
+	"sort"
 )
 
 func (spec *Spec) ProcessAttesterSlashings(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []AttesterSlashing) error {
+	return spec.ProcessAttesterSlashingsWithMode(ctx, epc, state, ops, FullValidation)
+}
+
+// ProcessAttesterSlashingsWithMode is like ProcessAttesterSlashings, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessAttesterSlashingsWithMode(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []AttesterSlashing, mode ValidationMode) error {
+	if err := checkOperationsLimit("attester slashings", len(ops), spec.MAX_ATTESTER_SLASHINGS); err != nil {
+		return err
+	}
 	for i := range ops {
 		select {
 		case <-ctx.Done():
@@ -17,7 +26,7 @@ func (spec *Spec) ProcessAttesterSlashings(ctx context.Context, epc *EpochsConte
 		default: // Don't block.
 			break
 		}
-		if err := spec.ProcessAttesterSlashing(epc, state, &ops[i]); err != nil {
+		if err := spec.ProcessAttesterSlashingWithMode(epc, state, &ops[i], mode); err != nil {
 			return err
 		}
 	}
@@ -98,6 +107,11 @@ func (li AttesterSlashings) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
 }
 
 func (spec *Spec) ProcessAttesterSlashing(epc *EpochsContext, state *BeaconStateView, attesterSlashing *AttesterSlashing) error {
+	return spec.ProcessAttesterSlashingWithMode(epc, state, attesterSlashing, FullValidation)
+}
+
+// ProcessAttesterSlashingWithMode is like ProcessAttesterSlashing, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessAttesterSlashingWithMode(epc *EpochsContext, state *BeaconStateView, attesterSlashing *AttesterSlashing, mode ValidationMode) error {
 	sa1 := &attesterSlashing.Attestation1
 	sa2 := &attesterSlashing.Attestation2
 
@@ -105,17 +119,17 @@ func (spec *Spec) ProcessAttesterSlashing(epc *EpochsContext, state *BeaconState
 		return errors.New("attester slashing has no valid reasoning")
 	}
 
-	if err := spec.ValidateIndexedAttestation(epc, state, sa1); err != nil {
+	if err := spec.validateIndexedAttestation(epc, state, sa1, mode.verifySignatures()); err != nil {
 		return errors.New("attestation 1 of attester slashing cannot be verified")
 	}
-	if err := spec.ValidateIndexedAttestation(epc, state, sa2); err != nil {
+	if err := spec.validateIndexedAttestation(epc, state, sa2, mode.verifySignatures()); err != nil {
 		return errors.New("attestation 2 of attester slashing cannot be verified")
 	}
 
 	currentEpoch := epc.CurrentEpoch.Epoch
 
-	// keep track of effectiveness
-	slashedAny := false
+	// collect the validators to slash
+	var slashable []ValidatorIndex
 	var errorAny error
 
 	validators, err := state.Validators()
@@ -133,23 +147,27 @@ func (spec *Spec) ProcessAttesterSlashing(epc *EpochsContext, state *BeaconState
 			errorAny = err
 			return
 		}
-		if slashable, err := spec.IsSlashable(validator, currentEpoch); err != nil {
+		if ok, err := spec.IsSlashable(validator, currentEpoch); err != nil {
 			errorAny = err
-		} else if slashable {
-			if err := spec.SlashValidator(epc, state, i, nil); err != nil {
-				errorAny = err
-			} else {
-				slashedAny = true
-			}
+		} else if ok {
+			slashable = append(slashable, i)
 		}
 	}, nil)
 	if errorAny != nil {
 		return fmt.Errorf("error during attester-slashing validators slashable check: %v", errorAny)
 	}
-	if !slashedAny {
+	if len(slashable) == 0 {
 		return errors.New("attester slashing %d is not effective, hence invalid")
 	}
-	return nil
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	propIndex, err := epc.GetBeaconProposer(slot)
+	if err != nil {
+		return err
+	}
+	return spec.SlashValidators(epc, state, slashable, propIndex)
 }
 
 func IsSlashableAttestationData(a *AttestationData, b *AttestationData) bool {
@@ -165,3 +183,77 @@ func IsDoubleVote(a *AttestationData, b *AttestationData) bool {
 func IsSurroundVote(a *AttestationData, b *AttestationData) bool {
 	return a.Source.Epoch < b.Source.Epoch && a.Target.Epoch > b.Target.Epoch
 }
+
+// FindSlashableAttestationPairs finds all pairs of attestations that share an attester,
+// and are a double vote or a surround vote. Each pair is ordered such that
+// IsSlashableAttestationData(pair[0].Data, pair[1].Data) holds, like the attestations of an AttesterSlashing.
+// Attestations are grouped by attester, and sorted by target epoch, to stop comparing an attestation
+// once no later attestation in the group can be a double vote or surround vote with it.
+func FindSlashableAttestationPairs(atts []*IndexedAttestation) [][2]*IndexedAttestation {
+	byAttester := make(map[ValidatorIndex][]int)
+	for i, att := range atts {
+		for _, v := range att.AttestingIndices {
+			byAttester[v] = append(byAttester[v], i)
+		}
+	}
+	seen := make(map[[2]int]struct{})
+	var pairs [][2]int
+	var minSource []Epoch
+	for _, group := range byAttester {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool {
+			return atts[group[i]].Data.Target.Epoch < atts[group[j]].Data.Target.Epoch
+		})
+		// minSource[y] is the lowest source epoch of the attestations from y onwards.
+		minSource = append(minSource[:0], make([]Epoch, len(group))...)
+		minSource[len(group)-1] = atts[group[len(group)-1]].Data.Source.Epoch
+		for y := len(group) - 2; y >= 0; y-- {
+			minSource[y] = minSource[y+1]
+			if src := atts[group[y]].Data.Source.Epoch; src < minSource[y] {
+				minSource[y] = src
+			}
+		}
+		for x := 0; x < len(group); x++ {
+			a := &atts[group[x]].Data
+			for y := x + 1; y < len(group); y++ {
+				b := &atts[group[y]].Data
+				// Past the targets equal to that of a, only surround votes remain,
+				// and these need an earlier source than that of a.
+				if b.Target.Epoch > a.Target.Epoch && minSource[y] >= a.Source.Epoch {
+					break
+				}
+				// b has the same or a later target than a:
+				// the pair is either a double vote, or b may surround a.
+				var pair [2]int
+				if IsDoubleVote(a, b) {
+					pair = [2]int{group[x], group[y]}
+					if pair[0] > pair[1] {
+						pair[0], pair[1] = pair[1], pair[0]
+					}
+				} else if IsSurroundVote(b, a) {
+					pair = [2]int{group[y], group[x]}
+				} else {
+					continue
+				}
+				if _, ok := seen[pair]; ok {
+					continue
+				}
+				seen[pair] = struct{}{}
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	out := make([][2]*IndexedAttestation, len(pairs), len(pairs))
+	for i, p := range pairs {
+		out[i] = [2]*IndexedAttestation{atts[p[0]], atts[p[1]]}
+	}
+	return out
+}
@@ -72,30 +72,55 @@ func (v *RegistryBalancesView) DecreaseBalance(index ValidatorIndex, delta Gwei)
 	if err != nil {
 		return err
 	}
-	// prevent underflow, clip to 0
+	return v.SetBalance(index, decreaseClamped(bal, delta))
+}
+
+// decreaseClamped subtracts delta from the balance, clipped to 0 to prevent underflow.
+func decreaseClamped(bal Gwei, delta Gwei) Gwei {
 	if bal >= delta {
-		bal -= delta
-	} else {
-		bal = 0
+		return bal - delta
 	}
-	return v.SetBalance(index, bal)
+	return 0
+}
+
+// BalanceIter iterates over the balances of the registry, in validator index order.
+type BalanceIter struct {
+	iter  ElemIter
+	index ValidatorIndex
+}
+
+// Next returns the next validator index and balance, or ok=false when all balances were iterated.
+func (it *BalanceIter) Next() (index ValidatorIndex, balance Gwei, ok bool, err error) {
+	el, ok, err := it.iter.Next()
+	if err != nil || !ok {
+		return 0, 0, ok, err
+	}
+	balance, err = AsGwei(el, nil)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	index = it.index
+	it.index++
+	return index, balance, true, nil
+}
+
+// IterBalances returns a readonly iterator over the balances, reading them sequentially from the tree backing,
+// which is much faster than a GetBalance call per validator.
+func (v *RegistryBalancesView) IterBalances() *BalanceIter {
+	return &BalanceIter{iter: v.ReadonlyIter()}
 }
 
 func (v *RegistryBalancesView) AllBalances() ([]Gwei, error) {
 	var out []Gwei
-	balIter := v.ReadonlyIter()
-	for i := ValidatorIndex(0); true; i++ {
-		el, ok, err := balIter.Next()
+	balIter := v.IterBalances()
+	for {
+		_, balance, ok, err := balIter.Next()
 		if err != nil {
 			return nil, err
 		}
 		if !ok {
 			break
 		}
-		balance, err := AsGwei(el, nil)
-		if err != nil {
-			return nil, err
-		}
 		out = append(out, balance)
 	}
 	return out, nil
@@ -24,6 +24,12 @@ func (b *SignedBeaconBlock) ByteLength(spec *Spec) uint64 {
 	return codec.ContainerLength(spec.Wrap(&b.Message), &b.Signature)
 }
 
+// EstimateBlockSize returns the exact SSZ byte length of the signed block, including the offsets
+// of the variable-length fields, without serializing it.
+func (spec *Spec) EstimateBlockSize(block *SignedBeaconBlock) uint64 {
+	return block.ByteLength(spec)
+}
+
 func (a *SignedBeaconBlock) FixedLength(*Spec) uint64 {
 	return 0
 }
@@ -146,21 +152,51 @@ func (b *BeaconBlockBody) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
 	)
 }
 
+// TooManyOperationsError is returned when a list of block operations is longer than the block body allows.
+type TooManyOperationsError struct {
+	Operation string
+	Count     uint64
+	Limit     uint64
+}
+
+func (err *TooManyOperationsError) Error() string {
+	return fmt.Sprintf("too many %s: %d, limit is %d", err.Operation, err.Count, err.Limit)
+}
+
+func checkOperationsLimit(operation string, count int, limit uint64) error {
+	if x := uint64(count); x > limit {
+		return &TooManyOperationsError{Operation: operation, Count: x, Limit: limit}
+	}
+	return nil
+}
+
+// limitOperations returns how many of count operations to process, at most limit, and at most max.
+// A negative limit only limits the count to max.
+func limitOperations(count int, limit int, max uint64) int {
+	if limit >= 0 && count > limit {
+		count = limit
+	}
+	if uint64(count) > max {
+		count = int(max)
+	}
+	return count
+}
+
 func (b BeaconBlockBody) CheckLimits(spec *Spec) error {
-	if x := uint64(len(b.ProposerSlashings)); x > spec.MAX_PROPOSER_SLASHINGS {
-		return fmt.Errorf("too many proposer slashings: %d", x)
+	if err := checkOperationsLimit("proposer slashings", len(b.ProposerSlashings), spec.MAX_PROPOSER_SLASHINGS); err != nil {
+		return err
 	}
-	if x := uint64(len(b.AttesterSlashings)); x > spec.MAX_ATTESTER_SLASHINGS {
-		return fmt.Errorf("too many attester slashings: %d", x)
+	if err := checkOperationsLimit("attester slashings", len(b.AttesterSlashings), spec.MAX_ATTESTER_SLASHINGS); err != nil {
+		return err
 	}
-	if x := uint64(len(b.Attestations)); x > spec.MAX_ATTESTATIONS {
-		return fmt.Errorf("too many attestations: %d", x)
+	if err := checkOperationsLimit("attestations", len(b.Attestations), spec.MAX_ATTESTATIONS); err != nil {
+		return err
 	}
-	if x := uint64(len(b.Deposits)); x > spec.MAX_DEPOSITS {
-		return fmt.Errorf("too many deposits: %d", x)
+	if err := checkOperationsLimit("deposits", len(b.Deposits), spec.MAX_DEPOSITS); err != nil {
+		return err
 	}
-	if x := uint64(len(b.VoluntaryExits)); x > spec.MAX_VOLUNTARY_EXITS {
-		return fmt.Errorf("too many voluntary exits: %d", x)
+	if err := checkOperationsLimit("voluntary exits", len(b.VoluntaryExits), spec.MAX_VOLUNTARY_EXITS); err != nil {
+		return err
 	}
 	return nil
 }
@@ -178,3 +214,80 @@ func (c *Phase0Config) BeaconBlockBody() *ContainerTypeDef {
 		{"voluntary_exits", c.BlockVoluntaryExits()},
 	})
 }
+
+// BodyField selects a variable-size field of the block body, and the destination to decode it into.
+type BodyField struct {
+	index uint64
+	dst   codec.Deserializable
+}
+
+func (spec *Spec) BodyProposerSlashingsField(dst *ProposerSlashings) BodyField {
+	return BodyField{index: 0, dst: spec.Wrap(dst)}
+}
+
+func (spec *Spec) BodyAttesterSlashingsField(dst *AttesterSlashings) BodyField {
+	return BodyField{index: 1, dst: spec.Wrap(dst)}
+}
+
+func (spec *Spec) BodyAttestationsField(dst *Attestations) BodyField {
+	return BodyField{index: 2, dst: spec.Wrap(dst)}
+}
+
+func (spec *Spec) BodyDepositsField(dst *Deposits) BodyField {
+	return BodyField{index: 3, dst: spec.Wrap(dst)}
+}
+
+func (spec *Spec) BodyVoluntaryExitsField(dst *VoluntaryExits) BodyField {
+	return BodyField{index: 4, dst: spec.Wrap(dst)}
+}
+
+// randao_reveal, eth1_data and graffiti, followed by the offsets of the 5 operation lists.
+var blockBodyFixedSize = BLSSignatureType.TypeByteLength() + Eth1DataType.TypeByteLength() + 32
+var blockBodyOffsetsEnd = blockBodyFixedSize + 5*4
+
+// PeekBlockBodyField decodes a single variable-size field of an SSZ encoded block body,
+// skipping over the other fields with the offsets, without decoding them.
+// The reader must be scoped to the block body.
+func PeekBlockBodyField(dr *codec.DecodingReader, field BodyField) error {
+	scope := dr.Scope()
+	if _, err := dr.Skip(blockBodyFixedSize); err != nil {
+		return fmt.Errorf("failed to skip fixed-size block body fields: %v", err)
+	}
+	var offsets [5]uint64
+	for i := range offsets {
+		off, err := dr.ReadOffset()
+		if err != nil {
+			return fmt.Errorf("failed to read offset for block body field %d: %v", i, err)
+		}
+		offsets[i] = uint64(off)
+	}
+	if offsets[0] != blockBodyOffsetsEnd {
+		return fmt.Errorf("offset 0 is incorrect, expected %d, got %d", blockBodyOffsetsEnd, offsets[0])
+	}
+	prev := offsets[0]
+	for i, off := range offsets {
+		if off < prev {
+			return fmt.Errorf("scope cannot be negative, got offset %d after %d, at index %d", off, prev, i)
+		}
+		prev = off
+	}
+	if prev > scope {
+		return fmt.Errorf("offset %d is beyond the block body scope %d", prev, scope)
+	}
+	start := offsets[field.index]
+	end := scope
+	if field.index+1 < uint64(len(offsets)) {
+		end = offsets[field.index+1]
+	}
+	if _, err := dr.Skip(start - blockBodyOffsetsEnd); err != nil {
+		return fmt.Errorf("failed to skip to block body field %d: %v", field.index, err)
+	}
+	sub, err := dr.SubScope(end - start)
+	if err != nil {
+		return err
+	}
+	if err := field.dst.Deserialize(sub); err != nil {
+		return fmt.Errorf("failed to deserialize block body field %d: %v", field.index, err)
+	}
+	return nil
+}
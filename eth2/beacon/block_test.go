@@ -0,0 +1,99 @@
+package beacon_test
+
+import (
+	"bytes"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestPeekBlockBodyField(t *testing.T) {
+	spec := configs.Minimal
+	body := beacon.BeaconBlockBody{
+		Graffiti: beacon.Root{1},
+		Attestations: beacon.Attestations{
+			{AggregationBits: beacon.CommitteeBits{0x03}, Data: beacon.AttestationData{Slot: 3, Index: 1}},
+			{AggregationBits: beacon.CommitteeBits{0x05, 0x01}, Data: beacon.AttestationData{Slot: 4}},
+		},
+		Deposits: beacon.Deposits{
+			{Data: beacon.DepositData{Amount: 32}},
+		},
+		VoluntaryExits: beacon.VoluntaryExits{
+			{Message: beacon.VoluntaryExit{Epoch: 2, ValidatorIndex: 7}},
+		},
+	}
+	var buf bytes.Buffer
+	if err := body.Serialize(spec, codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	peek := func(field beacon.BodyField) {
+		if err := beacon.PeekBlockBodyField(codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data))), field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hFn := tree.GetHashFn()
+
+	var atts beacon.Attestations
+	peek(spec.BodyAttestationsField(&atts))
+	if atts.HashTreeRoot(spec, hFn) != body.Attestations.HashTreeRoot(spec, hFn) {
+		t.Fatal("peeked attestations do not match")
+	}
+	var deps beacon.Deposits
+	peek(spec.BodyDepositsField(&deps))
+	if deps.HashTreeRoot(spec, hFn) != body.Deposits.HashTreeRoot(spec, hFn) {
+		t.Fatal("peeked deposits do not match")
+	}
+	// last field, ends with the scope
+	var exits beacon.VoluntaryExits
+	peek(spec.BodyVoluntaryExitsField(&exits))
+	if exits.HashTreeRoot(spec, hFn) != body.VoluntaryExits.HashTreeRoot(spec, hFn) {
+		t.Fatal("peeked voluntary exits do not match")
+	}
+	var slashings beacon.ProposerSlashings
+	peek(spec.BodyProposerSlashingsField(&slashings))
+	if len(slashings) != 0 {
+		t.Fatal("expected no proposer slashings")
+	}
+
+	// a truncated body has offsets beyond the scope
+	truncated := data[:len(data)-1]
+	dr := codec.NewDecodingReader(bytes.NewReader(truncated), uint64(len(truncated)))
+	if err := beacon.PeekBlockBodyField(dr, spec.BodyVoluntaryExitsField(&exits)); err == nil {
+		t.Fatal("expected error for truncated block body")
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	spec := configs.Minimal
+	block := beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: 3, Body: beacon.BeaconBlockBody{
+		Attestations: beacon.Attestations{
+			{AggregationBits: beacon.CommitteeBits{0x05, 0x01}, Data: beacon.AttestationData{Slot: 2}},
+		},
+		VoluntaryExits: beacon.VoluntaryExits{
+			{Message: beacon.VoluntaryExit{Epoch: 2, ValidatorIndex: 7}},
+		},
+	}}}
+	var buf bytes.Buffer
+	if err := block.Serialize(spec, codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	if size := spec.EstimateBlockSize(&block); size != uint64(buf.Len()) {
+		t.Fatalf("estimated block size %d, serialized size %d", size, buf.Len())
+	}
+
+	state, _ := testState(t, spec)
+	buf.Reset()
+	if err := state.Serialize(codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	size, err := spec.EstimateStateSize(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(buf.Len()) {
+		t.Fatalf("estimated state size %d, serialized size %d", size, buf.Len())
+	}
+}
@@ -104,6 +104,30 @@ func ComputeSigningRoot(msgRoot Root, dom BLSDomain) Root {
 	return withDomain.HashTreeRoot(tree.GetHashFn())
 }
 
+// HashTreeRootWithSpec is implemented by objects that need the spec to compute their hash-tree-root.
+type HashTreeRootWithSpec interface {
+	HashTreeRoot(spec *Spec, hFn tree.HashFn) Root
+}
+
+// SigningRoot computes the signing root of the object, with the domain of the given type and epoch, as found in the state.
+func (spec *Spec) SigningRoot(state *BeaconStateView, obj HashTreeRootWithSpec, domainType BLSDomainType, epoch Epoch) (Root, error) {
+	dom, err := state.GetDomain(domainType, epoch)
+	if err != nil {
+		return Root{}, err
+	}
+	return ComputeSigningRoot(obj.HashTreeRoot(spec, tree.GetHashFn()), dom), nil
+}
+
+// SigningRoot computes the signing root of an object that does not depend on the spec,
+// with the domain of the given type and epoch, as found in the state.
+func SigningRoot(state *BeaconStateView, obj tree.HTR, domainType BLSDomainType, epoch Epoch) (Root, error) {
+	dom, err := state.GetDomain(domainType, epoch)
+	if err != nil {
+		return Root{}, err
+	}
+	return ComputeSigningRoot(obj.HashTreeRoot(tree.GetHashFn()), dom), nil
+}
+
 // For pubkeys/signatures in state, a tree-representation is used. (TODO: cache optimized deserialized/parsed bls points)
 
 type BLSPubkeyView struct {
@@ -108,6 +108,25 @@ func (cb CommitteeBits) FilterParticipants(committee []ValidatorIndex) []Validat
 	return out
 }
 
+// Filters a list of committee indices to only keep the bitfield participants,
+// writing them into dst (re-sliced from the start) instead of modifying the committee.
+// The result is not sorted. Returns the re-sliced dst, which only allocates if dst is too small.
+//
+// WARNING: unsafe to use, panics if committee size does not match.
+func (cb CommitteeBits) FilterParticipantsInto(dst []ValidatorIndex, committee []ValidatorIndex) []ValidatorIndex {
+	bitLen := cb.BitLen()
+	out := dst[:0]
+	if bitLen != uint64(len(committee)) {
+		panic("committee mismatch, bitfield length does not match")
+	}
+	for i := uint64(0); i < bitLen; i++ {
+		if cb.GetBit(i) {
+			out = append(out, committee[i])
+		}
+	}
+	return out
+}
+
 // In-place filters a list of committees indices to only keep the bitfield NON-participants.
 // The result is not sorted. Returns the re-sliced filtered non-participants list.
 //
@@ -126,6 +145,22 @@ func (cb CommitteeBits) FilterNonParticipants(committee []ValidatorIndex) []Vali
 	return out
 }
 
+// Returns true if any bit is set to 1 in both this bitfield and other
+func (cb CommitteeBits) Overlaps(other CommitteeBits) (bool, error) {
+	if a, b := cb.BitLen(), other.BitLen(); a != b {
+		return false, fmt.Errorf("bitfield length mismatch: %d <> %d", a, b)
+	}
+	last := len(cb) - 1
+	for i := 0; i < last; i++ {
+		if cb[i]&other[i] != 0 {
+			return true, nil
+		}
+	}
+	// both bitfields have the same delimiter bit set, exclude it from the last byte.
+	delimiter := byte(1) << (cb.BitLen() % 8)
+	return (cb[last]&other[last])&^delimiter != 0, nil
+}
+
 // Returns true if other only has bits set to 1 that this bitfield also has set to 1
 func (cb CommitteeBits) Covers(other CommitteeBits) (bool, error) {
 	if a, b := cb.BitLen(), other.BitLen(); a != b {
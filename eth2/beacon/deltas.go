@@ -3,6 +3,7 @@ package beacon
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/protolambda/zrnt/eth2/util/math"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
@@ -99,6 +100,46 @@ func NewRewardsAndPenalties(validatorCount uint64) *RewardsAndPenalties {
 	}
 }
 
+// DeltaComponent is the reward and penalty of a single validator, for one component of the rewards and penalties.
+type DeltaComponent struct {
+	Reward  Gwei `json:"reward" yaml:"reward"`
+	Penalty Gwei `json:"penalty" yaml:"penalty"`
+}
+
+// ValidatorRewardBreakdown is the rewards and penalties of a single validator, per component.
+type ValidatorRewardBreakdown struct {
+	Source         DeltaComponent `json:"source" yaml:"source"`
+	Target         DeltaComponent `json:"target" yaml:"target"`
+	Head           DeltaComponent `json:"head" yaml:"head"`
+	InclusionDelay DeltaComponent `json:"inclusion_delay" yaml:"inclusion_delay"`
+	Inactivity     DeltaComponent `json:"inactivity" yaml:"inactivity"`
+	// Sum of all rewards minus all penalties, may be negative.
+	Net int64 `json:"net" yaml:"net"`
+}
+
+// ForValidator collects the rewards and penalties of the given validator from each of the components.
+func (rp *RewardsAndPenalties) ForValidator(i ValidatorIndex) (out ValidatorRewardBreakdown, err error) {
+	components := [...]struct {
+		deltas *Deltas
+		out    *DeltaComponent
+	}{
+		{rp.Source, &out.Source},
+		{rp.Target, &out.Target},
+		{rp.Head, &out.Head},
+		{rp.InclusionDelay, &out.InclusionDelay},
+		{rp.Inactivity, &out.Inactivity},
+	}
+	for _, c := range components {
+		if uint64(i) >= uint64(len(c.deltas.Rewards)) || uint64(i) >= uint64(len(c.deltas.Penalties)) {
+			return ValidatorRewardBreakdown{}, fmt.Errorf("validator index %d out of range for rewards and penalties", i)
+		}
+		c.out.Reward = c.deltas.Rewards[i]
+		c.out.Penalty = c.deltas.Penalties[i]
+		out.Net += int64(c.out.Reward) - int64(c.out.Penalty)
+	}
+	return out, nil
+}
+
 func (spec *Spec) AttestationRewardsAndPenalties(ctx context.Context,
 	epc *EpochsContext, process *EpochProcess, state *BeaconStateView) (*RewardsAndPenalties, error) {
 
@@ -253,28 +294,17 @@ func (spec *Spec) ProcessEpochRewardsAndPenalties(ctx context.Context, epc *Epoc
 		return errors.New("cannot apply deltas to balances list with different length")
 	}
 	balancesElements := make([]BasicView, 0, balLen)
-	balIter := balances.ReadonlyIter()
-	i := ValidatorIndex(0)
+	balIter := balances.IterBalances()
 	for {
-		el, ok, err := balIter.Next()
+		i, bal, ok, err := balIter.Next()
 		if err != nil {
 			return err
 		}
 		if !ok {
 			break
 		}
-		bal, err := AsGwei(el, err)
-		if err != nil {
-			return err
-		}
-		bal += sum.Rewards[i]
-		if penalty := sum.Penalties[i]; bal >= penalty {
-			bal -= penalty
-		} else {
-			bal = 0
-		}
+		bal = decreaseClamped(bal+sum.Rewards[i], sum.Penalties[i])
 		balancesElements = append(balancesElements, Uint64View(bal))
-		i++
 	}
 
 	newBalancesTree, err := spec.RegistryBalances().FromElements(balancesElements...)
@@ -172,6 +172,9 @@ func (li Deposits) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
 
 // Verify that outstanding deposits are processed up to the maximum number of deposits, then process all in order.
 func (spec *Spec) ProcessDeposits(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Deposit) error {
+	if err := checkOperationsLimit("deposits", len(ops), spec.MAX_DEPOSITS); err != nil {
+		return err
+	}
 	inputCount := uint64(len(ops))
 	eth1Data, err := state.Eth1Data()
 	if err != nil {
@@ -185,7 +188,9 @@ func (spec *Spec) ProcessDeposits(ctx context.Context, epc *EpochsContext, state
 	if err != nil {
 		return err
 	}
-	// state deposit count and deposit index are trusted not to underflow
+	if depIndex > depCount {
+		return fmt.Errorf("deposit index %d exceeds deposit count %d", depIndex, depCount)
+	}
 	expectedInputCount := uint64(depCount - depIndex)
 	if expectedInputCount > spec.MAX_DEPOSITS {
 		expectedInputCount = spec.MAX_DEPOSITS
@@ -193,44 +198,147 @@ func (spec *Spec) ProcessDeposits(ctx context.Context, epc *EpochsContext, state
 	if inputCount != expectedInputCount {
 		return errors.New("block does not contain expected deposits amount")
 	}
+	_, err = spec.processDeposits(ctx, epc, state, ops)
+	return err
+}
+
+// ProcessDepositsLimited processes at most limit deposits, at most MAX_DEPOSITS, and no more than
+// the outstanding deposits, and returns the remainder that was not processed.
+// A negative limit processes as many as a block can contain.
+// Unlike ProcessDeposits, it does not require all outstanding deposits (up to MAX_DEPOSITS) to be processed.
+// On error, the deposits before the failing deposit stay applied, and the remainder starts at the failing deposit.
+func (spec *Spec) ProcessDepositsLimited(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Deposit, limit int) ([]Deposit, error) {
+	eth1Data, err := state.Eth1Data()
+	if err != nil {
+		return ops, err
+	}
+	depCount, err := eth1Data.DepositCount()
+	if err != nil {
+		return ops, err
+	}
+	depIndex, err := state.DepositIndex()
+	if err != nil {
+		return ops, err
+	}
+	if depIndex > depCount {
+		return ops, fmt.Errorf("deposit index %d exceeds deposit count %d", depIndex, depCount)
+	}
+	n := limitOperations(len(ops), limit, spec.MAX_DEPOSITS)
+	if outstanding := uint64(depCount - depIndex); uint64(n) > outstanding {
+		n = int(outstanding)
+	}
+	done, err := spec.processDeposits(ctx, epc, state, ops[:n])
+	if err != nil {
+		return ops[done:], err
+	}
+	return ops[n:], nil
+}
 
+// processDeposits processes the deposits in order, and returns how many were processed before any error.
+func (spec *Spec) processDeposits(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []Deposit) (int, error) {
 	for i := range ops {
 		select {
 		case <-ctx.Done():
-			return TransitionCancelErr
+			return i, TransitionCancelErr
 		default: // Don't block.
 			break
 		}
-		if err := spec.ProcessDeposit(epc, state, &ops[i], false); err != nil {
-			return err
+		if _, _, err := spec.ProcessDeposit(epc, state, &ops[i], false); err != nil {
+			return i, err
 		}
 	}
-	return nil
+	return len(ops), nil
 }
 
 // Process an Eth1 deposit, registering a validator or increasing its balance.
-func (spec *Spec) ProcessDeposit(epc *EpochsContext, state *BeaconStateView, dep *Deposit, ignoreSignatureAndProof bool) error {
+// It returns the index of the validator that was created or topped up, and whether it is a new validator.
+// A new validator with an invalid signature is skipped: isNew is false,
+// and the index is the validator count, i.e. outside of the registry.
+func (spec *Spec) ProcessDeposit(epc *EpochsContext, state *BeaconStateView, dep *Deposit, ignoreSignatureAndProof bool) (index ValidatorIndex, isNew bool, err error) {
+	verifySig := spec.VerifyDepositSignature
+	if ignoreSignatureAndProof {
+		verifySig = nil
+	}
+	return spec.processDeposit(epc, state, dep, !ignoreSignatureAndProof, verifySig)
+}
+
+// VerifyDepositSignature verifies the proof of possession of a deposit.
+func (spec *Spec) VerifyDepositSignature(dep *Deposit) bool {
+	return bls.Verify(
+		&CachedPubkey{Compressed: dep.Data.Pubkey},
+		ComputeSigningRoot(
+			dep.Data.MessageRoot(),
+			// Fork-agnostic domain since deposits are valid across forks
+			ComputeDomain(spec.DOMAIN_DEPOSIT, spec.GENESIS_FORK_VERSION, Root{})),
+		dep.Data.Signature)
+}
+
+// VerifyDepositSignatures verifies the proofs of possession of the given deposits as a batch,
+// and returns the validity of each deposit signature.
+// If the batch is invalid, it is split in halves to find the invalid deposits.
+func (spec *Spec) VerifyDepositSignatures(deps []Deposit) []bool {
+	dom := ComputeDomain(spec.DOMAIN_DEPOSIT, spec.GENESIS_FORK_VERSION, Root{})
+	pubkeys := make([][]*CachedPubkey, len(deps), len(deps))
+	messages := make([][32]byte, len(deps), len(deps))
+	signatures := make([]BLSSignature, len(deps), len(deps))
+	for i := range deps {
+		pubkeys[i] = []*CachedPubkey{{Compressed: deps[i].Data.Pubkey}}
+		messages[i] = ComputeSigningRoot(deps[i].Data.MessageRoot(), dom)
+		signatures[i] = deps[i].Data.Signature
+	}
+	out := make([]bool, len(deps), len(deps))
+	var verifyRange func(start, end int)
+	verifyRange = func(start, end int) {
+		if start == end {
+			return
+		}
+		if bls.BatchFastAggregateVerify(pubkeys[start:end], messages[start:end], signatures[start:end]) {
+			for i := start; i < end; i++ {
+				out[i] = true
+			}
+			return
+		}
+		if end-start == 1 {
+			return
+		}
+		mid := (start + end) / 2
+		verifyRange(start, mid)
+		verifyRange(mid, end)
+	}
+	verifyRange(0, len(deps))
+	return out
+}
+
+// VerifyDepositProof checks the merkle branch of the deposit at the given index against the deposit root,
+// which has the deposit count mixed in, like the Eth1Data.DepositRoot and DepositTree.Root.
+func VerifyDepositProof(dep *Deposit, depositIndex uint64, root Root) bool {
+	return merkle.VerifyMerkleBranch(
+		dep.Data.HashTreeRoot(tree.GetHashFn()),
+		dep.Proof[:],
+		DEPOSIT_CONTRACT_TREE_DEPTH+1, // Add 1 for the `List` length mix-in
+		depositIndex,
+		root)
+}
+
+// processDeposit processes a deposit, optionally verifying the merkle proof.
+// The signature is only checked for new validators, and only if verifySig is not nil.
+func (spec *Spec) processDeposit(epc *EpochsContext, state *BeaconStateView, dep *Deposit, verifyProof bool, verifySig func(dep *Deposit) bool) (index ValidatorIndex, isNew bool, err error) {
 	depositIndex, err := state.DepositIndex()
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 	eth1Data, err := state.Eth1Data()
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 	depositsRoot, err := eth1Data.DepositRoot()
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 
 	// Verify the Merkle branch
-	if !ignoreSignatureAndProof && !merkle.VerifyMerkleBranch(
-		dep.Data.HashTreeRoot(tree.GetHashFn()),
-		dep.Proof[:],
-		DEPOSIT_CONTRACT_TREE_DEPTH+1, // Add 1 for the `List` length mix-in
-		uint64(depositIndex),
-		depositsRoot) {
-		return fmt.Errorf("deposit %d merkle proof failed to be verified", depositIndex)
+	if verifyProof && !VerifyDepositProof(dep, uint64(depositIndex), depositsRoot) {
+		return 0, false, fmt.Errorf("deposit %d merkle proof failed to be verified", depositIndex)
 	}
 
 	// Increment the next deposit index we are expecting. Note that this
@@ -238,17 +346,17 @@ func (spec *Spec) ProcessDeposit(epc *EpochsContext, state *BeaconStateView, dep
 	// create an invalid Merkle branch, it may admit an invalid deposit
 	// object, and we need to be able to skip over it
 	if err := state.IncrementDepositIndex(); err != nil {
-		return err
+		return 0, false, err
 	}
 
 	validators, err := state.Validators()
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 
 	valCount, err := validators.Length()
 	if err != nil {
-		return err
+		return 0, false, err
 	}
 	valIndex, ok := epc.PubkeyCache.ValidatorIndex(dep.Data.Pubkey)
 	// it exists if: it exists in the pubkey cache AND the validator index is lower than the current validator count.
@@ -260,17 +368,11 @@ func (spec *Spec) ProcessDeposit(epc *EpochsContext, state *BeaconStateView, dep
 	// Check if it is a known validator that is depositing ("if pubkey not in validator_pubkeys")
 	if !exists {
 		// Verify the deposit signature (proof of possession) which is not checked by the deposit contract
-		if !ignoreSignatureAndProof && !bls.Verify(
-			&CachedPubkey{Compressed: dep.Data.Pubkey},
-			ComputeSigningRoot(
-				dep.Data.MessageRoot(),
-				// Fork-agnostic domain since deposits are valid across forks
-				ComputeDomain(spec.DOMAIN_DEPOSIT, spec.GENESIS_FORK_VERSION, Root{})),
-			dep.Data.Signature) {
+		if verifySig != nil && !verifySig(dep) {
 			// invalid signatures are OK,
 			// the depositor will not receive anything because of their mistake,
 			// and the chain continues.
-			return nil
+			return valIndex, false, nil
 		}
 
 		// Add validator and balance entries
@@ -292,29 +394,26 @@ func (spec *Spec) ProcessDeposit(epc *EpochsContext, state *BeaconStateView, dep
 		}
 		validator := validatorRaw.View()
 		if err := validators.Append(validator); err != nil {
-			return err
+			return 0, false, err
 		}
 		bals, err := state.Balances()
 		if err != nil {
-			return err
+			return 0, false, err
 		}
 		if err := bals.Append(Uint64View(balance)); err != nil {
-			return err
+			return 0, false, err
 		}
 		if pc, err := epc.PubkeyCache.AddValidator(valIndex, pubkey); err != nil {
-			return err
+			return 0, false, err
 		} else {
 			epc.PubkeyCache = pc
 		}
+		return valIndex, true, nil
 	} else {
 		// Increase balance by deposit amount
-		bals, err := state.Balances()
-		if err != nil {
-			return err
-		}
-		if err := bals.IncreaseBalance(valIndex, dep.Data.Amount); err != nil {
-			return err
+		if err := state.IncreaseBalance(valIndex, dep.Data.Amount); err != nil {
+			return 0, false, err
 		}
+		return valIndex, false, nil
 	}
-	return nil
 }
@@ -0,0 +1,85 @@
+package beacon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/protolambda/ztyp/tree"
+)
+
+// DepositTree is an incremental merkle tree of deposit data roots, mirroring the deposit contract.
+// The root includes the deposit count mix-in, like the contract and Eth1Data.DepositRoot.
+//
+// Inserting updates only the path from the new leaf to the top of the tree, O(depth) per leaf.
+// The intermediate nodes are kept, to be able to build a proof for any inserted leaf.
+type DepositTree struct {
+	// layers[0] are the leaves, layers[DEPOSIT_CONTRACT_TREE_DEPTH] is the (not mixed-in) root, if any leaves.
+	layers [DEPOSIT_CONTRACT_TREE_DEPTH + 1][]Root
+	hFn    tree.HashFn
+}
+
+func NewDepositTree() *DepositTree {
+	return &DepositTree{hFn: tree.GetHashFn()}
+}
+
+// Count returns the number of inserted leaves.
+func (t *DepositTree) Count() uint64 {
+	return uint64(len(t.layers[0]))
+}
+
+// Insert appends a leaf (the hash-tree-root of a DepositData) to the tree.
+func (t *DepositTree) Insert(leaf Root) error {
+	if t.Count() >= 1<<DEPOSIT_CONTRACT_TREE_DEPTH {
+		return fmt.Errorf("deposit tree is full, cannot insert more than %d leaves", uint64(1)<<DEPOSIT_CONTRACT_TREE_DEPTH)
+	}
+	t.layers[0] = append(t.layers[0], leaf)
+	index := t.Count() - 1
+	node := leaf
+	for d := 0; d < DEPOSIT_CONTRACT_TREE_DEPTH; d++ {
+		if index&1 == 1 {
+			node = t.hFn(t.layers[d][index-1], node)
+		} else {
+			node = t.hFn(node, tree.ZeroHashes[d])
+		}
+		index >>= 1
+		layer := t.layers[d+1]
+		if uint64(len(layer)) == index {
+			t.layers[d+1] = append(layer, node)
+		} else {
+			layer[index] = node
+		}
+	}
+	return nil
+}
+
+func (t *DepositTree) mixIn() (out Root) {
+	binary.LittleEndian.PutUint64(out[:], t.Count())
+	return
+}
+
+// Root returns the deposit root, with the deposit count mixed in.
+func (t *DepositTree) Root() Root {
+	root := tree.ZeroHashes[DEPOSIT_CONTRACT_TREE_DEPTH]
+	if top := t.layers[DEPOSIT_CONTRACT_TREE_DEPTH]; len(top) > 0 {
+		root = top[0]
+	}
+	return t.hFn(root, t.mixIn())
+}
+
+// Proof returns the merkle branch of the leaf at the given index, including the deposit count mix-in,
+// as expected in Deposit.Proof. The proof is valid against the current Root of the tree.
+func (t *DepositTree) Proof(index uint64) (out DepositProof, err error) {
+	if index >= t.Count() {
+		return out, fmt.Errorf("deposit index %d is out of range, deposit tree has %d leaves", index, t.Count())
+	}
+	for d := 0; d < DEPOSIT_CONTRACT_TREE_DEPTH; d++ {
+		sibling := index ^ 1
+		if layer := t.layers[d]; sibling < uint64(len(layer)) {
+			out[d] = layer[sibling]
+		} else {
+			out[d] = tree.ZeroHashes[d]
+		}
+		index >>= 1
+	}
+	out[DEPOSIT_CONTRACT_TREE_DEPTH] = t.mixIn()
+	return out, nil
+}
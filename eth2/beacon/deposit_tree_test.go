@@ -0,0 +1,147 @@
+package beacon
+
+import (
+	"encoding/hex"
+	"github.com/protolambda/zrnt/eth2/util/merkle"
+	"github.com/protolambda/ztyp/tree"
+	. "github.com/protolambda/ztyp/view"
+	"testing"
+)
+
+func TestDepositTreeEmptyRoot(t *testing.T) {
+	// The deposit root of the mainnet deposit contract, before any deposits.
+	var expected Root
+	if _, err := hex.Decode(expected[:], []byte("d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e")); err != nil {
+		t.Fatal(err)
+	}
+	if root := NewDepositTree().Root(); root != expected {
+		t.Fatalf("unexpected empty deposit root: %s", root)
+	}
+}
+
+// Deposit roots of the deposit contract, computed with an independent port of the get_deposit_root function
+// of the deposit contract, after the given count of 32 ETH deposits, where deposit i has pubkey, withdrawal credentials
+// and signature derived from i.
+var depositContractRoots = []struct {
+	count uint64
+	root  string
+}{
+	{0, "d70a234731285c6804c2a4f56711ddb8c82c99740f207854891028af34e27e5e"},
+	{1, "90ecbc1db4be45670402def03113718551749c4e25014f7870f49255c86b6955"},
+	{2, "85a29c900b79c6eeda4705e99641e8b9241c6e0f95d2de1886c0017aa1754c32"},
+	{3, "a5c8d85d3c918ceba24c8dca045903f1a0020ba5f9a70471fd7b9f9401bcba3c"},
+	{4, "3b25e3ab7ba05e0390739457ee796322a30a78dc9bc64ca6df5f2b628a1a691d"},
+	{5, "ddb67ef864284b66db885f3dd856732ba02ae100fbef5ce28207bf8307b02dee"},
+	{16, "c1426fc444316445d12cc4688af275509e8e4ce201e87a159681bf7d7125426c"},
+	{17, "d082579dd764f7b561ca8a6cbb26eb77d60248be7fd4178422cd5b72dfbc43d5"},
+	{33, "d479205bc3cb40ad9bfd8b4b0d01653889453ee1d8fc6bc6f4393b7985930e4f"},
+	{64, "06b5e88709b4c34a2fcb17ac18a54a6d8cc3d45835856ff177e06fd1ed5e4128"},
+	{100, "895012de13aa9c7b74317c5a6cbcfd2a6e6f44f9be477160a336aed46f307513"},
+}
+
+func TestDepositTreeContractRoots(t *testing.T) {
+	depTree := NewDepositTree()
+	hFn := tree.GetHashFn()
+	next := 0
+	for i := uint64(0); ; i++ {
+		if c := depositContractRoots[next]; c.count == i {
+			var expected Root
+			if _, err := hex.Decode(expected[:], []byte(c.root)); err != nil {
+				t.Fatal(err)
+			}
+			if root := depTree.Root(); root != expected {
+				t.Fatalf("unexpected deposit root after %d deposits: %s <> %s", i, root, expected)
+			}
+			next++
+			if next == len(depositContractRoots) {
+				break
+			}
+		}
+		data := DepositData{Amount: 32000000000}
+		data.Pubkey[0] = byte(i)
+		data.WithdrawalCredentials[1] = byte(i)
+		for j := range data.Signature {
+			data.Signature[j] = byte(i)
+		}
+		if err := depTree.Insert(data.HashTreeRoot(hFn)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDepositTree(t *testing.T) {
+	depTree := NewDepositTree()
+	depRoots := NewDepositRootsView()
+	hFn := tree.GetHashFn()
+	for i := 0; i < 33; i++ {
+		data := DepositData{Amount: Gwei(i)}
+		data.Pubkey[0] = byte(i)
+		leaf := data.HashTreeRoot(hFn)
+		if err := depTree.Insert(leaf); err != nil {
+			t.Fatal(err)
+		}
+		rootView := RootView(leaf)
+		if err := depRoots.Append(&rootView); err != nil {
+			t.Fatal(err)
+		}
+		root := depTree.Root()
+		// The genesis deposit root is computed from a full list of deposit data roots.
+		if expected := depRoots.HashTreeRoot(hFn); root != expected {
+			t.Fatalf("deposit root mismatch after %d deposits: %s <> %s", i+1, root, expected)
+		}
+		for j := 0; j <= i; j++ {
+			proof, err := depTree.Proof(uint64(j))
+			if err != nil {
+				t.Fatal(err)
+			}
+			leafJ, err := depRoots.Get(uint64(j))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !merkle.VerifyMerkleBranch(leafJ.HashTreeRoot(hFn), proof[:], DEPOSIT_CONTRACT_TREE_DEPTH+1, uint64(j), root) {
+				t.Fatalf("invalid proof for deposit %d after %d deposits", j, i+1)
+			}
+		}
+	}
+	if _, err := depTree.Proof(depTree.Count()); err == nil {
+		t.Fatal("expected error for out of range proof")
+	}
+}
+
+func TestVerifyDepositProof(t *testing.T) {
+	depTree := NewDepositTree()
+	deps := make([]Deposit, 5)
+	hFn := tree.GetHashFn()
+	for i := range deps {
+		deps[i].Data.Pubkey[0] = byte(i)
+		deps[i].Data.Amount = Gwei(i)
+		if err := depTree.Insert(deps[i].Data.HashTreeRoot(hFn)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := depTree.Root()
+	for i := range deps {
+		proof, err := depTree.Proof(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		deps[i].Proof = proof
+		if !VerifyDepositProof(&deps[i], uint64(i), root) {
+			t.Fatalf("invalid proof for deposit %d", i)
+		}
+	}
+	if VerifyDepositProof(&deps[1], 2, root) {
+		t.Fatal("proof must not be valid for another index")
+	}
+	// The deposit count is part of the root, the proof of an earlier tree does not match the later root.
+	if err := depTree.Insert(Root{0xaa}); err != nil {
+		t.Fatal(err)
+	}
+	if VerifyDepositProof(&deps[0], 0, depTree.Root()) {
+		t.Fatal("proof must not be valid against a root with a different deposit count")
+	}
+	deps[3].Data.Amount++
+	if VerifyDepositProof(&deps[3], 3, root) {
+		t.Fatal("proof must not be valid for modified deposit data")
+	}
+}
@@ -2,14 +2,20 @@ package beacon
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/protolambda/zrnt/eth2/util/math"
+	. "github.com/protolambda/ztyp/view"
+	"runtime"
 	"sort"
+	"sync"
 )
 
 type EpochStakeSummary struct {
-	SourceStake Gwei
-	TargetStake Gwei
-	HeadStake   Gwei
+	SourceStake Gwei `json:"source_stake"`
+	TargetStake Gwei `json:"target_stake"`
+	HeadStake   Gwei `json:"head_stake"`
 }
 
 type EpochProcess struct {
@@ -37,35 +43,248 @@ type EpochProcess struct {
 	ExitQueueEnd      Epoch
 	ExitQueueEndChurn uint64
 	ChurnLimit        uint64
+
+	effectiveBalanceChanges []EffectiveBalanceChange
+
+	// Number of validators per combination of flags
+	flagCounts [256]uint64
+	// Unslashed previous epoch attesting stake, as fraction of the total active stake
+	participation [3]float64
 }
 
-func (spec *Spec) GetChurnLimit(activeValidatorCount uint64) uint64 {
-	return math.MaxU64(spec.MIN_PER_EPOCH_CHURN_LIMIT, activeValidatorCount/spec.CHURN_LIMIT_QUOTIENT)
+type EffectiveBalanceChange struct {
+	Index ValidatorIndex `json:"index"`
+	Old   Gwei           `json:"old"`
+	New   Gwei           `json:"new"`
 }
 
-func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, state *BeaconStateView) (out *EpochProcess, err error) {
-	validators, err := state.Validators()
+// EffectiveBalanceChanges returns the effective balance updates, in validator index order.
+// Effective balances are updated based on the balances after rewards and penalties,
+// so the changes are only available after ProcessEpochFinalUpdates ran with this epoch process.
+func (process *EpochProcess) EffectiveBalanceChanges() []EffectiveBalanceChange {
+	return process.effectiveBalanceChanges
+}
+
+// FlagCounts returns the number of validators for each combination of attester flags that occurs.
+func (process *EpochProcess) FlagCounts() map[AttesterFlag]uint64 {
+	out := make(map[AttesterFlag]uint64)
+	for flags, count := range process.flagCounts {
+		if count != 0 {
+			out[AttesterFlag(flags)] = count
+		}
+	}
+	return out
+}
+
+// ParticipationRate returns the unslashed stake that attested to the correct source, target and head
+// in the previous epoch, as fraction of the total active stake.
+func (process *EpochProcess) ParticipationRate() (source float64, target float64, head float64) {
+	return process.participation[0], process.participation[1], process.participation[2]
+}
+
+// InclusionStats summarizes the inclusion delays of the attestations of the previous epoch.
+type InclusionStats struct {
+	// Number of unslashed validators that attested in the previous epoch.
+	Count uint64 `json:"count"`
+	// Delays are zero if there are no attesters.
+	MinDelay     Slot    `json:"min_delay"`
+	MaxDelay     Slot    `json:"max_delay"`
+	AverageDelay float64 `json:"average_delay"`
+	// Number of attesters per inclusion delay, indexed by the delay, up to and including MaxDelay.
+	// Capped at SLOTS_PER_EPOCH, the maximum delay of a valid attestation: the last bucket also counts
+	// any larger delay, so the histogram has at most SLOTS_PER_EPOCH+1 entries.
+	Histogram []uint64 `json:"histogram"`
+}
+
+// InclusionDelayStats computes the inclusion delay statistics of the unslashed attesters of the previous epoch.
+func (process *EpochProcess) InclusionDelayStats(spec *Spec) (out InclusionStats) {
+	total := uint64(0)
+	for i := range process.Statuses {
+		status := &process.Statuses[i]
+		if !status.Flags.HasMarkers(PrevSourceAttester | UnslashedAttester) {
+			continue
+		}
+		delay := status.InclusionDelay
+		if out.Count == 0 || delay < out.MinDelay {
+			out.MinDelay = delay
+		}
+		if delay > out.MaxDelay {
+			out.MaxDelay = delay
+		}
+		bucket := delay
+		if bucket > spec.SLOTS_PER_EPOCH {
+			bucket = spec.SLOTS_PER_EPOCH
+		}
+		for Slot(len(out.Histogram)) <= bucket {
+			out.Histogram = append(out.Histogram, 0)
+		}
+		out.Histogram[bucket]++
+		out.Count++
+		total += uint64(delay)
+	}
+	if out.Count > 0 {
+		out.AverageDelay = float64(total) / float64(out.Count)
+	}
+	return
+}
+
+// StatusOf returns the attester status of the validator, or an error if the index is out of range.
+func (process *EpochProcess) StatusOf(index ValidatorIndex) (*AttesterStatus, error) {
+	if uint64(index) >= uint64(len(process.Statuses)) {
+		return nil, fmt.Errorf("validator index %d out of range, only %d validators", index, len(process.Statuses))
+	}
+	return &process.Statuses[index], nil
+}
+
+// DidAttestTarget returns whether the validator attested to the correct target in the previous epoch,
+// i.e. has the PrevTargetAttester flag. Slashed validators are included: check UnslashedAttester
+// to tell if the vote counts towards justification and rewards.
+func (process *EpochProcess) DidAttestTarget(index ValidatorIndex) (bool, error) {
+	status, err := process.StatusOf(index)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	count, err := validators.Length()
+	return status.Flags.HasMarkers(PrevTargetAttester), nil
+}
+
+// DidAttestHead returns whether the validator attested to the correct head in the previous epoch,
+// i.e. has the PrevHeadAttester flag. Like DidAttestTarget, slashed validators are included.
+func (process *EpochProcess) DidAttestHead(index ValidatorIndex) (bool, error) {
+	status, err := process.StatusOf(index)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	return status.Flags.HasMarkers(PrevHeadAttester), nil
+}
 
-	prevEpoch := epc.PreviousEpoch.Epoch
-	currentEpoch := epc.CurrentEpoch.Epoch
+// attesterStatusJSON is the compact JSON form of an AttesterStatus, for diagnostics.
+type attesterStatusJSON struct {
+	Flags            AttesterFlag `json:"flags"`
+	EffectiveBalance Gwei         `json:"effective_balance"`
+	InclusionDelay   Slot         `json:"inclusion_delay"`
+}
 
-	out = &EpochProcess{
-		Statuses:  make([]AttesterStatus, count, count),
-		PrevEpoch: prevEpoch,
-		CurrEpoch: currentEpoch,
+// MarshalJSON encodes the epoch process, to dump and compare it when debugging epoch transitions.
+// Statuses are encoded compactly: the flags as a single byte, the effective balance and the inclusion delay.
+// Fields are always encoded in the same order. Flag counts are only included for the flag combinations that occur.
+func (process *EpochProcess) MarshalJSON() ([]byte, error) {
+	statuses := make([]attesterStatusJSON, len(process.Statuses), len(process.Statuses))
+	for i := range process.Statuses {
+		status := &process.Statuses[i]
+		statuses[i].Flags = status.Flags
+		statuses[i].InclusionDelay = status.InclusionDelay
+		if status.Validator != nil {
+			statuses[i].EffectiveBalance = status.Validator.EffectiveBalance
+		}
+	}
+	out := &struct {
+		PrevEpoch                         Epoch                    `json:"prev_epoch"`
+		CurrEpoch                         Epoch                    `json:"curr_epoch"`
+		Statuses                          []attesterStatusJSON     `json:"statuses"`
+		TotalActiveStake                  Gwei                     `json:"total_active_stake"`
+		PrevEpochUnslashedStake           EpochStakeSummary        `json:"prev_epoch_unslashed_stake"`
+		CurrEpochUnslashedTargetStake     Gwei                     `json:"curr_epoch_unslashed_target_stake"`
+		ActiveValidators                  Uint64View               `json:"active_validators"`
+		IndicesToSlash                    []ValidatorIndex         `json:"indices_to_slash"`
+		IndicesToSetActivationEligibility []ValidatorIndex         `json:"indices_to_set_activation_eligibility"`
+		IndicesToMaybeActivate            []ValidatorIndex         `json:"indices_to_maybe_activate"`
+		IndicesToEject                    []ValidatorIndex         `json:"indices_to_eject"`
+		ExitQueueEnd                      Epoch                    `json:"exit_queue_end"`
+		ExitQueueEndChurn                 Uint64View               `json:"exit_queue_end_churn"`
+		ChurnLimit                        Uint64View               `json:"churn_limit"`
+		EffectiveBalanceChanges           []EffectiveBalanceChange `json:"effective_balance_changes"`
+		FlagCounts                        map[AttesterFlag]uint64  `json:"flag_counts"`
+		Participation                     struct {
+			Source float64 `json:"source"`
+			Target float64 `json:"target"`
+			Head   float64 `json:"head"`
+		} `json:"participation"`
+	}{
+		PrevEpoch:                         process.PrevEpoch,
+		CurrEpoch:                         process.CurrEpoch,
+		Statuses:                          statuses,
+		TotalActiveStake:                  process.TotalActiveStake,
+		PrevEpochUnslashedStake:           process.PrevEpochUnslashedStake,
+		CurrEpochUnslashedTargetStake:     process.CurrEpochUnslashedTargetStake,
+		ActiveValidators:                  Uint64View(process.ActiveValidators),
+		IndicesToSlash:                    process.IndicesToSlash,
+		IndicesToSetActivationEligibility: process.IndicesToSetActivationEligibility,
+		IndicesToMaybeActivate:            process.IndicesToMaybeActivate,
+		IndicesToEject:                    process.IndicesToEject,
+		ExitQueueEnd:                      process.ExitQueueEnd,
+		ExitQueueEndChurn:                 Uint64View(process.ExitQueueEndChurn),
+		ChurnLimit:                        Uint64View(process.ChurnLimit),
+		EffectiveBalanceChanges:           process.effectiveBalanceChanges,
+		FlagCounts:                        process.FlagCounts(),
+	}
+	out.Participation.Source, out.Participation.Target, out.Participation.Head = process.ParticipationRate()
+	return json.Marshal(out)
+}
+
+func (spec *Spec) GetChurnLimit(activeValidatorCount uint64) uint64 {
+	return math.MaxU64(spec.MIN_PER_EPOCH_CHURN_LIMIT, activeValidatorCount/spec.CHURN_LIMIT_QUOTIENT)
+}
+
+// validatorScan is the result of scanning (a range of) the validator registry during epoch preparation.
+// Index lists are in ascending index order.
+type validatorScan struct {
+	ActiveCount                       uint64
+	TotalActiveStake                  Gwei
+	IndicesToSlash                    []ValidatorIndex
+	IndicesToSetActivationEligibility []ValidatorIndex
+	IndicesToMaybeActivate            []ValidatorIndex
+	IndicesToEject                    []ValidatorIndex
+}
+
+func (spec *Spec) scanValidator(i ValidatorIndex, val *ValidatorView, status *AttesterStatus,
+	prevEpoch Epoch, currentEpoch Epoch, res *validatorScan) error {
+	flat, err := ToFlatValidator(val)
+	if err != nil {
+		return err
 	}
 
+	status.Validator = flat
+	status.AttestedProposer = ValidatorIndexMarker
+
 	slashingsEpoch := currentEpoch + (spec.EPOCHS_PER_SLASHINGS_VECTOR / 2)
-	exitQueueEnd := spec.ComputeActivationExitEpoch(currentEpoch)
+	if flat.Slashed {
+		if slashingsEpoch == flat.WithdrawableEpoch {
+			res.IndicesToSlash = append(res.IndicesToSlash, i)
+		}
+	} else {
+		status.Flags |= UnslashedAttester
+	}
+
+	if flat.IsActive(prevEpoch) || (flat.Slashed && (prevEpoch+1 < flat.WithdrawableEpoch)) {
+		status.Flags |= EligibleAttester
+	}
 
-	activeCount := uint64(0)
+	status.Active = flat.IsActive(currentEpoch)
+	if status.Active {
+		res.ActiveCount++
+		if res.TotalActiveStake, err = res.TotalActiveStake.SafeAdd(flat.EffectiveBalance); err != nil {
+			return err
+		}
+	}
+
+	if flat.ActivationEligibilityEpoch == FAR_FUTURE_EPOCH && flat.EffectiveBalance == spec.MAX_EFFECTIVE_BALANCE {
+		res.IndicesToSetActivationEligibility = append(res.IndicesToSetActivationEligibility, i)
+	}
+
+	if flat.ActivationEpoch == FAR_FUTURE_EPOCH && flat.ActivationEligibilityEpoch <= currentEpoch {
+		res.IndicesToMaybeActivate = append(res.IndicesToMaybeActivate, i)
+	}
+
+	if status.Active && flat.EffectiveBalance <= spec.EJECTION_BALANCE && flat.ExitEpoch == FAR_FUTURE_EPOCH {
+		res.IndicesToEject = append(res.IndicesToEject, i)
+	}
+	return nil
+}
+
+// scanValidatorsSerial scans the full registry with a single iterator.
+func (spec *Spec) scanValidatorsSerial(ctx context.Context, validators *ValidatorsRegistryView,
+	statuses []AttesterStatus, prevEpoch Epoch, currentEpoch Epoch) (*validatorScan, error) {
+	res := new(validatorScan)
 	valIter := validators.ReadonlyIter()
 	for i := ValidatorIndex(0); true; i++ {
 		// every 1024 validators, check if the context is done.
@@ -88,45 +307,129 @@ func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, s
 		if err != nil {
 			return nil, err
 		}
-		flat, err := ToFlatValidator(val)
-		if err != nil {
+		if err := spec.scanValidator(i, val, &statuses[i], prevEpoch, currentEpoch, res); err != nil {
 			return nil, err
 		}
+	}
+	return res, nil
+}
 
-		status := &out.Statuses[i]
-		status.Validator = flat
-		status.AttestedProposer = ValidatorIndexMarker
-
-		if flat.Slashed {
-			if slashingsEpoch == flat.WithdrawableEpoch {
-				out.IndicesToSlash = append(out.IndicesToSlash, i)
+// scanValidatorsParallel splits the registry in the given number of shards, and scans each shard concurrently.
+// The shard results are merged in shard order, so the output is the same as that of a serial scan.
+func (spec *Spec) scanValidatorsParallel(ctx context.Context, validators *ValidatorsRegistryView,
+	statuses []AttesterStatus, prevEpoch Epoch, currentEpoch Epoch, shards uint64) (*validatorScan, error) {
+	count := uint64(len(statuses))
+	shardSize := (count + shards - 1) / shards
+	results := make([]validatorScan, shards, shards)
+	errs := make([]error, shards, shards)
+	var wg sync.WaitGroup
+	for s := uint64(0); s < shards; s++ {
+		start := s * shardSize
+		end := math.MinU64(start+shardSize, count)
+		if start >= end {
+			break
+		}
+		wg.Add(1)
+		go func(res *validatorScan, errOut *error, start uint64, end uint64) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				// every 1024 validators, check if the context is done.
+				if i&((1<<10)-1) == 0 {
+					select {
+					case <-ctx.Done():
+						*errOut = TransitionCancelErr
+						return
+					default: // Don't block.
+						break
+					}
+				}
+				val, err := validators.Validator(ValidatorIndex(i))
+				if err != nil {
+					*errOut = err
+					return
+				}
+				if err := spec.scanValidator(ValidatorIndex(i), val, &statuses[i], prevEpoch, currentEpoch, res); err != nil {
+					*errOut = err
+					return
+				}
 			}
-		} else {
-			status.Flags |= UnslashedAttester
+		}(&results[s], &errs[s], start, end)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
 		}
-
-		if flat.IsActive(prevEpoch) || (flat.Slashed && (prevEpoch+1 < flat.WithdrawableEpoch)) {
-			status.Flags |= EligibleAttester
+	}
+	out := new(validatorScan)
+	for i := range results {
+		res := &results[i]
+		out.ActiveCount += res.ActiveCount
+		var err error
+		if out.TotalActiveStake, err = out.TotalActiveStake.SafeAdd(res.TotalActiveStake); err != nil {
+			return nil, err
 		}
+		out.IndicesToSlash = append(out.IndicesToSlash, res.IndicesToSlash...)
+		out.IndicesToSetActivationEligibility = append(out.IndicesToSetActivationEligibility, res.IndicesToSetActivationEligibility...)
+		out.IndicesToMaybeActivate = append(out.IndicesToMaybeActivate, res.IndicesToMaybeActivate...)
+		out.IndicesToEject = append(out.IndicesToEject, res.IndicesToEject...)
+	}
+	return out, nil
+}
 
-		status.Active = flat.IsActive(currentEpoch)
-		if status.Active {
-			activeCount++
-			out.TotalActiveStake += flat.EffectiveBalance
-		}
+func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, state *BeaconStateView) (out *EpochProcess, err error) {
+	return spec.prepareEpochProcess(ctx, epc, state, nil, 1)
+}
 
-		if flat.ActivationEligibilityEpoch == FAR_FUTURE_EPOCH && flat.EffectiveBalance == spec.MAX_EFFECTIVE_BALANCE {
-			out.IndicesToSetActivationEligibility = append(out.IndicesToSetActivationEligibility, i)
-		}
+// PrepareEpochProcessParallel is like PrepareEpochProcess,
+// but scans the validator registry with runtime.GOMAXPROCS(0) concurrent shards.
+// The result is the same as that of PrepareEpochProcess.
+func (spec *Spec) PrepareEpochProcessParallel(ctx context.Context, epc *EpochsContext, state *BeaconStateView) (out *EpochProcess, err error) {
+	return spec.prepareEpochProcess(ctx, epc, state, nil, uint64(runtime.GOMAXPROCS(0)))
+}
 
-		if flat.ActivationEpoch == FAR_FUTURE_EPOCH && flat.ActivationEligibilityEpoch <= currentEpoch {
-			out.IndicesToMaybeActivate = append(out.IndicesToMaybeActivate, i)
-		}
+// PrepareEpochProcessWithProvider is like PrepareEpochProcess, but looks up the block roots
+// that are older than the block roots of the state in the states of the provider.
+func (spec *Spec) PrepareEpochProcessWithProvider(ctx context.Context, epc *EpochsContext, state *BeaconStateView, provider StateProvider) (out *EpochProcess, err error) {
+	return spec.prepareEpochProcess(ctx, epc, state, provider, 1)
+}
 
-		if status.Active && flat.EffectiveBalance <= spec.EJECTION_BALANCE && flat.ExitEpoch == FAR_FUTURE_EPOCH {
-			out.IndicesToEject = append(out.IndicesToEject, i)
-		}
+func (spec *Spec) prepareEpochProcess(ctx context.Context, epc *EpochsContext, state *BeaconStateView, provider StateProvider, shards uint64) (out *EpochProcess, err error) {
+	validators, err := state.Validators()
+	if err != nil {
+		return nil, err
+	}
+	count, err := validators.Length()
+	if err != nil {
+		return nil, err
+	}
+
+	prevEpoch := epc.PreviousEpoch.Epoch
+	currentEpoch := epc.CurrentEpoch.Epoch
+
+	out = &EpochProcess{
+		Statuses:  make([]AttesterStatus, count, count),
+		PrevEpoch: prevEpoch,
+		CurrEpoch: currentEpoch,
+	}
+
+	exitQueueEnd := spec.ComputeActivationExitEpoch(currentEpoch)
+
+	var scan *validatorScan
+	if shards <= 1 || count < shards {
+		scan, err = spec.scanValidatorsSerial(ctx, validators, out.Statuses, prevEpoch, currentEpoch)
+	} else {
+		scan, err = spec.scanValidatorsParallel(ctx, validators, out.Statuses, prevEpoch, currentEpoch, shards)
+	}
+	if err != nil {
+		return nil, err
 	}
+	activeCount := scan.ActiveCount
+	out.TotalActiveStake = scan.TotalActiveStake
+	out.IndicesToSlash = scan.IndicesToSlash
+	out.IndicesToSetActivationEligibility = scan.IndicesToSetActivationEligibility
+	out.IndicesToMaybeActivate = scan.IndicesToMaybeActivate
+	out.IndicesToEject = scan.IndicesToEject
 
 	// Order by the sequence of activation_eligibility_epoch setting and then index
 	sort.Slice(out.IndicesToMaybeActivate, func(i int, j int) bool {
@@ -140,11 +443,19 @@ func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, s
 		return a < b
 	})
 
+	// The exit queue may already extend past the activation-exit epoch,
+	// e.g. when voluntary exits in blocks filled up the churn of earlier exit epochs.
 	exitQueueEndChurn := uint64(0)
 	for i := ValidatorIndex(0); i < ValidatorIndex(count); i++ {
-		status := &out.Statuses[i]
-		if status.Validator.ExitEpoch == exitQueueEnd {
+		valExit := out.Statuses[i].Validator.ExitEpoch
+		if valExit == FAR_FUTURE_EPOCH {
+			continue
+		}
+		if valExit == exitQueueEnd {
 			exitQueueEndChurn++
+		} else if valExit > exitQueueEnd {
+			exitQueueEnd = valExit
+			exitQueueEndChurn = 1
 		}
 	}
 	churnLimit := spec.GetChurnLimit(activeCount)
@@ -165,8 +476,15 @@ func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, s
 		if err != nil {
 			return err
 		}
-		actualTargetBlockRoot, err := spec.GetBlockRootAtSlot(state, startSlot)
-		if err != nil {
+		// The target is unknown if the epoch starts at the current slot (e.g. at genesis),
+		// no attestation can match it, not even with a zero target root.
+		targetKnown := true
+		actualTargetBlockRoot, err := spec.GetBlockRootAtSlotWithProvider(state, provider, startSlot)
+		if errors.Is(err, ErrSlotInFuture) {
+			targetKnown = false
+		} else if errors.Is(err, ErrSlotTooOld) {
+			return fmt.Errorf("target of epoch %d is older than the block roots of the state: %w", epoch, err)
+		} else if err != nil {
 			return err
 		}
 		participants := make([]ValidatorIndex, 0, spec.MAX_VALIDATORS_PER_COMMITTEE)
@@ -198,20 +516,19 @@ func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, s
 				return err
 			}
 
-			attBlockRoot, err := spec.GetBlockRootAtSlot(state, att.Data.Slot)
+			attBlockRoot, err := spec.GetBlockRootAtSlotWithProvider(state, provider, att.Data.Slot)
 			if err != nil {
 				return err
 			}
 
 			// attestation-target is already known to be this epoch, get it from the pre-computed shuffling directly.
-			committee, err := epc.GetBeaconCommittee(att.Data.Slot, att.Data.Index)
+			committee, err := epc.GetBeaconCommitteeCtx(ctx, att.Data.Slot, att.Data.Index)
 			if err != nil {
 				return err
 			}
 
-			participants = participants[:0]                                     // reset old slice (re-used in for loop)
-			participants = append(participants, committee...)                   // add committee indices
-			participants = att.AggregationBits.FilterParticipants(participants) // only keep the participants
+			// only keep the participants, re-using the participants buffer, without modifying the committee.
+			participants = att.AggregationBits.FilterParticipantsInto(participants, committee)
 
 			if epoch == prevEpoch {
 				for _, p := range participants {
@@ -232,7 +549,7 @@ func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, s
 				status.Flags |= sourceFlag
 
 				// If the attestation is for the boundary:
-				if att.Data.Target.Root == actualTargetBlockRoot {
+				if targetKnown && att.Data.Target.Root == actualTargetBlockRoot {
 					status.Flags |= targetFlag
 
 					// If the attestation is for the head (att the time of attestation):
@@ -264,24 +581,40 @@ func (spec *Spec) PrepareEpochProcess(ctx context.Context, epc *EpochsContext, s
 
 	for i := 0; i < len(out.Statuses); i++ {
 		status := out.Statuses[i]
+		out.flagCounts[status.Flags]++
 		// nested, since they are subsets anyway
+		stake := &out.PrevEpochUnslashedStake
 		if status.Flags.HasMarkers(PrevSourceAttester | UnslashedAttester) {
-			out.PrevEpochUnslashedStake.SourceStake += status.Validator.EffectiveBalance
+			if stake.SourceStake, err = stake.SourceStake.SafeAdd(status.Validator.EffectiveBalance); err != nil {
+				return nil, err
+			}
 			// already know it's unslashed, just look if attesting target, then head
 			if status.Flags.HasMarkers(PrevTargetAttester) {
-				out.PrevEpochUnslashedStake.TargetStake += status.Validator.EffectiveBalance
+				if stake.TargetStake, err = stake.TargetStake.SafeAdd(status.Validator.EffectiveBalance); err != nil {
+					return nil, err
+				}
 				if status.Flags.HasMarkers(PrevHeadAttester) {
-					out.PrevEpochUnslashedStake.HeadStake += status.Validator.EffectiveBalance
+					if stake.HeadStake, err = stake.HeadStake.SafeAdd(status.Validator.EffectiveBalance); err != nil {
+						return nil, err
+					}
 				}
 			}
 		}
 		if status.Flags.HasMarkers(CurrTargetAttester | UnslashedAttester) {
-			out.CurrEpochUnslashedTargetStake += status.Validator.EffectiveBalance
+			if out.CurrEpochUnslashedTargetStake, err = out.CurrEpochUnslashedTargetStake.SafeAdd(status.Validator.EffectiveBalance); err != nil {
+				return nil, err
+			}
 		}
 	}
 	if out.TotalActiveStake < spec.EFFECTIVE_BALANCE_INCREMENT {
 		out.TotalActiveStake = spec.EFFECTIVE_BALANCE_INCREMENT
 	}
+	// The participation is based on the actual stake, before the stakes are raised to the minimum below.
+	out.participation = [3]float64{
+		float64(out.PrevEpochUnslashedStake.SourceStake) / float64(out.TotalActiveStake),
+		float64(out.PrevEpochUnslashedStake.TargetStake) / float64(out.TotalActiveStake),
+		float64(out.PrevEpochUnslashedStake.HeadStake) / float64(out.TotalActiveStake),
+	}
 	if out.PrevEpochUnslashedStake.SourceStake < spec.EFFECTIVE_BALANCE_INCREMENT {
 		out.PrevEpochUnslashedStake.SourceStake = spec.EFFECTIVE_BALANCE_INCREMENT
 	}
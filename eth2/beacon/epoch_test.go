@@ -0,0 +1,561 @@
+package beacon_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"reflect"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// testSingleAttestation includes a single correct attestation, of a single validator, in the first epoch,
+// and moves to the next epoch, for the attestation to be of the previous epoch. The attester is returned.
+func testSingleAttestation(t *testing.T, spec *beacon.Spec, epc *beacon.EpochsContext, state *beacon.BeaconStateView) beacon.ValidatorIndex {
+	ctx := context.Background()
+	if err := spec.ProcessSlots(ctx, epc, state, 2); err != nil {
+		t.Fatal(err)
+	}
+	justified, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := justified.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetRoot, err := spec.GetBlockRoot(state, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headRoot, err := spec.GetBlockRootAtSlot(state, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committee, err := epc.GetBeaconCommittee(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits := make(beacon.CommitteeBits, len(committee)/8+1)
+	bits.SetBit(uint64(len(committee)), true) // delimiter bit
+	bits.SetBit(0, true)
+	att := &beacon.Attestation{
+		AggregationBits: bits,
+		Data: beacon.AttestationData{
+			Slot:            1,
+			BeaconBlockRoot: headRoot,
+			Source:          source,
+			Target:          beacon.Checkpoint{Epoch: 0, Root: targetRoot},
+		},
+	}
+	if err := spec.ProcessAttestationWithMode(epc, state, att, beacon.SkipAllSignatures); err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.ProcessSlots(ctx, epc, state, spec.SLOTS_PER_EPOCH+1); err != nil {
+		t.Fatal(err)
+	}
+	return committee[0]
+}
+
+func TestEpochProcessFlagCounts(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	testSingleAttestation(t, spec, epc, state)
+	process, err := spec.PrepareEpochProcess(context.Background(), epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := process.FlagCounts()
+	base := beacon.UnslashedAttester | beacon.EligibleAttester
+	attester := base | beacon.PrevSourceAttester | beacon.PrevTargetAttester | beacon.PrevHeadAttester
+	if len(counts) != 2 || counts[attester] != 1 || counts[base] != uint64(len(validators)-1) {
+		t.Fatalf("unexpected flag counts: %v", counts)
+	}
+	sourceRate, targetRate, headRate := process.ParticipationRate()
+	expected := 1 / float64(len(validators))
+	if sourceRate != expected || targetRate != expected || headRate != expected {
+		t.Fatalf("unexpected participation rate: %f %f %f", sourceRate, targetRate, headRate)
+	}
+}
+
+func TestRewardsAndPenaltiesForValidator(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	attester := testSingleAttestation(t, spec, epc, state)
+	ctx := context.Background()
+	process, err := spec.PrepareEpochProcess(ctx, epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp, err := spec.AttestationRewardsAndPenalties(ctx, epc, process, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The full deltas, summed the same way as when they are applied to the balances.
+	sum := beacon.NewDeltas(uint64(len(validators)))
+	sum.Add(rp.Source)
+	sum.Add(rp.Target)
+	sum.Add(rp.Head)
+	sum.Add(rp.InclusionDelay)
+	sum.Add(rp.Inactivity)
+
+	proposer := process.Statuses[attester].AttestedProposer
+	nonAttester := (attester + 1) % beacon.ValidatorIndex(len(validators))
+	if nonAttester == proposer {
+		nonAttester = (nonAttester + 1) % beacon.ValidatorIndex(len(validators))
+	}
+	for _, i := range []beacon.ValidatorIndex{attester, proposer, nonAttester} {
+		out, err := rp.ForValidator(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		components := []struct {
+			name   string
+			deltas *beacon.Deltas
+			got    beacon.DeltaComponent
+		}{
+			{"source", rp.Source, out.Source},
+			{"target", rp.Target, out.Target},
+			{"head", rp.Head, out.Head},
+			{"inclusion_delay", rp.InclusionDelay, out.InclusionDelay},
+			{"inactivity", rp.Inactivity, out.Inactivity},
+		}
+		for _, c := range components {
+			expected := beacon.DeltaComponent{Reward: c.deltas.Rewards[i], Penalty: c.deltas.Penalties[i]}
+			if c.got != expected {
+				t.Errorf("validator %d %s: got %+v, expected %+v", i, c.name, c.got, expected)
+			}
+		}
+		if expected := int64(sum.Rewards[i]) - int64(sum.Penalties[i]); out.Net != expected {
+			t.Errorf("validator %d: got net %d, expected %d", i, out.Net, expected)
+		}
+	}
+	// The attester is rewarded, the others are not, for the breakdown to be meaningful.
+	if out, _ := rp.ForValidator(attester); out.Source.Reward == 0 || out.InclusionDelay.Reward == 0 {
+		t.Errorf("expected attester rewards, got %+v", out)
+	}
+	if out, _ := rp.ForValidator(proposer); out.InclusionDelay.Reward == 0 {
+		t.Errorf("expected proposer reward, got %+v", out)
+	}
+	if out, _ := rp.ForValidator(nonAttester); out.Net >= 0 {
+		t.Errorf("expected net penalty for non-attester, got %+v", out)
+	}
+	if _, err := rp.ForValidator(beacon.ValidatorIndex(len(validators))); err == nil {
+		t.Fatal("expected error for out-of-range validator index")
+	}
+}
+
+func TestEpochProcessBalanceOverflow(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A malformed state, with active validators with impossible effective balances.
+	for i := beacon.ValidatorIndex(0); i < 2; i++ {
+		val, err := vals.Validator(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := val.SetEffectiveBalance(1 << 63); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := spec.PrepareEpochProcess(context.Background(), epc, state); err != beacon.ErrBalanceOverflow {
+		t.Fatalf("expected balance overflow error, got: %v", err)
+	}
+	if _, err := spec.PrepareEpochProcessParallel(context.Background(), epc, state); err != beacon.ErrBalanceOverflow {
+		t.Fatalf("expected balance overflow error from parallel preparation, got: %v", err)
+	}
+}
+
+func TestProcessJustificationAndFinalization(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	// Last slot of epoch 4
+	if err := spec.ProcessSlots(ctx, epc, state, 5*spec.SLOTS_PER_EPOCH-1); err != nil {
+		t.Fatal(err)
+	}
+	// Epoch 3 was justified by the previous epoch transition
+	prevJustified := beacon.Checkpoint{Epoch: 2, Root: beacon.Root{2}}
+	currJustified := beacon.Checkpoint{Epoch: 3, Root: beacon.Root{3}}
+	for _, ch := range []struct {
+		get func() (*beacon.CheckpointView, error)
+		v   *beacon.Checkpoint
+	}{{state.PreviousJustifiedCheckpoint, &prevJustified}, {state.CurrentJustifiedCheckpoint, &currJustified}} {
+		view, err := ch.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := view.Set(ch.v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bitsView, err := state.JustificationBits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bitsView.Set(beacon.JustificationBits{0b0001}); err != nil {
+		t.Fatal(err)
+	}
+
+	total := spec.MAX_EFFECTIVE_BALANCE * 64
+	process := &beacon.EpochProcess{
+		PrevEpoch:                     3,
+		CurrEpoch:                     4,
+		TotalActiveStake:              total,
+		PrevEpochUnslashedStake:       beacon.EpochStakeSummary{TargetStake: total},
+		CurrEpochUnslashedTargetStake: total,
+	}
+	res, err := spec.ProcessJustificationAndFinalization(ctx, epc, process, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Skipped || !res.PreviousEpochJustified || !res.CurrentEpochJustified {
+		t.Fatalf("expected previous and current epoch to be justified: %+v", res)
+	}
+	if res.Rules != beacon.FinalizationRule12 {
+		t.Fatalf("expected only rule 12 to apply, got %b", res.Rules)
+	}
+	if res.Bits != (beacon.JustificationBits{0b0011}) {
+		t.Fatalf("unexpected bits: %b", res.Bits[0])
+	}
+	if res.Finalized != currJustified || res.PreviousJustified != currJustified {
+		t.Fatalf("expected epoch 3 to be finalized and previous justified: %+v", res)
+	}
+	root, err := spec.GetBlockRoot(state, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CurrentJustified != (beacon.Checkpoint{Epoch: 4, Root: root}) {
+		t.Fatalf("unexpected current justified checkpoint: %+v", res.CurrentJustified)
+	}
+	finCh, err := state.FinalizedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fin, err := finCh.Raw(); err != nil {
+		t.Fatal(err)
+	} else if fin != res.Finalized {
+		t.Fatalf("state finalized checkpoint %+v does not match result %+v", fin, res.Finalized)
+	}
+}
+
+func TestEpochProcessInclusionDelayStats(t *testing.T) {
+	attester := beacon.PrevSourceAttester | beacon.UnslashedAttester
+	process := &beacon.EpochProcess{Statuses: []beacon.AttesterStatus{
+		{Flags: attester, InclusionDelay: 1},
+		{Flags: attester, InclusionDelay: 3},
+		{Flags: attester | beacon.PrevTargetAttester, InclusionDelay: 1},
+		// slashed, not counted
+		{Flags: beacon.PrevSourceAttester, InclusionDelay: 5},
+		// did not attest
+		{Flags: beacon.UnslashedAttester},
+	}}
+	spec := configs.Minimal
+	stats := process.InclusionDelayStats(spec)
+	if stats.Count != 3 || stats.MinDelay != 1 || stats.MaxDelay != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if stats.AverageDelay < 1.66 || stats.AverageDelay > 1.67 {
+		t.Fatalf("unexpected average delay: %f", stats.AverageDelay)
+	}
+	expected := []uint64{0, 2, 0, 1}
+	if len(stats.Histogram) != len(expected) {
+		t.Fatalf("unexpected histogram: %v", stats.Histogram)
+	}
+	for i, v := range expected {
+		if stats.Histogram[i] != v {
+			t.Fatalf("unexpected histogram: %v", stats.Histogram)
+		}
+	}
+	if empty := (&beacon.EpochProcess{}).InclusionDelayStats(spec); empty.Count != 0 || empty.AverageDelay != 0 {
+		t.Fatalf("expected empty stats: %+v", empty)
+	}
+	// delays beyond an epoch are counted in the last bucket
+	process.Statuses = append(process.Statuses,
+		beacon.AttesterStatus{Flags: attester, InclusionDelay: spec.SLOTS_PER_EPOCH},
+		beacon.AttesterStatus{Flags: attester, InclusionDelay: 1000})
+	stats = process.InclusionDelayStats(spec)
+	if stats.MaxDelay != 1000 || beacon.Slot(len(stats.Histogram)) != spec.SLOTS_PER_EPOCH+1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if last := stats.Histogram[spec.SLOTS_PER_EPOCH]; last != 2 {
+		t.Fatalf("expected 2 delays in the last bucket, got %d", last)
+	}
+}
+
+func TestEpochProcessStatusOf(t *testing.T) {
+	process := &beacon.EpochProcess{Statuses: []beacon.AttesterStatus{
+		{Flags: beacon.PrevSourceAttester | beacon.PrevTargetAttester | beacon.UnslashedAttester},
+		{Flags: beacon.PrevSourceAttester | beacon.PrevHeadAttester},
+	}}
+	if status, err := process.StatusOf(1); err != nil {
+		t.Fatal(err)
+	} else if status != &process.Statuses[1] {
+		t.Fatal("expected status of validator 1")
+	}
+	if _, err := process.StatusOf(2); err == nil {
+		t.Fatal("expected error for out of range index")
+	}
+	if ok, err := process.DidAttestTarget(0); err != nil || !ok {
+		t.Fatalf("expected validator 0 to attest target: %v", err)
+	}
+	if ok, err := process.DidAttestHead(0); err != nil || ok {
+		t.Fatalf("expected validator 0 not to attest head: %v", err)
+	}
+	if ok, err := process.DidAttestHead(1); err != nil || !ok {
+		t.Fatalf("expected validator 1 to attest head: %v", err)
+	}
+	if _, err := process.DidAttestTarget(5); err == nil {
+		t.Fatal("expected error for out of range index")
+	}
+}
+
+type stepRecorder struct {
+	names []string
+}
+
+func (r *stepRecorder) OnEpochStep(name string, dur time.Duration) {
+	r.names = append(r.names, name)
+}
+
+func TestProcessEpochObserved(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	if err := spec.ProcessSlots(ctx, epc, state, spec.SLOTS_PER_EPOCH-1); err != nil {
+		t.Fatal(err)
+	}
+	var rec stepRecorder
+	if err := spec.ProcessEpochObserved(ctx, epc, state, &rec); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"epoch_process", "justification_and_finalization", "rewards_and_penalties",
+		"registry_updates", "slashings", "effective_balance_updates", "final_updates"}
+	if len(rec.names) != len(expected) {
+		t.Fatalf("unexpected steps: %v", rec.names)
+	}
+	for i, name := range expected {
+		if rec.names[i] != name {
+			t.Fatalf("unexpected steps: %v", rec.names)
+		}
+	}
+}
+
+func TestEffectiveBalanceHysteresis(t *testing.T) {
+	run := func(spec *beacon.Spec) beacon.Gwei {
+		state, epc := testState(t, spec)
+		// 0.3 ETH below the effective balance: more than the minimal preset downward threshold of 0.25 ETH
+		if err := state.DecreaseBalance(0, 300_000_000); err != nil {
+			t.Fatal(err)
+		}
+		// the genesis epoch has no rewards or penalties, only the balance decrease counts.
+		if err := spec.ProcessSlots(context.Background(), epc, state, spec.SLOTS_PER_EPOCH); err != nil {
+			t.Fatal(err)
+		}
+		vals, err := state.Validators()
+		if err != nil {
+			t.Fatal(err)
+		}
+		val, err := vals.Validator(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		effBal, err := val.EffectiveBalance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return effBal
+	}
+	minimal := configs.Minimal
+	if effBal := run(minimal); effBal != minimal.MAX_EFFECTIVE_BALANCE-minimal.EFFECTIVE_BALANCE_INCREMENT {
+		t.Fatalf("expected effective balance to drop with the minimal preset, got %d", effBal)
+	}
+	// a wider downward threshold of 0.5 ETH keeps the effective balance
+	custom := *configs.Minimal
+	custom.HYSTERESIS_DOWNWARD_MULTIPLIER = 2
+	if effBal := run(&custom); effBal != custom.MAX_EFFECTIVE_BALANCE {
+		t.Fatalf("expected effective balance to be kept with the custom hysteresis, got %d", effBal)
+	}
+}
+
+func TestEffectiveBalanceChanges(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	hysteresisIncrement := spec.EFFECTIVE_BALANCE_INCREMENT / beacon.Gwei(spec.HYSTERESIS_QUOTIENT)
+	downward := hysteresisIncrement * beacon.Gwei(spec.HYSTERESIS_DOWNWARD_MULTIPLIER)
+	upward := hysteresisIncrement * beacon.Gwei(spec.HYSTERESIS_UPWARD_MULTIPLIER)
+	// below the max, so upward changes are not capped
+	lowEffBal := spec.MAX_EFFECTIVE_BALANCE - 2*spec.EFFECTIVE_BALANCE_INCREMENT
+
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []beacon.ValidatorIndex{2, 3} {
+		val, err := vals.Validator(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := val.SetEffectiveBalance(lowEffBal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bals, err := state.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances := map[beacon.ValidatorIndex]beacon.Gwei{
+		0: spec.MAX_EFFECTIVE_BALANCE - downward,     // on the downward threshold: kept
+		1: spec.MAX_EFFECTIVE_BALANCE - downward - 1, // just below the downward threshold: dropped
+		2: lowEffBal + upward,                        // on the upward threshold: kept
+		3: lowEffBal + upward + 1,                    // just above the upward threshold: raised
+	}
+	for i, bal := range balances {
+		if err := bals.SetBalance(i, bal); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	process, err := spec.PrepareEpochProcess(ctx, epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.ProcessEpochFinalUpdates(ctx, epc, process, state); err != nil {
+		t.Fatal(err)
+	}
+	expected := []beacon.EffectiveBalanceChange{
+		{Index: 1, Old: spec.MAX_EFFECTIVE_BALANCE, New: spec.MAX_EFFECTIVE_BALANCE - spec.EFFECTIVE_BALANCE_INCREMENT},
+		{Index: 3, Old: lowEffBal, New: lowEffBal + spec.EFFECTIVE_BALANCE_INCREMENT},
+	}
+	changes := process.EffectiveBalanceChanges()
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("got changes %+v, expected %+v", changes, expected)
+	}
+
+	vals, err = state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, expectedEffBal := range []beacon.Gwei{
+		spec.MAX_EFFECTIVE_BALANCE,
+		spec.MAX_EFFECTIVE_BALANCE - spec.EFFECTIVE_BALANCE_INCREMENT,
+		lowEffBal,
+		lowEffBal + spec.EFFECTIVE_BALANCE_INCREMENT,
+	} {
+		val, err := vals.Validator(beacon.ValidatorIndex(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		effBal, err := val.EffectiveBalance()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if effBal != expectedEffBal {
+			t.Errorf("validator %d: got effective balance %d, expected %d", i, effBal, expectedEffBal)
+		}
+	}
+}
+
+func TestEpochProcessUnknownTarget(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	// At genesis, the target of the current epoch is not known yet.
+	if _, err := spec.PrepareEpochProcess(ctx, epc, state); err != nil {
+		t.Fatal(err)
+	}
+	// Same at the start of the next epoch, while previous epoch targets are known.
+	if err := spec.ProcessSlots(ctx, epc, state, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	headRoot, err := spec.GetBlockRootAtSlot(state, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committee, err := epc.GetBeaconCommittee(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits := make(beacon.CommitteeBits, len(committee)/8+1)
+	bits.SetBit(uint64(len(committee)), true) // delimiter bit
+	bits.SetBit(0, true)
+	// An attestation with a zero target root must not match the unknown target.
+	att := &beacon.PendingAttestation{
+		AggregationBits: bits,
+		Data: beacon.AttestationData{
+			Slot:            1,
+			BeaconBlockRoot: headRoot,
+			Target:          beacon.Checkpoint{Epoch: 1},
+		},
+		InclusionDelay: 1,
+	}
+	currAtts, err := state.CurrentEpochAttestations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := currAtts.Append(att.View(spec)); err != nil {
+		t.Fatal(err)
+	}
+	process, err := spec.PrepareEpochProcess(ctx, epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := process.Statuses[committee[0]].Flags
+	if !flags.HasMarkers(beacon.CurrSourceAttester) {
+		t.Fatal("expected source vote to be credited")
+	}
+	if flags.HasMarkers(beacon.CurrTargetAttester) || flags.HasMarkers(beacon.CurrHeadAttester) {
+		t.Fatal("expected no target and head votes to be credited for an unknown target")
+	}
+}
+
+func TestEpochProcessJSON(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	if err := spec.ProcessSlots(ctx, epc, state, spec.SLOTS_PER_EPOCH*2-1); err != nil {
+		t.Fatal(err)
+	}
+	process, err := spec.PrepareEpochProcess(ctx, epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(process)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	// Every field of the epoch process is included, named in snake case.
+	typ := reflect.TypeOf(beacon.EpochProcess{})
+	for i := 0; i < typ.NumField(); i++ {
+		var name []rune
+		for j, r := range typ.Field(i).Name {
+			if unicode.IsUpper(r) {
+				if j > 0 {
+					name = append(name, '_')
+				}
+				r = unicode.ToLower(r)
+			}
+			name = append(name, r)
+		}
+		key := string(name)
+		if _, ok := fields[key]; !ok {
+			t.Errorf("field %s is missing from the JSON as %q", typ.Field(i).Name, key)
+		}
+	}
+	if string(fields["flag_counts"]) == "{}" {
+		t.Error("expected flag counts")
+	}
+}
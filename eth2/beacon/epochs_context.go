@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -45,9 +46,10 @@ func (spec *Spec) computeProposerIndex(state *BeaconStateView, indices []Validat
 }
 
 type BoundedIndex struct {
-	Index      ValidatorIndex
-	Activation Epoch
-	Exit       Epoch
+	Index            ValidatorIndex
+	Activation       Epoch
+	Exit             Epoch
+	EffectiveBalance Gwei
 }
 
 func (state *BeaconStateView) loadIndicesBounded() ([]BoundedIndex, error) {
@@ -82,16 +84,39 @@ func (state *BeaconStateView) loadIndicesBounded() ([]BoundedIndex, error) {
 		if err != nil {
 			return nil, err
 		}
+		effBal, err := val.EffectiveBalance()
+		if err != nil {
+			return nil, err
+		}
 		indicesBounded[i] = BoundedIndex{
-			Index:      i,
-			Activation: actiEp,
-			Exit:       exitEp,
+			Index:            i,
+			Activation:       actiEp,
+			Exit:             exitEp,
+			EffectiveBalance: effBal,
 		}
 		i++
 	}
 	return indicesBounded, nil
 }
 
+// totalActiveBalance sums the effective balances of the validators active in the given epoch,
+// with a minimum of EFFECTIVE_BALANCE_INCREMENT, like get_total_active_balance in the spec.
+func (spec *Spec) totalActiveBalance(indicesBounded []BoundedIndex, epoch Epoch) (Gwei, error) {
+	total := Gwei(0)
+	for _, v := range indicesBounded {
+		if v.Activation <= epoch && epoch < v.Exit {
+			var err error
+			if total, err = total.SafeAdd(v.EffectiveBalance); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if total < spec.EFFECTIVE_BALANCE_INCREMENT {
+		total = spec.EFFECTIVE_BALANCE_INCREMENT
+	}
+	return total, nil
+}
+
 // PubkeyCache is shared between any state. However, if .Append(index, pubkey) conflicts, a new cache will be forked out.
 type PubkeyCache struct {
 	parent *PubkeyCache
@@ -247,16 +272,180 @@ func (pc *PubkeyCache) AddValidator(index ValidatorIndex, pub BLSPubkey) (*Pubke
 	return pc, nil
 }
 
+// IndexedPubkey is a validator index and its pubkey, for bulk additions to the PubkeyCache.
+type IndexedPubkey struct {
+	Index  ValidatorIndex
+	Pubkey BLSPubkey
+}
+
+// AddBatch adds the pairs, in order, like AddValidator does, but acquires the write lock only once
+// as long as the pairs are new and consecutive, e.g. when initializing the cache at genesis.
+// A fork of the cache, or the same cache, is returned, like AddValidator.
+func (pc *PubkeyCache) AddBatch(pairs []IndexedPubkey) (*PubkeyCache, error) {
+	pc.rwLock.Lock()
+	i := 0
+	for ; i < len(pairs); i++ {
+		p := &pairs[i]
+		if expected := pc.trustedParentCount + ValidatorIndex(len(pc.idx2pub)); p.Index != expected {
+			break
+		}
+		if _, exists := pc.unsafeValidatorIndex(p.Pubkey); exists {
+			break
+		}
+		pc.idx2pub = append(pc.idx2pub, CachedPubkey{Compressed: p.Pubkey})
+		pc.pub2idx[p.Pubkey] = p.Index
+	}
+	pc.rwLock.Unlock()
+	// Known or conflicting pairs are handled one by one.
+	out := pc
+	for ; i < len(pairs); i++ {
+		var err error
+		out, err = out.AddValidator(pairs[i].Index, pairs[i].Pubkey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// PruneExited drops the decompressed pubkeys of the validators that are withdrawable before the given epoch,
+// e.g. the finalized epoch, to bound the memory of the cache to the recently active validators.
+// Withdrawable validators are not part of any committee anymore, and can not be slashed,
+// so their pubkeys are not used by recent attestations and other operations.
+//
+// Only the decompressed form is dropped: the compressed pubkey and the pubkey to index mapping are kept,
+// so deposits of a pruned validator still top up the existing validator, and indices are never reused.
+// If a pruned pubkey is referenced again, it is lazily decompressed again on use.
+// Pubkeys that were already retrieved from the cache are not affected.
+func (pc *PubkeyCache) PruneExited(beforeEpoch Epoch, state *BeaconStateView) error {
+	vals, err := state.Validators()
+	if err != nil {
+		return err
+	}
+	var prune []ValidatorIndex
+	valIter := vals.ReadonlyIter()
+	for i := ValidatorIndex(0); true; i++ {
+		valContainer, ok, err := valIter.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		val, err := AsValidator(valContainer, nil)
+		if err != nil {
+			return err
+		}
+		withdrawableEpoch, err := val.WithdrawableEpoch()
+		if err != nil {
+			return err
+		}
+		if withdrawableEpoch < beforeEpoch {
+			prune = append(prune, i)
+		}
+	}
+	pc.prune(prune)
+	return nil
+}
+
+// prune resets the given entries, in ascending index order, to their compressed form.
+func (pc *PubkeyCache) prune(indices []ValidatorIndex) {
+	if len(indices) == 0 {
+		return
+	}
+	// the entries of the parent are shared with other forks of the cache, but pruning is safe for all of them.
+	split := 0
+	for split < len(indices) && indices[split] < pc.trustedParentCount {
+		split++
+	}
+	if pc.parent != nil {
+		pc.parent.prune(indices[:split])
+	}
+	pc.rwLock.Lock()
+	defer pc.rwLock.Unlock()
+	// Copy instead of modifying in place: pubkeys retrieved earlier point into the previous entries.
+	next := make([]CachedPubkey, len(pc.idx2pub), len(pc.idx2pub))
+	copy(next, pc.idx2pub)
+	for _, index := range indices[split:] {
+		i := index - pc.trustedParentCount
+		if i >= ValidatorIndex(len(next)) {
+			break
+		}
+		next[i] = CachedPubkey{Compressed: next[i].Compressed}
+	}
+	pc.idx2pub = next
+}
+
+// EpochsContext caches the shuffling, proposers and pubkeys for the processing of a state.
+//
+// The caches are never modified in place, an update replaces them: this makes a Clone cheap,
+// as clones share the caches until either of them rotates to the next epoch.
 type EpochsContext struct {
 	Spec *Spec
 	// PubkeyCache may be replaced when a new forked-out cache takes over to process an alternative Eth1 deposit chain.
+	// Safe to share: it is append-only, and forks out a new cache on conflicting entries.
 	PubkeyCache *PubkeyCache
-	// Proposers is a slice of SLOTS_PER_EPOCH proposer indices for the current epoch
+	// Proposers is a slice of SLOTS_PER_EPOCH proposer indices for the current epoch.
+	// Safe to share: a new slice is allocated for each epoch.
 	Proposers []ValidatorIndex
 
+	// Safe to share: a shuffling is immutable after construction, except for its lazily computed attester duties,
+	// which are synchronized. Epoch rotation replaces the pointers.
 	PreviousEpoch *ShufflingEpoch
 	CurrentEpoch  *ShufflingEpoch
 	NextEpoch     *ShufflingEpoch
+
+	// Total active balances of the current and previous epoch, see TotalActiveBalance. Zero if unknown.
+	// Both are computed when the shuffling is loaded or rotated, with the effective balances of that state.
+	// The epoch transition updates the effective balances: the previous total is recomputed, not carried over.
+	currentTotalActiveBalance  Gwei
+	previousTotalActiveBalance Gwei
+
+	// Domains caches signature domains, populated lazily by GetDomain. Domains are not cached if nil.
+	// Safe to share: it is synchronized, and epoch rotation replaces it.
+	Domains *DomainCache
+}
+
+type domainKey struct {
+	typ   BLSDomainType
+	epoch Epoch
+}
+
+// DomainCache caches signature domains by domain type and message epoch.
+// The genesis validators root is constant, and the fork only changes at an epoch transition,
+// so within an epoch the domain of a message is determined by its type and epoch.
+type DomainCache struct {
+	lock    sync.RWMutex
+	domains map[domainKey]BLSDomain
+}
+
+func newDomainCache() *DomainCache {
+	return &DomainCache{domains: make(map[domainKey]BLSDomain)}
+}
+
+// GetDomain returns the signature domain of a message, like BeaconStateView.GetDomain,
+// but computes each domain only once per epoch.
+func (epc *EpochsContext) GetDomain(state *BeaconStateView, typ BLSDomainType, messageEpoch Epoch) (BLSDomain, error) {
+	cache := epc.Domains
+	// Not assigned lazily: the epochs context may be shared between goroutines.
+	if cache == nil {
+		return state.GetDomain(typ, messageEpoch)
+	}
+	key := domainKey{typ: typ, epoch: messageEpoch}
+	cache.lock.RLock()
+	dom, ok := cache.domains[key]
+	cache.lock.RUnlock()
+	if ok {
+		return dom, nil
+	}
+	dom, err := state.GetDomain(typ, messageEpoch)
+	if err != nil {
+		return BLSDomain{}, err
+	}
+	cache.lock.Lock()
+	cache.domains[key] = dom
+	cache.lock.Unlock()
+	return dom, nil
 }
 
 // NewEpochsContext constructs a new context for the processing of the current epoch.
@@ -268,6 +457,7 @@ func (spec *Spec) NewEpochsContext(state *BeaconStateView) (*EpochsContext, erro
 	epc := &EpochsContext{
 		Spec:        spec,
 		PubkeyCache: pc,
+		Domains:     newDomainCache(),
 	}
 	if err := epc.LoadShuffling(state); err != nil {
 		return nil, err
@@ -292,7 +482,15 @@ func (epc *EpochsContext) LoadShuffling(state *BeaconStateView) error {
 	if err != nil {
 		return err
 	}
+	epc.currentTotalActiveBalance, err = epc.Spec.totalActiveBalance(indicesBounded, currentEpoch)
+	if err != nil {
+		return err
+	}
 	prevEpoch := currentEpoch.Previous()
+	epc.previousTotalActiveBalance, err = epc.Spec.totalActiveBalance(indicesBounded, prevEpoch)
+	if err != nil {
+		return err
+	}
 	if prevEpoch == currentEpoch { // in case of genesis
 		epc.PreviousEpoch = epc.CurrentEpoch
 	} else {
@@ -352,13 +550,24 @@ func (epc *EpochsContext) resetProposers(state *BeaconStateView) error {
 	return nil
 }
 
+// Clone returns a copy of the epochs context, sharing all caches with the original.
+// Cloning allocates only the outer struct, to hold many speculative states in memory cheaply.
 func (epc *EpochsContext) Clone() *EpochsContext {
 	// All fields can be reused, just need a fresh shallow copy of the outer container
 	epcClone := *epc
+	if epcClone.Domains == nil {
+		epcClone.Domains = newDomainCache()
+	}
 	return &epcClone
 }
 
 func (epc *EpochsContext) RotateEpochs(state *BeaconStateView) error {
+	return epc.RotateEpochsCtx(context.Background(), state)
+}
+
+// RotateEpochsCtx is like RotateEpochs, but the shuffling of the next epoch can be cancelled with the context.
+// If cancelled, the epochs context is left in an inconsistent state, and should not be used anymore.
+func (epc *EpochsContext) RotateEpochsCtx(ctx context.Context, state *BeaconStateView) error {
 	epc.PreviousEpoch = epc.CurrentEpoch
 	epc.CurrentEpoch = epc.NextEpoch
 	nextEpoch := epc.CurrentEpoch.Epoch + 1
@@ -367,13 +576,45 @@ func (epc *EpochsContext) RotateEpochs(state *BeaconStateView) error {
 	if err != nil {
 		return err
 	}
-	epc.NextEpoch, err = epc.Spec.ShufflingEpoch(state, indicesBounded, nextEpoch)
+	// The effective balances may have changed in the epoch transition, the previous total is summed again.
+	epc.previousTotalActiveBalance, err = epc.Spec.totalActiveBalance(indicesBounded, epc.PreviousEpoch.Epoch)
+	if err != nil {
+		return err
+	}
+	epc.currentTotalActiveBalance, err = epc.Spec.totalActiveBalance(indicesBounded, epc.CurrentEpoch.Epoch)
 	if err != nil {
 		return err
 	}
+	epc.NextEpoch, err = epc.Spec.ShufflingEpochCtx(ctx, state, indicesBounded, nextEpoch)
+	if err != nil {
+		return err
+	}
+	// The fork may change at the epoch transition, start with a fresh domain cache.
+	epc.Domains = newDomainCache()
 	return epc.resetProposers(state)
 }
 
+// PrecomputeShuffling warms the caches of the given epoch, to avoid a latency spike on first use,
+// e.g. for the next epoch, during idle time before the epoch boundary.
+// The shufflings of the previous, current and next epoch are computed when loaded or rotated,
+// this computes the remaining lazily computed parts, like the attester duties lookup.
+// It is safe to call concurrently with reads of the epochs context, but not with epoch rotation.
+// If cancelled, the caches are left as before, and are computed on first use instead.
+func (epc *EpochsContext) PrecomputeShuffling(ctx context.Context, epoch Epoch) error {
+	var shep *ShufflingEpoch
+	switch epoch {
+	case epc.PreviousEpoch.Epoch:
+		shep = epc.PreviousEpoch
+	case epc.CurrentEpoch.Epoch:
+		shep = epc.CurrentEpoch
+	case epc.NextEpoch.Epoch:
+		shep = epc.NextEpoch
+	default:
+		return fmt.Errorf("cannot precompute shuffling of out of range epoch: %d", epoch)
+	}
+	return shep.PrecomputeDuties(ctx)
+}
+
 func (epc *EpochsContext) getSlotComms(slot Slot) ([][]ValidatorIndex, error) {
 	epoch := epc.Spec.SlotToEpoch(slot)
 	epochSlot := slot % epc.Spec.SLOTS_PER_EPOCH
@@ -405,11 +646,73 @@ func (epc *EpochsContext) GetBeaconCommittee(slot Slot, index CommitteeIndex) ([
 	return slotComms[index], nil
 }
 
+// GetBeaconCommitteeCtx is like GetBeaconCommittee, but returns TransitionCancelErr if the context is cancelled,
+// to stop loops over many committees early.
+func (epc *EpochsContext) GetBeaconCommitteeCtx(ctx context.Context, slot Slot, index CommitteeIndex) ([]ValidatorIndex, error) {
+	select {
+	case <-ctx.Done():
+		return nil, TransitionCancelErr
+	default: // Don't block.
+		break
+	}
+	return epc.GetBeaconCommittee(slot, index)
+}
+
+// GetAttesterDuty returns the slot, committee index and position within the committee that the validator
+// is assigned to attest in, during the given epoch. The epoch must be the previous, current or next epoch.
+// Ok is false if the validator is not active in the epoch.
+// The first lookup of an epoch iterates all its committees, later lookups of the same epoch are O(1).
+func (epc *EpochsContext) GetAttesterDuty(index ValidatorIndex, epoch Epoch) (slot Slot, committeeIndex CommitteeIndex, positionInCommittee uint64, ok bool, err error) {
+	var shep *ShufflingEpoch
+	if epoch == epc.PreviousEpoch.Epoch {
+		shep = epc.PreviousEpoch
+	} else if epoch == epc.CurrentEpoch.Epoch {
+		shep = epc.CurrentEpoch
+	} else if epoch == epc.NextEpoch.Epoch {
+		shep = epc.NextEpoch
+	} else {
+		return 0, 0, 0, false, fmt.Errorf("attester duty lookup: out of range epoch: %d", epoch)
+	}
+	start, err := epc.Spec.EpochStartSlot(epoch)
+	if err != nil {
+		return 0, 0, 0, false, err
+	}
+	offset, committeeIndex, positionInCommittee, ok := shep.AttesterDuty(index)
+	if !ok {
+		return 0, 0, 0, false, nil
+	}
+	return start + offset, committeeIndex, positionInCommittee, true, nil
+}
+
 func (epc *EpochsContext) GetCommitteeCountAtSlot(slot Slot) (uint64, error) {
 	slotComms, err := epc.getSlotComms(slot)
 	return uint64(len(slotComms)), err
 }
 
+// CommitteesPerSlot returns the number of committees per slot in the given epoch,
+// like get_committee_count_per_slot in the spec. The epoch must be within the epochs of the context.
+func (epc *EpochsContext) CommitteesPerSlot(epoch Epoch) (uint64, error) {
+	start, err := epc.Spec.EpochStartSlot(epoch)
+	if err != nil {
+		return 0, err
+	}
+	return epc.GetCommitteeCountAtSlot(start)
+}
+
+// TotalActiveBalance returns the total effective balance of the validators active in the given epoch,
+// with a minimum of EFFECTIVE_BALANCE_INCREMENT, without scanning the registry.
+// Like get_total_balance(state, get_active_validator_indices(state, epoch)) in the spec, the current
+// effective balances are summed, also for the previous epoch. Only the current and previous epoch are available.
+func (epc *EpochsContext) TotalActiveBalance(epoch Epoch) (Gwei, error) {
+	if epoch == epc.CurrentEpoch.Epoch && epc.currentTotalActiveBalance != 0 {
+		return epc.currentTotalActiveBalance, nil
+	}
+	if epoch == epc.PreviousEpoch.Epoch && epc.previousTotalActiveBalance != 0 {
+		return epc.previousTotalActiveBalance, nil
+	}
+	return 0, fmt.Errorf("total active balance of epoch %d is not available", epoch)
+}
+
 func (epc *EpochsContext) GetBeaconProposer(slot Slot) (ValidatorIndex, error) {
 	epoch := epc.Spec.SlotToEpoch(slot)
 	if epoch != epc.CurrentEpoch.Epoch {
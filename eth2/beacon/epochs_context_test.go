@@ -0,0 +1,348 @@
+package beacon_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"sync"
+	"testing"
+)
+
+func TestGetAttesterDuty(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	_, epc := testState(t, spec)
+	epoch := epc.NextEpoch.Epoch
+	for i := range validators {
+		index := beacon.ValidatorIndex(i)
+		slot, committeeIndex, position, ok, err := epc.GetAttesterDuty(index, epoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			t.Fatalf("validator %d has no duty", i)
+		}
+		if spec.SlotToEpoch(slot) != epoch {
+			t.Fatalf("validator %d has duty at slot %d, outside of epoch %d", i, slot, epoch)
+		}
+		committee, err := epc.GetBeaconCommittee(slot, committeeIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if position >= uint64(len(committee)) || committee[position] != index {
+			t.Fatalf("validator %d is not at position %d of committee %d at slot %d", i, position, committeeIndex, slot)
+		}
+	}
+	if _, _, _, ok, err := epc.GetAttesterDuty(beacon.ValidatorIndex(len(validators)), epoch); err != nil || ok {
+		t.Fatalf("expected no duty for unknown validator, got ok: %v, err: %v", ok, err)
+	}
+	if _, _, _, _, err := epc.GetAttesterDuty(0, epoch+1); err == nil {
+		t.Fatal("expected error for out of range epoch")
+	}
+}
+
+func TestEpochsContextClone(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	clone := epc.Clone()
+	if clone.CurrentEpoch != epc.CurrentEpoch || clone.PubkeyCache != epc.PubkeyCache || &clone.Proposers[0] != &epc.Proposers[0] {
+		t.Fatal("expected clone to share the caches")
+	}
+	current := epc.CurrentEpoch
+	proposers := append([]beacon.ValidatorIndex(nil), epc.Proposers...)
+	if err := spec.ProcessSlots(context.Background(), clone, state, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	if epc.CurrentEpoch != current || clone.PreviousEpoch != current {
+		t.Fatal("unexpected shuffling after rotation of clone")
+	}
+	for i, p := range proposers {
+		if epc.Proposers[i] != p {
+			t.Fatal("rotation of clone changed the proposers of the original")
+		}
+	}
+}
+
+func TestPubkeyCacheAddBatch(t *testing.T) {
+	pairs := make([]beacon.IndexedPubkey, 10)
+	for i := range pairs {
+		pairs[i].Index = beacon.ValidatorIndex(i)
+		pairs[i].Pubkey[0] = byte(i + 1)
+	}
+	pc, err := beacon.EmptyPubkeyCache().AddBatch(pairs[:6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Overlapping with the known pairs is fine.
+	if next, err := pc.AddBatch(pairs[4:]); err != nil {
+		t.Fatal(err)
+	} else if next != pc {
+		t.Fatal("expected the same cache for consecutive pairs")
+	}
+	for _, p := range pairs {
+		if pub, ok := pc.Pubkey(p.Index); !ok || pub.Compressed != p.Pubkey {
+			t.Fatalf("bad pubkey of index %d", p.Index)
+		}
+		if index, ok := pc.ValidatorIndex(p.Pubkey); !ok || index != p.Index {
+			t.Fatalf("bad index of pubkey %x", p.Pubkey)
+		}
+	}
+	// A conflicting pubkey forks out a new cache, the original cache is not changed.
+	conflict := []beacon.IndexedPubkey{{Index: 7, Pubkey: beacon.BLSPubkey{0xff}}}
+	forked, err := pc.AddBatch(conflict)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forked == pc {
+		t.Fatal("expected a forked cache")
+	}
+	if index, ok := forked.ValidatorIndex(beacon.BLSPubkey{0xff}); !ok || index != 7 {
+		t.Fatal("forked cache is missing the new pubkey")
+	}
+	if _, ok := forked.Pubkey(8); ok {
+		t.Fatal("forked cache should not trust the pubkeys after the conflict")
+	}
+	if pub, ok := pc.Pubkey(7); !ok || pub.Compressed != pairs[7].Pubkey {
+		t.Fatal("original cache changed")
+	}
+	if _, err := beacon.EmptyPubkeyCache().AddBatch(pairs[1:]); err == nil {
+		t.Fatal("expected error for missing index")
+	}
+}
+
+func TestEpochsContextGetDomain(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	for _, typ := range []beacon.BLSDomainType{spec.DOMAIN_BEACON_ATTESTER, spec.DOMAIN_VOLUNTARY_EXIT} {
+		expected, err := state.GetDomain(typ, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the second call is served from the cache
+		for i := 0; i < 2; i++ {
+			dom, err := epc.GetDomain(state, typ, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dom != expected {
+				t.Fatalf("domain %x of type %x does not match state domain %x", dom, typ, expected)
+			}
+		}
+	}
+	cache := epc.Domains
+	if err := spec.ProcessSlots(context.Background(), epc, state, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	if epc.Domains == cache {
+		t.Fatal("expected epoch rotation to replace the domain cache")
+	}
+}
+
+func TestEpochsContextGetDomainShared(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	expected, err := state.GetDomain(spec.DOMAIN_BEACON_ATTESTER, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A context without domain cache, e.g. constructed by hand, is shared between goroutines.
+	shared := *epc
+	shared.Domains = nil
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dom, err := shared.GetDomain(state, spec.DOMAIN_BEACON_ATTESTER, 0)
+			if err == nil && dom != expected {
+				err = fmt.Errorf("domain %x does not match state domain %x", dom, expected)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if shared.Domains != nil {
+		t.Fatal("expected GetDomain to not assign a domain cache")
+	}
+	if shared.Clone().Domains == nil {
+		t.Fatal("expected clone to have a domain cache")
+	}
+}
+
+func TestComputeSubnetForAttestation(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	_, epc := testState(t, spec)
+	epoch := epc.NextEpoch.Epoch
+	committeesPerSlot, err := epc.CommitteesPerSlot(epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := spec.CommitteeCount(uint64(len(validators))); committeesPerSlot != expected {
+		t.Fatalf("expected %d committees per slot, got %d", expected, committeesPerSlot)
+	}
+	if _, err := epc.CommitteesPerSlot(epoch + 1); err == nil {
+		t.Fatal("expected error for out of range epoch")
+	}
+	start, err := spec.EpochStartSlot(epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// committees of an epoch are spread over consecutive subnets
+	expected := uint64(0)
+	for slot := start; slot < start+spec.SLOTS_PER_EPOCH; slot++ {
+		for index := uint64(0); index < committeesPerSlot; index++ {
+			subnet, err := spec.ComputeSubnetForAttestation(committeesPerSlot, slot, beacon.CommitteeIndex(index))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if subnet != expected%beacon.ATTESTATION_SUBNET_COUNT {
+				t.Fatalf("slot %d committee %d: expected subnet %d, got %d", slot, index, expected, subnet)
+			}
+			expected++
+		}
+	}
+	if _, err := spec.ComputeSubnetForAttestation(committeesPerSlot, start, beacon.CommitteeIndex(committeesPerSlot)); err == nil {
+		t.Fatal("expected error for out of range committee index")
+	}
+}
+
+func TestPrecomputeShuffling(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	_, epc := testState(t, spec)
+	epoch := epc.NextEpoch.Epoch
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := epc.PrecomputeShuffling(cancelled, epoch); err != beacon.TransitionCancelErr {
+		t.Fatalf("expected cancel error, got: %v", err)
+	}
+	if err := epc.PrecomputeShuffling(context.Background(), epoch); err != nil {
+		t.Fatal(err)
+	}
+	if err := epc.PrecomputeShuffling(context.Background(), epoch+1); err == nil {
+		t.Fatal("expected error for out of range epoch")
+	}
+	// the precomputed duties are used for lookups
+	for i := range validators {
+		if _, _, _, ok, err := epc.GetAttesterDuty(beacon.ValidatorIndex(i), epoch); err != nil || !ok {
+			t.Fatalf("validator %d has no duty after precompute: ok: %v, err: %v", i, ok, err)
+		}
+	}
+}
+
+func TestTotalActiveBalance(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	expected := spec.MAX_EFFECTIVE_BALANCE * beacon.Gwei(len(validators))
+	if total, err := epc.TotalActiveBalance(0); err != nil || total != expected {
+		t.Fatalf("expected total %d, got %d (err: %v)", expected, total, err)
+	}
+	if _, err := epc.TotalActiveBalance(1); err == nil {
+		t.Fatal("expected next epoch total to be unavailable")
+	}
+	// exit a validator, the total of the current epoch is kept until the exit epoch is reached
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := vals.Validator(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := val.SetExitEpoch(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.ProcessSlots(context.Background(), epc, state, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	if total, err := epc.TotalActiveBalance(0); err != nil || total != expected {
+		t.Fatalf("expected previous total %d, got %d (err: %v)", expected, total, err)
+	}
+	expected -= spec.MAX_EFFECTIVE_BALANCE
+	if total, err := epc.TotalActiveBalance(1); err != nil || total != expected {
+		t.Fatalf("expected current total %d, got %d (err: %v)", expected, total, err)
+	}
+}
+
+func TestTotalActiveBalanceAfterEffectiveBalanceChange(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	// the final updates of the epoch transition lower the effective balance by 2 ETH
+	if err := state.DecreaseBalance(0, 2*spec.EFFECTIVE_BALANCE_INCREMENT); err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.ProcessSlots(context.Background(), epc, state, spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	vals, err := state.FlatValidators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals[0].EffectiveBalance != spec.MAX_EFFECTIVE_BALANCE-2*spec.EFFECTIVE_BALANCE_INCREMENT {
+		t.Fatalf("expected effective balance update, got %d", vals[0].EffectiveBalance)
+	}
+	expected := spec.MAX_EFFECTIVE_BALANCE*beacon.Gwei(len(validators)) - 2*spec.EFFECTIVE_BALANCE_INCREMENT
+	for _, epoch := range []beacon.Epoch{epc.PreviousEpoch.Epoch, epc.CurrentEpoch.Epoch} {
+		// get_total_balance(state, get_active_validator_indices(state, epoch)), with the rotated state
+		direct := beacon.Gwei(0)
+		for i := range vals {
+			if vals[i].IsActive(epoch) {
+				direct += vals[i].EffectiveBalance
+			}
+		}
+		if direct != expected {
+			t.Fatalf("epoch %d: expected direct sum %d, got %d", epoch, expected, direct)
+		}
+		if total, err := epc.TotalActiveBalance(epoch); err != nil || total != direct {
+			t.Fatalf("epoch %d: expected total %d, got %d (err: %v)", epoch, direct, total, err)
+		}
+	}
+}
+
+func TestGetIndexedAttestation(t *testing.T) {
+	spec := configs.Minimal
+	_, epc := testState(t, spec)
+	committee, err := epc.GetBeaconCommittee(2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := uint64(len(committee))
+	bits := make(beacon.CommitteeBits, n/8+1)
+	bits.SetBit(n, true) // delimiter bit
+	bits.SetBit(0, true)
+	bits.SetBit(n-1, true)
+	att := &beacon.Attestation{AggregationBits: bits, Data: beacon.AttestationData{Slot: 2, Index: 1}}
+	indexed, err := spec.GetIndexedAttestation(epc, att)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := committee[0], committee[n-1]
+	if a > b {
+		a, b = b, a
+	}
+	if len(indexed.AttestingIndices) != 2 || indexed.AttestingIndices[0] != a || indexed.AttestingIndices[1] != b {
+		t.Fatalf("expected sorted participants %d and %d, got %v", a, b, indexed.AttestingIndices)
+	}
+	if indexed.Data != att.Data {
+		t.Fatal("expected attestation data to be copied")
+	}
+	att.AggregationBits = beacon.CommitteeBits{0x01}
+	if _, err := spec.GetIndexedAttestation(epc, att); err == nil {
+		t.Fatal("expected error for bits not matching the committee size")
+	}
+	att.Data.Index = beacon.CommitteeIndex(spec.MAX_COMMITTEES_PER_SLOT)
+	if _, err := spec.GetIndexedAttestation(epc, att); err == nil {
+		t.Fatal("expected error for out of range committee index")
+	}
+}
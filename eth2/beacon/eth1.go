@@ -98,6 +98,26 @@ func (v *Eth1DataView) DepositIndex() (DepositIndex, error) {
 	return AsDepositIndex(v.Get(2))
 }
 
+func (v *Eth1DataView) BlockHash() (Root, error) {
+	return AsRoot(v.Get(2))
+}
+
+func (v *Eth1DataView) Raw() (Eth1Data, error) {
+	depRoot, err := v.DepositRoot()
+	if err != nil {
+		return Eth1Data{}, err
+	}
+	depCount, err := v.DepositCount()
+	if err != nil {
+		return Eth1Data{}, err
+	}
+	blockHash, err := v.BlockHash()
+	if err != nil {
+		return Eth1Data{}, err
+	}
+	return Eth1Data{DepositRoot: depRoot, DepositCount: depCount, BlockHash: blockHash}, nil
+}
+
 type Eth1DataVotes []Eth1Data
 
 func (a *Eth1DataVotes) Deserialize(spec *Spec, dr *codec.DecodingReader) error {
@@ -152,6 +172,64 @@ func (c *Phase0Config) ResetEth1Votes(state *BeaconStateView) error {
 	return votes.SetBacking(c.Eth1DataVotes().DefaultNode())
 }
 
+// ProcessEth1DataReset clears the eth1 data votes if the next epoch starts a new voting period.
+func (spec *Spec) ProcessEth1DataReset(state *BeaconStateView) error {
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	nextEpoch := spec.SlotToEpoch(slot) + 1
+	if nextEpoch%spec.EPOCHS_PER_ETH1_VOTING_PERIOD == 0 {
+		return spec.ResetEth1Votes(state)
+	}
+	return nil
+}
+
+// Eth1DataMajority tallies the eth1 data votes of the current voting period,
+// and returns the vote with more than half of the period slots as votes, if any.
+// There can only be one such vote: with no votes, or a tie, no majority is returned.
+func (state *BeaconStateView) Eth1DataMajority() (Eth1Data, bool, error) {
+	votes, err := state.Eth1DataVotes()
+	if err != nil {
+		return Eth1Data{}, false, err
+	}
+	period := votes.Limit()
+	voteCount, err := votes.Length()
+	if err != nil {
+		return Eth1Data{}, false, err
+	}
+	// a majority needs strictly more than half of the period, exactly half is a possible tie.
+	if voteCount<<1 <= period {
+		return Eth1Data{}, false, nil
+	}
+	counts := make(map[Root]uint64)
+	hFn := tree.GetHashFn()
+	iter := votes.ReadonlyIter()
+	for {
+		el, ok, err := iter.Next()
+		if err != nil {
+			return Eth1Data{}, false, err
+		}
+		if !ok {
+			break
+		}
+		vote, err := AsEth1Data(el, nil)
+		if err != nil {
+			return Eth1Data{}, false, err
+		}
+		root := vote.HashTreeRoot(hFn)
+		counts[root] += 1
+		if counts[root]<<1 > period {
+			data, err := vote.Raw()
+			if err != nil {
+				return Eth1Data{}, false, err
+			}
+			return data, true, nil
+		}
+	}
+	return Eth1Data{}, false, nil
+}
+
 func (spec *Spec) ProcessEth1Vote(ctx context.Context, epc *EpochsContext, state *BeaconStateView, data Eth1Data) error {
 	select {
 	case <-ctx.Done():
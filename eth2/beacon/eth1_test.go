@@ -0,0 +1,68 @@
+package beacon_test
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestEth1DataMajority(t *testing.T) {
+	spec := configs.Minimal
+	state, _ := testState(t, spec)
+	period := int(uint64(spec.EPOCHS_PER_ETH1_VOTING_PERIOD) * uint64(spec.SLOTS_PER_EPOCH))
+	a := beacon.Eth1Data{DepositRoot: beacon.Root{1}, DepositCount: 64, BlockHash: beacon.Root{2}}
+	b := beacon.Eth1Data{DepositRoot: beacon.Root{3}, DepositCount: 65, BlockHash: beacon.Root{4}}
+	votes, err := state.Eth1DataVotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMajority := func(name string, expected *beacon.Eth1Data) {
+		data, ok, err := state.Eth1DataMajority()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			if ok {
+				t.Fatalf("%s: expected no majority, got %v", name, data)
+			}
+		} else if !ok || data != *expected {
+			t.Fatalf("%s: expected majority %v, got %v (ok: %v)", name, *expected, data, ok)
+		}
+	}
+	expectMajority("empty", nil)
+	for i := 0; i < period/2; i++ {
+		if err := votes.Append(a.View()); err != nil {
+			t.Fatal(err)
+		}
+		if err := votes.Append(b.View()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectMajority("tie", nil)
+	// replace a vote for b with a vote for a, to give a a majority.
+	if err := votes.Set(1, a.View()); err != nil {
+		t.Fatal(err)
+	}
+	expectMajority("majority", &a)
+
+	// at genesis the next epoch does not start a new voting period, the votes are kept.
+	if err := spec.ProcessEth1DataReset(state); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := votes.Length(); err != nil || count != uint64(period) {
+		t.Fatalf("expected votes to be kept, got %d (err: %v)", count, err)
+	}
+	if err := state.SetSlot(beacon.Slot(spec.EPOCHS_PER_ETH1_VOTING_PERIOD-1) * spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.ProcessEth1DataReset(state); err != nil {
+		t.Fatal(err)
+	}
+	votes, err = state.Eth1DataVotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := votes.Length(); err != nil || count != 0 {
+		t.Fatalf("expected votes to be reset, got %d (err: %v)", count, err)
+	}
+}
@@ -3,6 +3,11 @@ package beacon
 import "context"
 
 func (spec *Spec) ProcessEpochFinalUpdates(ctx context.Context, epc *EpochsContext, process *EpochProcess, state *BeaconStateView) error {
+	return spec.processEpochFinalUpdates(ctx, epc, process, state, nil)
+}
+
+// processEpochFinalUpdates calls observe, if not nil, after the effective balance updates.
+func (spec *Spec) processEpochFinalUpdates(ctx context.Context, epc *EpochsContext, process *EpochProcess, state *BeaconStateView, observe func(name string)) error {
 	select {
 	case <-ctx.Done():
 		return TransitionCancelErr
@@ -12,10 +17,8 @@ func (spec *Spec) ProcessEpochFinalUpdates(ctx context.Context, epc *EpochsConte
 	nextEpoch := epc.NextEpoch.Epoch
 
 	// Reset eth1 data votes if it is the end of the voting period.
-	if nextEpoch%spec.EPOCHS_PER_ETH1_VOTING_PERIOD == 0 {
-		if err := spec.ResetEth1Votes(state); err != nil {
-			return err
-		}
+	if err := spec.ProcessEth1DataReset(state); err != nil {
+		return err
 	}
 
 	// update effective balances
@@ -24,6 +27,8 @@ func (spec *Spec) ProcessEpochFinalUpdates(ctx context.Context, epc *EpochsConte
 		DOWNWARD_THRESHOLD := HYSTERESIS_INCREMENT * Gwei(spec.HYSTERESIS_DOWNWARD_MULTIPLIER)
 		UPWARD_THRESHOLD := HYSTERESIS_INCREMENT * Gwei(spec.HYSTERESIS_UPWARD_MULTIPLIER)
 
+		process.effectiveBalanceChanges = process.effectiveBalanceChanges[:0]
+
 		vals, err := state.Validators()
 		if err != nil {
 			return err
@@ -32,19 +37,15 @@ func (spec *Spec) ProcessEpochFinalUpdates(ctx context.Context, epc *EpochsConte
 		if err != nil {
 			return err
 		}
-		balIter := bals.ReadonlyIter()
-		for i := ValidatorIndex(0); true; i++ {
-			el, ok, err := balIter.Next()
+		balIter := bals.IterBalances()
+		for {
+			i, balance, ok, err := balIter.Next()
 			if err != nil {
 				return err
 			}
 			if !ok {
 				break
 			}
-			balance, err := AsGwei(el, nil)
-			if err != nil {
-				return err
-			}
 			effBalance := process.Statuses[i].Validator.EffectiveBalance
 			if balance+DOWNWARD_THRESHOLD < effBalance || effBalance+UPWARD_THRESHOLD < balance {
 				effBalance = balance - (balance % spec.EFFECTIVE_BALANCE_INCREMENT)
@@ -58,9 +59,17 @@ func (spec *Spec) ProcessEpochFinalUpdates(ctx context.Context, epc *EpochsConte
 				if err := val.SetEffectiveBalance(effBalance); err != nil {
 					return err
 				}
+				process.effectiveBalanceChanges = append(process.effectiveBalanceChanges, EffectiveBalanceChange{
+					Index: i,
+					Old:   process.Statuses[i].Validator.EffectiveBalance,
+					New:   effBalance,
+				})
 			}
 		}
 	}
+	if observe != nil {
+		observe("effective_balance_updates")
+	}
 
 	slashings, err := state.Slashings()
 	if err != nil {
@@ -0,0 +1,74 @@
+package beacon
+
+// FuzzSpec returns a phase0 spec with tiny, but self-consistent, constants: small epochs, committees,
+// registry and history vectors, to hit edge cases of the state transition cheaply, e.g. when fuzzing.
+// The registry limit matches the maximum number of validators the committees can hold.
+// Not compatible with any network, the phase1 constants are left empty.
+func FuzzSpec() *Spec {
+	const (
+		slotsPerEpoch             = 4
+		maxCommitteesPerSlot      = 2
+		maxValidatorsPerCommittee = 16
+	)
+	return &Spec{
+		CONFIG_NAME: "fuzz",
+		Phase0Config: Phase0Config{
+			MAX_COMMITTEES_PER_SLOT:               maxCommitteesPerSlot,
+			TARGET_COMMITTEE_SIZE:                 2,
+			MAX_VALIDATORS_PER_COMMITTEE:          maxValidatorsPerCommittee,
+			MIN_PER_EPOCH_CHURN_LIMIT:             1,
+			CHURN_LIMIT_QUOTIENT:                  8,
+			SHUFFLE_ROUND_COUNT:                   4,
+			MIN_GENESIS_ACTIVE_VALIDATOR_COUNT:    8,
+			MIN_GENESIS_TIME:                      1578009600,
+			HYSTERESIS_QUOTIENT:                   4,
+			HYSTERESIS_DOWNWARD_MULTIPLIER:        1,
+			HYSTERESIS_UPWARD_MULTIPLIER:          5,
+			SAFE_SLOTS_TO_UPDATE_JUSTIFIED:        2,
+			ETH1_FOLLOW_DISTANCE:                  4,
+			TARGET_AGGREGATORS_PER_COMMITTEE:      2,
+			RANDOM_SUBNETS_PER_VALIDATOR:          1,
+			EPOCHS_PER_RANDOM_SUBNET_SUBSCRIPTION: 4,
+			SECONDS_PER_ETH1_BLOCK:                1,
+			MIN_DEPOSIT_AMOUNT:                    1_000_000_000,
+			MAX_EFFECTIVE_BALANCE:                 32_000_000_000,
+			EJECTION_BALANCE:                      16_000_000_000,
+			EFFECTIVE_BALANCE_INCREMENT:           1_000_000_000,
+			GENESIS_FORK_VERSION:                  Version{0x00, 0x00, 0x00, 0xff},
+			BLS_WITHDRAWAL_PREFIX:                 [1]byte{0x00},
+			GENESIS_DELAY:                         0,
+			SECONDS_PER_SLOT:                      1,
+			MIN_ATTESTATION_INCLUSION_DELAY:       1,
+			SLOTS_PER_EPOCH:                       slotsPerEpoch,
+			MIN_SEED_LOOKAHEAD:                    1,
+			MAX_SEED_LOOKAHEAD:                    2,
+			EPOCHS_PER_ETH1_VOTING_PERIOD:         2,
+			SLOTS_PER_HISTORICAL_ROOT:             4 * slotsPerEpoch,
+			MIN_VALIDATOR_WITHDRAWABILITY_DELAY:   4,
+			SHARD_COMMITTEE_PERIOD:                4,
+			MIN_EPOCHS_TO_INACTIVITY_PENALTY:      4,
+			EPOCHS_PER_HISTORICAL_VECTOR:          16,
+			EPOCHS_PER_SLASHINGS_VECTOR:           8,
+			HISTORICAL_ROOTS_LIMIT:                16,
+			VALIDATOR_REGISTRY_LIMIT:              slotsPerEpoch * maxCommitteesPerSlot * maxValidatorsPerCommittee,
+			BASE_REWARD_FACTOR:                    64,
+			WHISTLEBLOWER_REWARD_QUOTIENT:         512,
+			PROPOSER_REWARD_QUOTIENT:              8,
+			INACTIVITY_PENALTY_QUOTIENT:           1 << 25,
+			MIN_SLASHING_PENALTY_QUOTIENT:         64,
+			PROPORTIONAL_SLASHING_MULTIPLIER:      2,
+			MAX_PROPOSER_SLASHINGS:                2,
+			MAX_ATTESTER_SLASHINGS:                1,
+			MAX_ATTESTATIONS:                      8,
+			MAX_DEPOSITS:                          4,
+			MAX_VOLUNTARY_EXITS:                   4,
+			DOMAIN_BEACON_PROPOSER:                BLSDomainType{0x00, 0x00, 0x00, 0x00},
+			DOMAIN_BEACON_ATTESTER:                BLSDomainType{0x01, 0x00, 0x00, 0x00},
+			DOMAIN_RANDAO:                         BLSDomainType{0x02, 0x00, 0x00, 0x00},
+			DOMAIN_DEPOSIT:                        BLSDomainType{0x03, 0x00, 0x00, 0x00},
+			DOMAIN_VOLUNTARY_EXIT:                 BLSDomainType{0x04, 0x00, 0x00, 0x00},
+			DOMAIN_SELECTION_PROOF:                BLSDomainType{0x05, 0x00, 0x00, 0x00},
+			DOMAIN_AGGREGATE_AND_PROOF:            BLSDomainType{0x06, 0x00, 0x00, 0x00},
+		},
+	}
+}
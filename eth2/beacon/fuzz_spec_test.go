@@ -0,0 +1,64 @@
+package beacon_test
+
+import (
+	"context"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/view"
+	"reflect"
+	"testing"
+)
+
+func TestFuzzSpecTypes(t *testing.T) {
+	spec := beacon.FuzzSpec()
+	conf := reflect.ValueOf(&spec.Phase0Config)
+	typeDefType := reflect.TypeOf((*view.TypeDef)(nil)).Elem()
+	checked := 0
+	for i := 0; i < conf.NumMethod(); i++ {
+		m := conf.Type().Method(i)
+		if m.Type.NumIn() != 1 || m.Type.NumOut() != 1 || !m.Type.Out(0).Implements(typeDefType) {
+			continue
+		}
+		td := conf.Method(i).Call(nil)[0].Interface().(view.TypeDef)
+		if td.Default(nil) == nil {
+			t.Fatalf("%s: no default view", m.Name)
+		}
+		if li, ok := td.(view.ListTypeDef); ok && li.Limit() == 0 {
+			t.Fatalf("%s: list has no capacity", m.Name)
+		}
+		checked++
+	}
+	if checked == 0 {
+		t.Fatal("no type definitions found")
+	}
+}
+
+func TestFuzzSpecTransition(t *testing.T) {
+	spec := beacon.FuzzSpec()
+	validators := make([]beacon.KickstartValidatorData, 32)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	state, epc, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// run past a full eth1 voting period and the block roots history
+	if err := spec.ProcessSlots(context.Background(), epc, state, spec.SLOTS_PER_HISTORICAL_ROOT+spec.SLOTS_PER_EPOCH); err != nil {
+		t.Fatal(err)
+	}
+	start, err := spec.EpochStartSlot(epc.CurrentEpoch.Epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for slot := start; slot < start+spec.SLOTS_PER_EPOCH; slot++ {
+		committee, err := epc.GetBeaconCommittee(slot, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uint64(len(committee)) > spec.MAX_VALIDATORS_PER_COMMITTEE {
+			t.Fatalf("committee of %d validators is too large", len(committee))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"errors"
 	"fmt"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
@@ -110,6 +111,18 @@ func (g Gwei) HashTreeRoot(hFn tree.HashFn) Root {
 	return Uint64View(g).HashTreeRoot(hFn)
 }
 
+// ErrBalanceOverflow is returned when a sum of balances does not fit in a Gwei.
+var ErrBalanceOverflow = errors.New("balance overflow")
+
+// SafeAdd returns the sum of the two amounts, or ErrBalanceOverflow if the sum does not fit in a Gwei.
+func (g Gwei) SafeAdd(other Gwei) (Gwei, error) {
+	sum := g + other
+	if sum < g {
+		return 0, ErrBalanceOverflow
+	}
+	return sum, nil
+}
+
 func (e Gwei) MarshalJSON() ([]byte, error) {
 	return Uint64View(e).MarshalJSON()
 }
@@ -22,13 +22,63 @@ func NewDepositRootsView() *DepositRootsView {
 }
 
 func (spec *Spec) GenesisFromEth1(eth1BlockHash Root, time Timestamp, deps []Deposit, ignoreSignaturesAndProofs bool) (*BeaconStateView, *EpochsContext, error) {
+	return spec.GenesisFromEth1WithOptions(eth1BlockHash, time, deps, GenesisOptions{SkipSignaturesAndProofs: ignoreSignaturesAndProofs})
+}
+
+// GenesisOptions customizes the genesis state, e.g. for private chains and testnet restarts.
+// The zero value follows the spec.
+type GenesisOptions struct {
+	// ForkVersion overrides the GENESIS_FORK_VERSION of the spec in the genesis fork, if not nil.
+	// Deposit signatures are always verified with the GENESIS_FORK_VERSION of the spec, like in the deposit contract.
+	ForkVersion *Version
+	// GenesisDelay overrides the GENESIS_DELAY of the spec, if not nil.
+	GenesisDelay *Timestamp
+	// GenesisValidatorsRoot overrides the computed genesis validators root, if not nil.
+	GenesisValidatorsRoot *Root
+	// SkipSignaturesAndProofs skips the verification of the deposit signatures and merkle proofs.
+	SkipSignaturesAndProofs bool
+}
+
+// GenesisFromEth1WithOptions is like GenesisFromEth1, but with the fork version, genesis delay
+// and genesis validators root customized by the options.
+func (spec *Spec) GenesisFromEth1WithOptions(eth1BlockHash Root, time Timestamp, deps []Deposit, opts GenesisOptions) (*BeaconStateView, *EpochsContext, error) {
+	verifySig := spec.VerifyDepositSignature
+	if opts.SkipSignaturesAndProofs {
+		verifySig = nil
+	}
+	return spec.genesisFromEth1(eth1BlockHash, time, deps, !opts.SkipSignaturesAndProofs, verifySig, &opts)
+}
+
+// GenesisFromEth1Batched is like GenesisFromEth1, but verifies all deposit signatures as a batch up-front,
+// instead of one by one. Deposits with invalid signatures are skipped as usual.
+func (spec *Spec) GenesisFromEth1Batched(eth1BlockHash Root, time Timestamp, deps []Deposit, ignoreProofs bool) (*BeaconStateView, *EpochsContext, error) {
+	validSigs := spec.VerifyDepositSignatures(deps)
+	valid := make(map[*Deposit]bool, len(deps))
+	for i := range deps {
+		valid[&deps[i]] = validSigs[i]
+	}
+	verifySig := func(dep *Deposit) bool {
+		return valid[dep]
+	}
+	return spec.genesisFromEth1(eth1BlockHash, time, deps, !ignoreProofs, verifySig, &GenesisOptions{})
+}
+
+func (spec *Spec) genesisFromEth1(eth1BlockHash Root, time Timestamp, deps []Deposit, verifyProofs bool, verifySig func(dep *Deposit) bool, opts *GenesisOptions) (*BeaconStateView, *EpochsContext, error) {
+	genesisDelay := spec.GENESIS_DELAY
+	if opts.GenesisDelay != nil {
+		genesisDelay = *opts.GenesisDelay
+	}
+	forkVersion := spec.GENESIS_FORK_VERSION
+	if opts.ForkVersion != nil {
+		forkVersion = *opts.ForkVersion
+	}
 	state := spec.NewBeaconStateView()
-	if err := state.SetGenesisTime(time + spec.GENESIS_DELAY); err != nil {
+	if err := state.SetGenesisTime(time + genesisDelay); err != nil {
 		return nil, nil, err
 	}
 	if err := state.SetFork(Fork{
-		PreviousVersion: spec.GENESIS_FORK_VERSION,
-		CurrentVersion:  spec.GENESIS_FORK_VERSION,
+		PreviousVersion: forkVersion,
+		CurrentVersion:  forkVersion,
 		Epoch:           GENESIS_EPOCH,
 	}); err != nil {
 		return nil, nil, err
@@ -65,6 +115,7 @@ func (spec *Spec) GenesisFromEth1(eth1BlockHash Root, time Timestamp, deps []Dep
 	epc := &EpochsContext{
 		Spec:        spec,
 		PubkeyCache: pc,
+		Domains:     newDomainCache(),
 	}
 
 	depRootsView := NewDepositRootsView()
@@ -88,7 +139,7 @@ func (spec *Spec) GenesisFromEth1(eth1BlockHash Root, time Timestamp, deps []Dep
 			return nil, nil, err
 		}
 		// in the rare case someone tries to create a genesis block using invalid data, error.
-		if err := spec.ProcessDeposit(epc, state, &deps[i], ignoreSignaturesAndProofs); err != nil {
+		if _, _, err := spec.processDeposit(epc, state, &deps[i], verifyProofs, verifySig); err != nil {
 			return nil, nil, err
 		}
 	}
@@ -133,7 +184,11 @@ func (spec *Spec) GenesisFromEth1(eth1BlockHash Root, time Timestamp, deps []Dep
 			}
 		}
 	}
-	if err := state.SetGenesisValidatorsRoot(vals.HashTreeRoot(hFn)); err != nil {
+	genesisValidatorsRoot := vals.HashTreeRoot(hFn)
+	if opts.GenesisValidatorsRoot != nil {
+		genesisValidatorsRoot = *opts.GenesisValidatorsRoot
+	}
+	if err := state.SetGenesisValidatorsRoot(genesisValidatorsRoot); err != nil {
 		return nil, nil, err
 	}
 	// Complete computation of epc
@@ -0,0 +1,166 @@
+// +build !bls_off
+
+package beacon_test
+
+import (
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/util/bls"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestGenesisFromEth1Batched(t *testing.T) {
+	spec := configs.Minimal
+	dom := beacon.ComputeDomain(spec.DOMAIN_DEPOSIT, spec.GENESIS_FORK_VERSION, beacon.Root{})
+	depTree := beacon.NewDepositTree()
+	hFn := tree.GetHashFn()
+	keys := make([]hbls.SecretKey, 20)
+	deps := make([]beacon.Deposit, len(keys))
+	for i := range deps {
+		keys[i].SetByCSPRNG()
+		key := &keys[i]
+		if i == 12 {
+			// Deposits for the validator of the invalid deposit 11 still need a valid signature.
+			key = &keys[11]
+		}
+		data := &deps[i].Data
+		copy(data.Pubkey[:], key.GetPublicKey().Serialize())
+		data.WithdrawalCredentials[0] = byte(i)
+		data.Amount = spec.MAX_EFFECTIVE_BALANCE
+		msg := beacon.ComputeSigningRoot(data.MessageRoot(), dom)
+		copy(data.Signature[:], key.SignHash(msg[:]).Serialize())
+		switch i {
+		case 3:
+			// Signature of another deposit
+			data.Signature = deps[2].Data.Signature
+		case 11:
+			// Not a valid signature at all
+			data.Signature = beacon.BLSSignature{0xff}
+		}
+		if err := depTree.Insert(data.HashTreeRoot(hFn)); err != nil {
+			t.Fatal(err)
+		}
+		// Deposits are verified against the deposit root after inclusion of the deposit itself.
+		proof, err := depTree.Proof(uint64(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		deps[i].Proof = proof
+	}
+	expected, _, err := spec.GenesisFromEth1(beacon.Root{123}, 1564000000, deps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _, err := spec.GenesisFromEth1Batched(beacon.Root{123}, 1564000000, deps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := expected.HashTreeRoot(hFn), state.HashTreeRoot(hFn); a != b {
+		t.Fatalf("batched genesis state differs: %s <> %s", a, b)
+	}
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := vals.Length(); err != nil {
+		t.Fatal(err)
+	} else if count != uint64(len(deps)-2) {
+		t.Fatalf("expected %d validators, got %d", len(deps)-2, count)
+	}
+}
+
+func TestKickStartFromSeed(t *testing.T) {
+	spec := configs.Minimal
+	seed := [32]byte{1, 2, 3}
+	count := spec.MIN_GENESIS_ACTIVE_VALIDATOR_COUNT
+	state, _, keys, err := spec.KickStartFromSeed(beacon.Root{123}, spec.MIN_GENESIS_TIME, seed, count, spec.MAX_EFFECTIVE_BALANCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := vals.Length(); err != nil {
+		t.Fatal(err)
+	} else if n != count || uint64(len(keys)) != count {
+		t.Fatalf("expected %d validators and keys, got %d validators and %d keys", count, n, len(keys))
+	}
+	if valid, err := spec.IsValidGenesisState(state); err != nil {
+		t.Fatal(err)
+	} else if !valid {
+		t.Fatal("expected valid genesis state")
+	}
+	expectedKey, err := bls.DeriveSKFromPath(seed[:], "m/12381/3600/3/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys[3] != expectedKey {
+		t.Fatal("unexpected signing key")
+	}
+	val, err := vals.Validator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := val.Pubkey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secKey hbls.SecretKey
+	if err := secKey.Deserialize(keys[3][:]); err != nil {
+		t.Fatal(err)
+	}
+	if string(pub[:]) != string(secKey.GetPublicKey().Serialize()) {
+		t.Fatal("validator pubkey does not match signing key")
+	}
+	creds, err := val.WithdrawalCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds[0] != spec.BLS_WITHDRAWAL_PREFIX[0] {
+		t.Fatalf("expected BLS withdrawal credentials, got %s", creds)
+	}
+}
+
+func TestGenesisFromEth1WithOptions(t *testing.T) {
+	spec := configs.Minimal
+	deps := make([]beacon.Deposit, 64)
+	for i := range deps {
+		deps[i].Data.Pubkey[0] = byte(i)
+		deps[i].Data.WithdrawalCredentials[0] = byte(i)
+		deps[i].Data.Amount = spec.MAX_EFFECTIVE_BALANCE
+	}
+	forkVersion := beacon.Version{0x12, 0x34, 0x56, 0x78}
+	delay := beacon.Timestamp(42)
+	validatorsRoot := beacon.Root{0xab}
+	state, _, err := spec.GenesisFromEth1WithOptions(beacon.Root{123}, 1564000000, deps, beacon.GenesisOptions{
+		ForkVersion:             &forkVersion,
+		GenesisDelay:            &delay,
+		GenesisValidatorsRoot:   &validatorsRoot,
+		SkipSignaturesAndProofs: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if genesisTime, err := state.GenesisTime(); err != nil {
+		t.Fatal(err)
+	} else if genesisTime != 1564000000+delay {
+		t.Fatalf("unexpected genesis time: %d", genesisTime)
+	}
+	fork, err := state.Fork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, err := fork.CurrentVersion(); err != nil {
+		t.Fatal(err)
+	} else if current != forkVersion {
+		t.Fatalf("unexpected genesis fork version: %s", current)
+	}
+	if root, err := state.GenesisValidatorsRoot(); err != nil {
+		t.Fatal(err)
+	} else if root != validatorsRoot {
+		t.Fatalf("unexpected genesis validators root: %s", root)
+	}
+}
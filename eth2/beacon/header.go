@@ -148,6 +148,22 @@ func (v *BeaconBlockHeaderView) Raw() (*BeaconBlockHeader, error) {
 	}, nil
 }
 
+// ErrWrongProposerIndex is returned (wrapped) when a block claims a different proposer than expected for its slot.
+var ErrWrongProposerIndex = errors.New("block proposer index does not match expected proposer")
+
+// ValidateProposerIndex checks that the proposer index of the block is the expected proposer of the block slot.
+// The slot must be within the epochs of the context.
+func (spec *Spec) ValidateProposerIndex(epc *EpochsContext, block *BeaconBlock) error {
+	expected, err := epc.GetBeaconProposer(block.Slot)
+	if err != nil {
+		return err
+	}
+	if block.ProposerIndex != expected {
+		return fmt.Errorf("%w: got: %d, expected: %d", ErrWrongProposerIndex, block.ProposerIndex, expected)
+	}
+	return nil
+}
+
 func (spec *Spec) ProcessHeader(ctx context.Context, epc *EpochsContext, state *BeaconStateView, header *BeaconBlock) error {
 	select {
 	case <-ctx.Done():
@@ -179,13 +195,10 @@ func (spec *Spec) ProcessHeader(ctx context.Context, epc *EpochsContext, state *
 	} else if !isValid {
 		return fmt.Errorf("beacon block header proposer index is out of range: %d", header.ProposerIndex)
 	}
-	proposerIndex, err := epc.GetBeaconProposer(currentSlot)
-	if err != nil {
+	if err := spec.ValidateProposerIndex(epc, header); err != nil {
 		return err
 	}
-	if header.ProposerIndex != proposerIndex {
-		return fmt.Errorf("beacon block header proposer index does not match expected index: got: %d, expected: %d", header.ProposerIndex, proposerIndex)
-	}
+	proposerIndex := header.ProposerIndex
 	// Verify that the parent matches
 	latestRoot := latestHeader.HashTreeRoot(tree.GetHashFn())
 	if header.ParentRoot != latestRoot {
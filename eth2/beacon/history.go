@@ -1,6 +1,8 @@
 package beacon
 
 import (
+	"errors"
+	"fmt"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
 	. "github.com/protolambda/ztyp/view"
@@ -84,8 +86,26 @@ func AsBatchRoots(v View, err error) (*BatchRootsView, error) {
 	return &BatchRootsView{c}, err
 }
 
+var (
+	// ErrSlotTooOld is returned when a root is requested for a slot that is no longer in the history of the state.
+	ErrSlotTooOld = errors.New("slot is too old, root is not available anymore")
+	// ErrSlotInFuture is returned when a root is requested for a slot that is not before the slot of the state.
+	ErrSlotInFuture = errors.New("slot is not in the past, root is not available yet")
+)
+
 // Return the block root at a recent slot. Only valid to SLOTS_PER_HISTORICAL_ROOT slots ago.
+// Returns ErrSlotTooOld or ErrSlotInFuture (wrapped) if the slot is out of range.
 func (spec *Spec) GetBlockRootAtSlot(state *BeaconStateView, slot Slot) (Root, error) {
+	currentSlot, err := state.Slot()
+	if err != nil {
+		return Root{}, err
+	}
+	if slot >= currentSlot {
+		return Root{}, fmt.Errorf("cannot get block root of slot %d at slot %d: %w", slot, currentSlot, ErrSlotInFuture)
+	}
+	if currentSlot > slot+spec.SLOTS_PER_HISTORICAL_ROOT {
+		return Root{}, fmt.Errorf("cannot get block root of slot %d at slot %d: %w", slot, currentSlot, ErrSlotTooOld)
+	}
 	blockRoots, err := state.BlockRoots()
 	if err != nil {
 		return Root{}, err
@@ -95,15 +115,31 @@ func (spec *Spec) GetBlockRootAtSlot(state *BeaconStateView, slot Slot) (Root, e
 
 // Return the block root at a recent epoch. Only valid to SLOTS_PER_HISTORICAL_ROOT slots ago.
 func (spec *Spec) GetBlockRoot(state *BeaconStateView, epoch Epoch) (Root, error) {
-	blockRoots, err := state.BlockRoots()
+	startSlot, err := spec.EpochStartSlot(epoch)
 	if err != nil {
 		return Root{}, err
 	}
-	startSlot, err := spec.EpochStartSlot(epoch)
+	return spec.GetBlockRootAtSlot(state, startSlot)
+}
+
+// StateProvider provides the states of past slots, e.g. from a database, or by replaying blocks.
+// The returned state must be at the requested slot, and must not be modified by the caller.
+type StateProvider interface {
+	StateAtSlot(slot Slot) (*BeaconStateView, error)
+}
+
+// GetBlockRootAtSlotWithProvider is like GetBlockRootAtSlot, but if the slot is older than the block roots of the state,
+// the block root is looked up in the state of the next slot, as provided. The provider may be nil.
+func (spec *Spec) GetBlockRootAtSlotWithProvider(state *BeaconStateView, provider StateProvider, slot Slot) (Root, error) {
+	root, err := spec.GetBlockRootAtSlot(state, slot)
+	if provider == nil || !errors.Is(err, ErrSlotTooOld) {
+		return root, err
+	}
+	past, err := provider.StateAtSlot(slot + 1)
 	if err != nil {
-		return Root{}, err
+		return Root{}, fmt.Errorf("failed to get state of slot %d: %v", slot+1, err)
 	}
-	return blockRoots.GetRoot(startSlot)
+	return spec.GetBlockRootAtSlot(past, slot)
 }
 
 func (c *Phase0Config) HistoricalBatch() *ContainerTypeDef {
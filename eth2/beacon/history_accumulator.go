@@ -0,0 +1,143 @@
+package beacon
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/ztyp/tree"
+	. "github.com/protolambda/ztyp/view"
+)
+
+// ErrPrunedSlot is returned by the HistoricalBatchAccumulator when the block root of a slot
+// is older than the retained batches.
+type ErrPrunedSlot struct {
+	Slot Slot
+	// First slot that can still be resolved.
+	Start Slot
+}
+
+func (e ErrPrunedSlot) Error() string {
+	return fmt.Sprintf("block root of slot %d is pruned, oldest resolvable slot is %d", e.Slot, e.Start)
+}
+
+// HistoricalBatchAccumulator keeps the block roots of each batch that the state accumulates into HistoricalRoots,
+// to resolve block roots older than the SLOTS_PER_HISTORICAL_ROOT window of the state.
+//
+// The state ring of block roots is overwritten after the batch is accumulated, so the accumulator has to
+// capture the batch at the batch boundary: use ProcessSlots to transition the state, or call Update
+// before the first slot after each batch is processed.
+type HistoricalBatchAccumulator struct {
+	spec *Spec
+	// Maximum number of batches to keep, 0 to keep all.
+	retain uint64
+	// Index of the first retained batch within HistoricalRoots.
+	first   uint64
+	batches []HistoricalBatchRoots
+}
+
+// NewHistoricalBatchAccumulator starts accumulating from the given state.
+// Batches accumulated before the state cannot be resolved. Retain is the maximum number of batches to keep,
+// or 0 to keep all of them.
+func (spec *Spec) NewHistoricalBatchAccumulator(state *BeaconStateView, retain uint64) (*HistoricalBatchAccumulator, error) {
+	histRoots, err := state.HistoricalRoots()
+	if err != nil {
+		return nil, err
+	}
+	count, err := histRoots.Length()
+	if err != nil {
+		return nil, err
+	}
+	return &HistoricalBatchAccumulator{spec: spec, retain: retain, first: count}, nil
+}
+
+// ResolvableSlots returns the range of slots, start inclusive, end exclusive, that BlockRootAtSlot can answer.
+func (acc *HistoricalBatchAccumulator) ResolvableSlots() (start Slot, end Slot) {
+	start = Slot(acc.first) * acc.spec.SLOTS_PER_HISTORICAL_ROOT
+	end = start + Slot(len(acc.batches))*acc.spec.SLOTS_PER_HISTORICAL_ROOT
+	return
+}
+
+// BlockRootAtSlot returns the block root at the given slot, if it is within the retained batches.
+// ErrPrunedSlot is returned for older slots.
+func (acc *HistoricalBatchAccumulator) BlockRootAtSlot(slot Slot) (Root, error) {
+	start, end := acc.ResolvableSlots()
+	if slot < start {
+		return Root{}, ErrPrunedSlot{Slot: slot, Start: start}
+	}
+	if slot >= end {
+		return Root{}, fmt.Errorf("block root of slot %d is not accumulated yet, accumulated up to slot %d (excl.)", slot, end)
+	}
+	i := uint64(slot-start) / uint64(acc.spec.SLOTS_PER_HISTORICAL_ROOT)
+	return acc.batches[i][slot%acc.spec.SLOTS_PER_HISTORICAL_ROOT], nil
+}
+
+// Update captures the batch that was last appended to the HistoricalRoots of the state, if not already captured.
+// The state must still be at the first slot after the batch, before that slot is processed.
+func (acc *HistoricalBatchAccumulator) Update(state *BeaconStateView) error {
+	histRoots, err := state.HistoricalRoots()
+	if err != nil {
+		return err
+	}
+	count, err := histRoots.Length()
+	if err != nil {
+		return err
+	}
+	next := acc.first + uint64(len(acc.batches))
+	if count == next {
+		return nil
+	}
+	if count != next+1 {
+		return fmt.Errorf("historical batch accumulator expected %d historical roots, but state has %d", next+1, count)
+	}
+	blockRoots, err := state.BlockRoots()
+	if err != nil {
+		return err
+	}
+	stateRoots, err := state.StateRoots()
+	if err != nil {
+		return err
+	}
+	expected, err := AsRoot(histRoots.Get(count - 1))
+	if err != nil {
+		return err
+	}
+	hFn := tree.GetHashFn()
+	if tree.Hash(blockRoots.HashTreeRoot(hFn), stateRoots.HashTreeRoot(hFn)) != expected {
+		return fmt.Errorf("block roots of state do not match historical root %d, batch was overwritten", count-1)
+	}
+	batch := make(HistoricalBatchRoots, acc.spec.SLOTS_PER_HISTORICAL_ROOT)
+	for i := range batch {
+		batch[i], err = blockRoots.GetRoot(Slot(i))
+		if err != nil {
+			return err
+		}
+	}
+	acc.batches = append(acc.batches, batch)
+	if acc.retain != 0 && uint64(len(acc.batches)) > acc.retain {
+		pruned := uint64(len(acc.batches)) - acc.retain
+		acc.batches = append(acc.batches[:0], acc.batches[pruned:]...)
+		acc.first += pruned
+	}
+	return nil
+}
+
+// ProcessSlots is like Spec.ProcessSlots, but stops at every batch boundary to update the accumulator.
+func (acc *HistoricalBatchAccumulator) ProcessSlots(ctx context.Context, epc *EpochsContext, state *BeaconStateView, slot Slot) error {
+	currentSlot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	for currentSlot < slot {
+		next := (currentSlot/acc.spec.SLOTS_PER_HISTORICAL_ROOT + 1) * acc.spec.SLOTS_PER_HISTORICAL_ROOT
+		if next > slot {
+			next = slot
+		}
+		if err := acc.spec.ProcessSlots(ctx, epc, state, next); err != nil {
+			return err
+		}
+		if err := acc.Update(state); err != nil {
+			return err
+		}
+		currentSlot = next
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package beacon_test
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestHistoricalBatchAccumulator(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	acc, err := spec.NewHistoricalBatchAccumulator(state, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := spec.SLOTS_PER_HISTORICAL_ROOT
+	// A distinct block root at every slot: the latest block header is replaced before the slot is processed.
+	roots := make(map[beacon.Slot]beacon.Root)
+	seen := make(map[beacon.Root]struct{})
+	for slot := beacon.Slot(0); slot <= batch*3; slot++ {
+		header := beacon.BeaconBlockHeader{Slot: slot, BodyRoot: beacon.Root{byte(slot), byte(slot >> 8)}}
+		if err := state.SetLatestBlockHeader(header.View()); err != nil {
+			t.Fatal(err)
+		}
+		if err := acc.ProcessSlots(context.Background(), epc, state, slot+1); err != nil {
+			t.Fatal(err)
+		}
+		root, err := spec.GetBlockRootAtSlot(state, slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := seen[root]; ok {
+			t.Fatalf("block root of slot %d is not distinct", slot)
+		}
+		seen[root] = struct{}{}
+		roots[slot] = root
+	}
+	start, end := acc.ResolvableSlots()
+	if start != batch || end != batch*3 {
+		t.Fatalf("unexpected resolvable slots: %d - %d", start, end)
+	}
+	for slot := start; slot < end; slot++ {
+		root, err := acc.BlockRootAtSlot(slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root != roots[slot] {
+			t.Fatalf("unexpected block root at slot %d: %s <> %s", slot, root, roots[slot])
+		}
+	}
+	var pruned beacon.ErrPrunedSlot
+	if _, err := acc.BlockRootAtSlot(start - 1); !errors.As(err, &pruned) || pruned.Slot != start-1 {
+		t.Fatalf("expected pruned slot error, got: %v", err)
+	}
+	if _, err := acc.BlockRootAtSlot(end); err == nil || errors.As(err, &pruned) {
+		t.Fatalf("expected not-yet-accumulated error, got: %v", err)
+	}
+}
+
+func TestGetBlockRootAtSlotBounds(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	current := spec.SLOTS_PER_HISTORICAL_ROOT + 6
+	if err := spec.ProcessSlots(context.Background(), epc, state, current); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spec.GetBlockRootAtSlot(state, current-spec.SLOTS_PER_HISTORICAL_ROOT); err != nil {
+		t.Fatalf("expected oldest slot to be available: %v", err)
+	}
+	if _, err := spec.GetBlockRootAtSlot(state, current-1); err != nil {
+		t.Fatalf("expected previous slot to be available: %v", err)
+	}
+	if _, err := spec.GetBlockRootAtSlot(state, current-spec.SLOTS_PER_HISTORICAL_ROOT-1); !errors.Is(err, beacon.ErrSlotTooOld) {
+		t.Fatalf("expected ErrSlotTooOld, got %v", err)
+	}
+	if _, err := spec.GetBlockRootAtSlot(state, current); !errors.Is(err, beacon.ErrSlotInFuture) {
+		t.Fatalf("expected ErrSlotInFuture, got %v", err)
+	}
+}
+
+type stateHistory map[beacon.Slot]*beacon.BeaconStateView
+
+func (h stateHistory) StateAtSlot(slot beacon.Slot) (*beacon.BeaconStateView, error) {
+	state, ok := h[slot]
+	if !ok {
+		return nil, errors.New("unknown slot")
+	}
+	return state, nil
+}
+
+func TestGetBlockRootAtSlotWithProvider(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	history := make(stateHistory)
+	current := spec.SLOTS_PER_HISTORICAL_ROOT + 6
+	for slot := beacon.Slot(1); slot <= current; slot++ {
+		if err := spec.ProcessSlots(context.Background(), epc, state, slot); err != nil {
+			t.Fatal(err)
+		}
+		past, err := beacon.AsBeaconStateView(state.Copy())
+		if err != nil {
+			t.Fatal(err)
+		}
+		history[slot] = past
+	}
+	expected, err := spec.GetBlockRootAtSlot(history[1], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := spec.GetBlockRootAtSlotWithProvider(state, history, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != expected {
+		t.Fatalf("unexpected block root: %s <> %s", root, expected)
+	}
+	if _, err := spec.GetBlockRootAtSlotWithProvider(state, nil, 0); !errors.Is(err, beacon.ErrSlotTooOld) {
+		t.Fatalf("expected ErrSlotTooOld without provider, got %v", err)
+	}
+	delete(history, 1)
+	if _, err := spec.GetBlockRootAtSlotWithProvider(state, history, 0); err == nil {
+		t.Fatal("expected error for unavailable state")
+	}
+}
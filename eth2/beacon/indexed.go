@@ -147,12 +147,58 @@ func (spec *Spec) ValidateIndexedAttestationSignature(dom BLSDomain, pubCache *P
 	return nil
 }
 
+// ValidateIndexedAttestationsBatch verifies the signatures of all the indexed attestations in a single batch.
+// All attestations are verified with the same domain, the caller is responsible for grouping them by domain.
+// If the batch is invalid, the attestations are verified one by one to find the first invalid attestation.
+func (spec *Spec) ValidateIndexedAttestationsBatch(dom BLSDomain, pubCache *PubkeyCache, atts []*IndexedAttestation) error {
+	if len(atts) == 0 {
+		return nil
+	}
+	pubkeys := make([][]*CachedPubkey, 0, len(atts))
+	messages := make([][32]byte, 0, len(atts))
+	signatures := make([]BLSSignature, 0, len(atts))
+	hFn := tree.GetHashFn()
+	for i, att := range atts {
+		attPubs := make([]*CachedPubkey, 0, len(att.AttestingIndices))
+		for _, vi := range att.AttestingIndices {
+			pub, ok := pubCache.Pubkey(vi)
+			if !ok {
+				return fmt.Errorf("indexed attestation %d: could not find pubkey for index %d", i, vi)
+			}
+			attPubs = append(attPubs, pub)
+		}
+		if len(attPubs) <= 0 {
+			return fmt.Errorf("indexed attestation %d: in phase 0 no empty attestation signatures are allowed", i)
+		}
+		pubkeys = append(pubkeys, attPubs)
+		messages = append(messages, ComputeSigningRoot(att.Data.HashTreeRoot(hFn), dom))
+		signatures = append(signatures, att.Signature)
+	}
+	if bls.BatchFastAggregateVerify(pubkeys, messages, signatures) {
+		return nil
+	}
+	// Batch failed, find the culprit.
+	for i := range atts {
+		if !bls.FastAggregateVerify(pubkeys[i], messages[i], signatures[i]) {
+			return fmt.Errorf("indexed attestation %d: could not verify BLS signature", i)
+		}
+	}
+	return errors.New("could not verify BLS signatures of indexed attestations batch")
+}
+
 // Verify validity of slashable_attestation fields.
 func (spec *Spec) ValidateIndexedAttestation(epc *EpochsContext, state *BeaconStateView, indexedAttestation *IndexedAttestation) error {
+	return spec.validateIndexedAttestation(epc, state, indexedAttestation, true)
+}
+
+func (spec *Spec) validateIndexedAttestation(epc *EpochsContext, state *BeaconStateView, indexedAttestation *IndexedAttestation, verifySig bool) error {
 	if err := spec.ValidateIndexedAttestationNoSignature(state, indexedAttestation); err != nil {
 		return err
 	}
-	dom, err := state.GetDomain(spec.DOMAIN_BEACON_ATTESTER, indexedAttestation.Data.Target.Epoch)
+	if !verifySig {
+		return nil
+	}
+	dom, err := epc.GetDomain(state, spec.DOMAIN_BEACON_ATTESTER, indexedAttestation.Data.Target.Epoch)
 	if err != nil {
 		return err
 	}
@@ -9,10 +9,48 @@ import (
 	. "github.com/protolambda/ztyp/view"
 )
 
+// FinalizationRule is a set of the finalization rules, each rule checks a different combination of justified epochs.
+type FinalizationRule uint8
+
+const (
+	// The 2nd/3rd/4th most recent epochs are all justified, the 2nd using the 4th as source
+	FinalizationRule234 FinalizationRule = 1 << iota
+	// The 2nd/3rd most recent epochs are both justified, the 2nd using the 3rd as source
+	FinalizationRule23
+	// The 1st/2nd/3rd most recent epochs are all justified, the 1st using the 3rd as source
+	FinalizationRule123
+	// The 1st/2nd most recent epochs are both justified, the 1st using the 2nd as source
+	FinalizationRule12
+)
+
+// JustificationResult describes what the justification and finalization of an epoch transition decided.
+type JustificationResult struct {
+	// Skipped is true for the first epochs after genesis, in which justification and finalization are not processed.
+	// The other fields are then zeroed.
+	Skipped bool
+	// If the previous and current epochs have enough target stake to be justified.
+	PreviousEpochJustified bool
+	CurrentEpochJustified  bool
+	// The justification bits after the update.
+	Bits JustificationBits
+	// The finalization rules that applied, if any. If multiple rules apply, the last rule determines what is finalized.
+	Rules FinalizationRule
+	// The checkpoints after the update.
+	PreviousJustified Checkpoint
+	CurrentJustified  Checkpoint
+	Finalized         Checkpoint
+}
+
 func (spec *Spec) ProcessEpochJustification(ctx context.Context, epc *EpochsContext, process *EpochProcess, state *BeaconStateView) error {
+	_, err := spec.ProcessJustificationAndFinalization(ctx, epc, process, state)
+	return err
+}
+
+// ProcessJustificationAndFinalization is ProcessEpochJustification, but also returns what it decided, for monitoring.
+func (spec *Spec) ProcessJustificationAndFinalization(ctx context.Context, epc *EpochsContext, process *EpochProcess, state *BeaconStateView) (res JustificationResult, err error) {
 	select {
 	case <-ctx.Done():
-		return TransitionCancelErr
+		return res, TransitionCancelErr
 	default: // Don't block.
 		break
 	}
@@ -21,39 +59,51 @@ func (spec *Spec) ProcessEpochJustification(ctx context.Context, epc *EpochsCont
 
 	// skip if genesis.
 	if currentEpoch <= GENESIS_EPOCH+1 {
-		return nil
+		res.Skipped = true
+		return res, nil
 	}
 
 	prJustCh, err := state.PreviousJustifiedCheckpoint()
 	if err != nil {
-		return err
+		return res, err
 	}
 	oldPreviousJustified, err := prJustCh.Raw()
 	if err != nil {
-		return err
+		return res, err
 	}
 	cuJustCh, err := state.CurrentJustifiedCheckpoint()
 	if err != nil {
-		return err
+		return res, err
 	}
 	oldCurrentJustified, err := cuJustCh.Raw()
 	if err != nil {
-		return err
+		return res, err
+	}
+	finCh, err := state.FinalizedCheckpoint()
+	if err != nil {
+		return res, err
+	}
+	oldFinalized, err := finCh.Raw()
+	if err != nil {
+		return res, err
 	}
 
 	bitsView, err := state.JustificationBits()
 	if err != nil {
-		return err
+		return res, err
 	}
 	bits, err := bitsView.Raw()
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	// Rotate (a copy of) current into previous
 	if err := prJustCh.Set(&oldCurrentJustified); err != nil {
-		return err
+		return res, err
 	}
+	res.PreviousJustified = oldCurrentJustified
+	res.CurrentJustified = oldCurrentJustified
+	res.Finalized = oldFinalized
 
 	bits.NextEpoch()
 
@@ -65,29 +115,32 @@ func (spec *Spec) ProcessEpochJustification(ctx context.Context, epc *EpochsCont
 	if process.PrevEpochUnslashedStake.TargetStake*3 >= totalStake*2 {
 		root, err := spec.GetBlockRoot(state, previousEpoch)
 		if err != nil {
-			return err
+			return res, err
 		}
 		newJustifiedCheckpoint = &Checkpoint{
 			Epoch: previousEpoch,
 			Root:  root,
 		}
 		bits[0] |= 1 << 1
+		res.PreviousEpochJustified = true
 	}
 	if process.CurrEpochUnslashedTargetStake*3 >= totalStake*2 {
 		root, err := spec.GetBlockRoot(state, currentEpoch)
 		if err != nil {
-			return err
+			return res, err
 		}
 		newJustifiedCheckpoint = &Checkpoint{
 			Epoch: currentEpoch,
 			Root:  root,
 		}
 		bits[0] |= 1 << 0
+		res.CurrentEpochJustified = true
 	}
 	if newJustifiedCheckpoint != nil {
 		if err := cuJustCh.Set(newJustifiedCheckpoint); err != nil {
-			return err
+			return res, err
 		}
+		res.CurrentJustified = *newJustifiedCheckpoint
 	}
 
 	// > Finalization
@@ -95,32 +148,34 @@ func (spec *Spec) ProcessEpochJustification(ctx context.Context, epc *EpochsCont
 	// The 2nd/3rd/4th most recent epochs are all justified, the 2nd using the 4th as source
 	if justified := bits.IsJustified(1, 2, 3); justified && oldPreviousJustified.Epoch+3 == currentEpoch {
 		toFinalize = &oldPreviousJustified
+		res.Rules |= FinalizationRule234
 	}
 	// The 2nd/3rd most recent epochs are both justified, the 2nd using the 3rd as source
 	if justified := bits.IsJustified(1, 2); justified && oldPreviousJustified.Epoch+2 == currentEpoch {
 		toFinalize = &oldPreviousJustified
+		res.Rules |= FinalizationRule23
 	}
 	// The 1st/2nd/3rd most recent epochs are all justified, the 1st using the 3rd as source
 	if justified := bits.IsJustified(0, 1, 2); justified && oldCurrentJustified.Epoch+2 == currentEpoch {
 		toFinalize = &oldCurrentJustified
+		res.Rules |= FinalizationRule123
 	}
 	// The 1st/2nd most recent epochs are both justified, the 1st using the 2nd as source
 	if justified := bits.IsJustified(0, 1); justified && oldCurrentJustified.Epoch+1 == currentEpoch {
 		toFinalize = &oldCurrentJustified
+		res.Rules |= FinalizationRule12
 	}
 	if toFinalize != nil {
-		finCh, err := state.FinalizedCheckpoint()
-		if err != nil {
-			return err
-		}
 		if err := finCh.Set(toFinalize); err != nil {
-			return err
+			return res, err
 		}
+		res.Finalized = *toFinalize
 	}
 	if err := bitsView.Set(bits); err != nil {
-		return err
+		return res, err
 	}
-	return nil
+	res.Bits = bits
+	return res, nil
 }
 
 type JustificationBits [1]byte
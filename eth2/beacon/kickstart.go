@@ -1,8 +1,10 @@
 package beacon
 
 import (
+	"crypto/sha256"
 	"errors"
 	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/util/bls"
 )
 
 type KickstartValidatorData struct {
@@ -26,14 +28,11 @@ func (spec *Spec) KickStartState(eth1BlockHash Root, time Timestamp, validators
 		}
 	}
 
-	state, epc, err := spec.GenesisFromEth1(eth1BlockHash, 0, deps, true)
-	if err != nil {
-		return nil, nil, err
-	}
-	if err := state.SetGenesisTime(time); err != nil {
-		return nil, nil, err
-	}
-	return state, epc, nil
+	noDelay := Timestamp(0)
+	return spec.GenesisFromEth1WithOptions(eth1BlockHash, time, deps, GenesisOptions{
+		GenesisDelay:            &noDelay,
+		SkipSignaturesAndProofs: true,
+	})
 }
 
 // To build a genesis state without Eth 1.0 deposits, i.e. directly from a sequence of minimal validator data.
@@ -64,12 +63,45 @@ func (spec *Spec) KickStartStateWithSignatures(eth1BlockHash Root, time Timestam
 		copy(d.Data.Signature[:], sig.Serialize())
 	}
 
-	state, epc, err := spec.GenesisFromEth1(eth1BlockHash, 0, deps, true)
+	noDelay := Timestamp(0)
+	return spec.GenesisFromEth1WithOptions(eth1BlockHash, time, deps, GenesisOptions{
+		GenesisDelay:            &noDelay,
+		SkipSignaturesAndProofs: true,
+	})
+}
+
+// KickStartFromSeed builds a genesis state, like KickStartStateWithSignatures, for count validators with keys derived
+// from the seed. The signing key of validator i is at EIP-2334 path m/12381/3600/i/0/0,
+// and the BLS withdrawal credentials are of the withdrawal key at m/12381/3600/i/0.
+// Also returns the signing keys, in validator index order.
+func (spec *Spec) KickStartFromSeed(eth1BlockHash Root, time Timestamp, seed [32]byte, count uint64, balance Gwei) (*BeaconStateView, *EpochsContext, [][32]byte, error) {
+	master, err := bls.DeriveMasterSK(seed[:])
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	base := bls.DeriveChildSK(bls.DeriveChildSK(master, 12381), 3600)
+	validators := make([]KickstartValidatorData, count, count)
+	keys := make([][32]byte, count, count)
+	for i := uint64(0); i < count; i++ {
+		withdrawalKey := bls.DeriveChildSK(bls.DeriveChildSK(base, uint32(i)), 0)
+		keys[i] = bls.DeriveChildSK(withdrawalKey, 0)
+
+		var secKey hbls.SecretKey
+		if err := secKey.Deserialize(keys[i][:]); err != nil {
+			return nil, nil, nil, err
+		}
+		copy(validators[i].Pubkey[:], secKey.GetPublicKey().Serialize())
+		if err := secKey.Deserialize(withdrawalKey[:]); err != nil {
+			return nil, nil, nil, err
+		}
+		creds := sha256.Sum256(secKey.GetPublicKey().Serialize())
+		creds[0] = spec.BLS_WITHDRAWAL_PREFIX[0]
+		validators[i].WithdrawalCredentials = creds
+		validators[i].Balance = balance
 	}
-	if err := state.SetGenesisTime(time); err != nil {
-		return nil, nil, err
+	state, epc, err := spec.KickStartStateWithSignatures(eth1BlockHash, time, validators, keys)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return state, epc, nil
+	return state, epc, keys, nil
 }
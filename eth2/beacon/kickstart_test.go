@@ -0,0 +1,26 @@
+package beacon_test
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+)
+
+// testValidators returns the kickstart data of 64 validators, with distinct pubkeys and full balances.
+func testValidators(spec *beacon.Spec) []beacon.KickstartValidatorData {
+	validators := make([]beacon.KickstartValidatorData, 64)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	return validators
+}
+
+// testState kickstarts a genesis state with the testValidators.
+func testState(t *testing.T, spec *beacon.Spec) (*beacon.BeaconStateView, *beacon.EpochsContext) {
+	state, epc, err := spec.KickStartState(beacon.Root{123}, 1564000000, testValidators(spec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state, epc
+}
@@ -0,0 +1,128 @@
+package beacon
+
+import (
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/util/math"
+)
+
+// ComputeProposerReward computes the reward the proposer of the block earns with the operations in the block body,
+// without applying the block. The state is expected to be the pre-block state, processed up to the block slot.
+//
+// Slashings credit the proposer directly during block processing: the proposer is the whistleblower,
+// and thus receives the full whistleblower reward of every validator slashed by the block.
+// Attestations credit the proposer during the epoch transition instead: the proposer earns the inclusion reward
+// of every unslashed attester that is included for the first time by the block.
+// This inclusion reward is an estimate, based on the current effective balances and total active balance.
+//
+// The block is assumed to be valid, operations are not verified.
+func (spec *Spec) ComputeProposerReward(epc *EpochsContext, state *BeaconStateView, block *BeaconBlock) (Gwei, error) {
+	currentEpoch := epc.CurrentEpoch.Epoch
+	vals, err := state.Validators()
+	if err != nil {
+		return 0, err
+	}
+	reward := Gwei(0)
+
+	// validators slashed by this block, they are not slashable again, nor eligible for inclusion rewards.
+	slashedByBlock := make(map[ValidatorIndex]struct{})
+	slashReward := func(index ValidatorIndex) error {
+		v, err := vals.Validator(index)
+		if err != nil {
+			return err
+		}
+		effBalance, err := v.EffectiveBalance()
+		if err != nil {
+			return err
+		}
+		// The proposer is the whistleblower, and receives both the proposer and whistleblower part.
+		reward += effBalance / Gwei(spec.WHISTLEBLOWER_REWARD_QUOTIENT)
+		slashedByBlock[index] = struct{}{}
+		return nil
+	}
+
+	for i := range block.Body.ProposerSlashings {
+		if err := slashReward(block.Body.ProposerSlashings[i].SignedHeader1.Message.ProposerIndex); err != nil {
+			return 0, err
+		}
+	}
+
+	for i := range block.Body.AttesterSlashings {
+		sl := &block.Body.AttesterSlashings[i]
+		var errorAny error
+		ValidatorSet(sl.Attestation1.AttestingIndices).ZigZagJoin(ValidatorSet(sl.Attestation2.AttestingIndices), func(index ValidatorIndex) {
+			if errorAny != nil {
+				return
+			}
+			if _, ok := slashedByBlock[index]; ok {
+				return
+			}
+			v, err := vals.Validator(index)
+			if err != nil {
+				errorAny = err
+				return
+			}
+			if ok, err := spec.IsSlashable(v, currentEpoch); err != nil {
+				errorAny = err
+			} else if ok {
+				errorAny = slashReward(index)
+			}
+		}, nil)
+		if errorAny != nil {
+			return 0, errorAny
+		}
+	}
+
+	if len(block.Body.Attestations) == 0 {
+		return reward, nil
+	}
+	totalBalance, err := epc.TotalActiveBalance(currentEpoch)
+	if err != nil {
+		return 0, err
+	}
+	balanceSqRoot := Gwei(math.IntegerSquareroot(uint64(totalBalance)))
+	covered, err := spec.includedAttesters(epc, state, currentEpoch)
+	if err != nil {
+		return 0, err
+	}
+	participants := make([]ValidatorIndex, 0, spec.MAX_VALIDATORS_PER_COMMITTEE)
+	for i := range block.Body.Attestations {
+		att := &block.Body.Attestations[i]
+		set, ok := covered[att.Data.Target.Epoch]
+		if !ok {
+			return 0, fmt.Errorf("attestation %d targets epoch %d, which is not the previous or current epoch", i, att.Data.Target.Epoch)
+		}
+		committee, err := epc.GetBeaconCommittee(att.Data.Slot, att.Data.Index)
+		if err != nil {
+			return 0, err
+		}
+		if att.AggregationBits.BitLen() != uint64(len(committee)) {
+			return 0, fmt.Errorf("attestation %d does not match committee size", i)
+		}
+		participants = att.AggregationBits.FilterParticipantsInto(participants, committee)
+		for _, p := range participants {
+			if _, ok := set[p]; ok {
+				continue
+			}
+			set[p] = struct{}{}
+			if _, ok := slashedByBlock[p]; ok {
+				continue
+			}
+			v, err := vals.Validator(p)
+			if err != nil {
+				return 0, err
+			}
+			if slashed, err := v.Slashed(); err != nil {
+				return 0, err
+			} else if slashed {
+				continue
+			}
+			effBalance, err := v.EffectiveBalance()
+			if err != nil {
+				return 0, err
+			}
+			baseReward := effBalance * Gwei(spec.BASE_REWARD_FACTOR) / balanceSqRoot / BASE_REWARDS_PER_EPOCH
+			reward += baseReward / Gwei(spec.PROPOSER_REWARD_QUOTIENT)
+		}
+	}
+	return reward, nil
+}
@@ -1,6 +1,7 @@
 package beacon
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -80,6 +81,14 @@ func (li ProposerSlashings) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
 }
 
 func (spec *Spec) ProcessProposerSlashings(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []ProposerSlashing) error {
+	return spec.ProcessProposerSlashingsWithMode(ctx, epc, state, ops, FullValidation)
+}
+
+// ProcessProposerSlashingsWithMode is like ProcessProposerSlashings, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessProposerSlashingsWithMode(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []ProposerSlashing, mode ValidationMode) error {
+	if err := checkOperationsLimit("proposer slashings", len(ops), spec.MAX_PROPOSER_SLASHINGS); err != nil {
+		return err
+	}
 	for i := range ops {
 		select {
 		case <-ctx.Done():
@@ -87,7 +96,7 @@ func (spec *Spec) ProcessProposerSlashings(ctx context.Context, epc *EpochsConte
 		default: // Don't block.
 			break
 		}
-		if err := spec.ProcessProposerSlashing(epc, state, &ops[i]); err != nil {
+		if err := spec.ProcessProposerSlashingWithMode(epc, state, &ops[i], mode); err != nil {
 			return err
 		}
 	}
@@ -95,6 +104,26 @@ func (spec *Spec) ProcessProposerSlashings(ctx context.Context, epc *EpochsConte
 }
 
 func (spec *Spec) ValidateProposerSlashingNoSignature(ps *ProposerSlashing) error {
+	return validateProposerSlashingHeaders(ps)
+}
+
+// BuildProposerSlashing builds a proposer slashing of two conflicting signed headers,
+// ordered canonically by header root. The headers must have the same slot and proposer, but a different root.
+// The headers are copied, signatures and the slashability of the proposer are not verified.
+func BuildProposerSlashing(h1, h2 *SignedBeaconBlockHeader) (*ProposerSlashing, error) {
+	hFn := tree.GetHashFn()
+	root1, root2 := h1.Message.HashTreeRoot(hFn), h2.Message.HashTreeRoot(hFn)
+	if bytes.Compare(root1[:], root2[:]) > 0 {
+		h1, h2 = h2, h1
+	}
+	ps := &ProposerSlashing{SignedHeader1: *h1, SignedHeader2: *h2}
+	if err := validateProposerSlashingHeaders(ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+func validateProposerSlashingHeaders(ps *ProposerSlashing) error {
 	// Verify header slots match
 	if a, b := ps.SignedHeader1.Message.Slot, ps.SignedHeader2.Message.Slot; a != b {
 		return fmt.Errorf("proposer slashing requires slashing headers to have the same slot: %d <> %d", a, b)
@@ -111,6 +140,10 @@ func (spec *Spec) ValidateProposerSlashingNoSignature(ps *ProposerSlashing) erro
 }
 
 func (spec *Spec) ValidateProposerSlashing(epc *EpochsContext, state *BeaconStateView, ps *ProposerSlashing) error {
+	return spec.validateProposerSlashing(epc, state, ps, true)
+}
+
+func (spec *Spec) validateProposerSlashing(epc *EpochsContext, state *BeaconStateView, ps *ProposerSlashing, verifySig bool) error {
 	if err := spec.ValidateProposerSlashingNoSignature(ps); err != nil {
 		return err
 	}
@@ -136,7 +169,10 @@ func (spec *Spec) ValidateProposerSlashing(epc *EpochsContext, state *BeaconStat
 	} else if !slashable {
 		return errors.New("proposer slashing requires proposer to be slashable")
 	}
-	domain, err := state.GetDomain(spec.DOMAIN_BEACON_PROPOSER, spec.SlotToEpoch(ps.SignedHeader1.Message.Slot))
+	if !verifySig {
+		return nil
+	}
+	domain, err := epc.GetDomain(state, spec.DOMAIN_BEACON_PROPOSER, spec.SlotToEpoch(ps.SignedHeader1.Message.Slot))
 	if err != nil {
 		return err
 	}
@@ -161,7 +197,12 @@ func (spec *Spec) ValidateProposerSlashing(epc *EpochsContext, state *BeaconStat
 }
 
 func (spec *Spec) ProcessProposerSlashing(epc *EpochsContext, state *BeaconStateView, ps *ProposerSlashing) error {
-	if err := spec.ValidateProposerSlashing(epc, state, ps); err != nil {
+	return spec.ProcessProposerSlashingWithMode(epc, state, ps, FullValidation)
+}
+
+// ProcessProposerSlashingWithMode is like ProcessProposerSlashing, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessProposerSlashingWithMode(epc *EpochsContext, state *BeaconStateView, ps *ProposerSlashing, mode ValidationMode) error {
+	if err := spec.validateProposerSlashing(epc, state, ps, mode.verifySignatures()); err != nil {
 		return err
 	}
 	return spec.SlashValidator(epc, state, ps.SignedHeader1.Message.ProposerIndex, nil)
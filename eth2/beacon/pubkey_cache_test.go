@@ -0,0 +1,124 @@
+// +build !bls_off
+
+package beacon_test
+
+import (
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/util/bls"
+	"testing"
+)
+
+func TestPubkeyCachePruneExited(t *testing.T) {
+	spec := configs.Minimal
+	state, epc, _, err := spec.KickStartFromSeed(beacon.Root{123}, 1564000000, [32]byte{1, 2, 3}, 64, spec.MAX_EFFECTIVE_BALANCE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompress := func(pc *beacon.PubkeyCache, i beacon.ValidatorIndex) *hbls.PublicKey {
+		pub, ok := pc.Pubkey(i)
+		if !ok {
+			t.Fatalf("expected pubkey of validator %d", i)
+		}
+		key, err := pub.Pubkey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	parent := epc.PubkeyCache
+	// fork out a child cache at index 6, with a different key, and trust the parent for the indices before it.
+	otherSeed := [32]byte{4, 5, 6}
+	otherSK, err := bls.DeriveSKFromPath(otherSeed[:], "m/12381/3600/0/0/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secKey hbls.SecretKey
+	if err := secKey.Deserialize(otherSK[:]); err != nil {
+		t.Fatal(err)
+	}
+	var otherPub beacon.BLSPubkey
+	copy(otherPub[:], secKey.GetPublicKey().Serialize())
+	child, err := parent.AddValidator(6, otherPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child == parent {
+		t.Fatal("expected conflicting pubkey to fork out the cache")
+	}
+
+	decompressed := make(map[beacon.ValidatorIndex]*hbls.PublicKey)
+	for _, i := range []beacon.ValidatorIndex{2, 3, 4, 7} {
+		decompressed[i] = decompress(parent, i)
+	}
+	childKey := decompress(child, 6)
+	before, _ := parent.Pubkey(3)
+	compressed := before.Compressed
+
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []beacon.ValidatorIndex{3, 6, 7} {
+		v, err := vals.Validator(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.SetWithdrawableEpoch(5); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := child.PruneExited(10, state); err != nil {
+		t.Fatal(err)
+	}
+
+	// pruned entries are reset to their compressed form, and decompress again on request
+	if key := decompress(child, 6); key == childKey {
+		t.Fatal("expected pruned entry of the child cache to be reset")
+	}
+	if key := decompress(parent, 3); key == decompressed[3] {
+		t.Fatal("expected pruned entry of the parent cache to be reset")
+	}
+	if key := decompress(child, 3); key == decompressed[3] {
+		t.Fatal("expected pruned parent entry to be reset for the child cache too")
+	}
+	// other entries keep their decompressed keys. Index 7 of the parent is not part of the child, and not pruned by it.
+	for _, i := range []beacon.ValidatorIndex{2, 4, 7} {
+		if key := decompress(parent, i); key != decompressed[i] {
+			t.Fatalf("expected decompressed pubkey of validator %d to be kept in the parent cache", i)
+		}
+	}
+	if key := decompress(child, 4); key != decompressed[4] {
+		t.Fatal("expected decompressed pubkey of the parent to be kept for the child cache")
+	}
+	// a previously retrieved pubkey is unaffected
+	if key, err := before.Pubkey(); err != nil || key != decompressed[3] {
+		t.Fatal("expected previously retrieved pubkey to be unaffected")
+	}
+
+	// pruned entries are still available in both directions
+	if pub, ok := child.Pubkey(3); !ok || pub.Compressed != compressed {
+		t.Fatal("expected pubkey of validator 3 to be kept")
+	}
+	if index, ok := child.ValidatorIndex(compressed); !ok || index != 3 {
+		t.Fatal("expected index of validator 3 to be kept")
+	}
+	if index, ok := child.ValidatorIndex(otherPub); !ok || index != 6 {
+		t.Fatal("expected index of validator 6 to be kept")
+	}
+	// indices are not reused
+	next, err := child.AddValidator(7, beacon.BLSPubkey{0xaa})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != child {
+		t.Fatal("expected new validator to be appended to the same cache")
+	}
+	if _, err := parent.AddValidator(3, beacon.BLSPubkey{0xbb}); err != nil {
+		t.Fatal(err)
+	}
+	if index, _ := parent.ValidatorIndex(compressed); index != 3 {
+		t.Fatal("expected conflicting addition to fork out, not to modify the pruned entry")
+	}
+}
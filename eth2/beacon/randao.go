@@ -110,31 +110,31 @@ func (spec *Spec) SeedRandao(seed Root) (*RandaoMixesView, error) {
 	return &RandaoMixesView{ComplexVectorView: vecView}, nil
 }
 
-func (spec *Spec) ProcessRandaoReveal(ctx context.Context, epc *EpochsContext, state *BeaconStateView, reveal BLSSignature) error {
-	select {
-	case <-ctx.Done():
-		return TransitionCancelErr
-	default: // Don't block.
-		break
-	}
-	slot, err := state.Slot()
+// GetRandaoMix returns the RANDAO mix of the given epoch, like get_randao_mix in the spec.
+func (state *BeaconStateView) GetRandaoMix(epoch Epoch) (Root, error) {
+	mixes, err := state.RandaoMixes()
 	if err != nil {
-		return err
+		return Root{}, err
 	}
-	propIndex, err := epc.GetBeaconProposer(slot)
+	return mixes.GetRandomMix(epoch)
+}
+
+// VerifyRandaoReveal checks the RANDAO reveal of the given proposer for the current epoch of the state.
+// The state is not modified.
+func (spec *Spec) VerifyRandaoReveal(epc *EpochsContext, state *BeaconStateView, proposerIndex ValidatorIndex, reveal BLSSignature) error {
+	slot, err := state.Slot()
 	if err != nil {
 		return err
 	}
-	proposerPubkey, ok := epc.PubkeyCache.Pubkey(propIndex)
+	proposerPubkey, ok := epc.PubkeyCache.Pubkey(proposerIndex)
 	if !ok {
 		return errors.New("could not find pubkey of proposer")
 	}
 	epoch := spec.SlotToEpoch(slot)
-	domain, err := state.GetDomain(spec.DOMAIN_RANDAO, epoch)
+	domain, err := epc.GetDomain(state, spec.DOMAIN_RANDAO, epoch)
 	if err != nil {
 		return err
 	}
-	// Verify RANDAO reveal
 	if !bls.Verify(
 		proposerPubkey,
 		ComputeSigningRoot(
@@ -144,6 +144,35 @@ func (spec *Spec) ProcessRandaoReveal(ctx context.Context, epc *EpochsContext, s
 	) {
 		return errors.New("randao invalid")
 	}
+	return nil
+}
+
+func (spec *Spec) ProcessRandaoReveal(ctx context.Context, epc *EpochsContext, state *BeaconStateView, reveal BLSSignature) error {
+	return spec.ProcessRandaoRevealWithMode(ctx, epc, state, reveal, FullValidation)
+}
+
+// ProcessRandaoRevealWithMode is like ProcessRandaoReveal, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessRandaoRevealWithMode(ctx context.Context, epc *EpochsContext, state *BeaconStateView, reveal BLSSignature, mode ValidationMode) error {
+	select {
+	case <-ctx.Done():
+		return TransitionCancelErr
+	default: // Don't block.
+		break
+	}
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	if mode.verifyRandao() {
+		propIndex, err := epc.GetBeaconProposer(slot)
+		if err != nil {
+			return err
+		}
+		if err := spec.VerifyRandaoReveal(epc, state, propIndex, reveal); err != nil {
+			return err
+		}
+	}
+	epoch := spec.SlotToEpoch(slot)
 	mixes, err := state.RandaoMixes()
 	if err != nil {
 		return err
@@ -0,0 +1,57 @@
+// +build !bls_off
+
+package beacon_test
+
+import (
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestVerifyRandaoReveal(t *testing.T) {
+	spec := configs.Minimal
+	keys := make([]hbls.SecretKey, 64)
+	validators := make([]beacon.KickstartValidatorData, len(keys))
+	for i := range validators {
+		keys[i].SetByCSPRNG()
+		copy(validators[i].Pubkey[:], keys[i].GetPublicKey().Serialize())
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	state, epc, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposer, err := epc.GetBeaconProposer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, err := state.GetDomain(spec.DOMAIN_RANDAO, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingRoot := beacon.ComputeSigningRoot(beacon.Epoch(0).HashTreeRoot(tree.GetHashFn()), domain)
+	var reveal beacon.BLSSignature
+	copy(reveal[:], keys[proposer].SignHash(signingRoot[:]).Serialize())
+
+	mixBefore, err := state.GetRandaoMix(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.VerifyRandaoReveal(epc, state, proposer, reveal); err != nil {
+		t.Fatal(err)
+	}
+	other := (proposer + 1) % beacon.ValidatorIndex(len(keys))
+	if err := spec.VerifyRandaoReveal(epc, state, other, reveal); err == nil {
+		t.Fatal("expected reveal to be invalid for another validator")
+	}
+	mixAfter, err := state.GetRandaoMix(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mixBefore != mixAfter {
+		t.Fatal("verification must not modify the randao mix")
+	}
+}
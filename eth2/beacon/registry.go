@@ -2,19 +2,56 @@ package beacon
 
 import (
 	"context"
+	"fmt"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
 	. "github.com/protolambda/ztyp/view"
 )
 
+// ValidatorRegistry is the raw form of the registry, to decode, encode and inspect it.
+// Hashing it always hashes every validator: use the ValidatorsRegistryView to only re-hash changed validators.
 type ValidatorRegistry []*Validator
 
 func (a *ValidatorRegistry) Deserialize(spec *Spec, dr *codec.DecodingReader) error {
-	return dr.List(func() codec.Deserializable {
-		i := len(*a)
-		*a = append(*a, &Validator{})
-		return (*a)[i]
-	}, ValidatorType.TypeByteLength(), spec.VALIDATOR_REGISTRY_LIMIT)
+	return a.DeserializeStreaming(spec, dr, func(index ValidatorIndex, v *Validator) error {
+		val := *v
+		*a = append(*a, &val)
+		return nil
+	})
+}
+
+// DeserializeStreaming decodes the registry one validator at a time, without retaining the validators.
+// The same Validator buffer is re-used for every call of cb: copy it if it needs to be retained.
+// The registry itself is not modified.
+func (a *ValidatorRegistry) DeserializeStreaming(spec *Spec, dr *codec.DecodingReader,
+	cb func(index ValidatorIndex, v *Validator) error) error {
+	scope := dr.Scope()
+	// no items to decode
+	if scope == 0 {
+		return nil
+	}
+	elemSize := ValidatorType.TypeByteLength()
+	if scope%elemSize != 0 {
+		return fmt.Errorf("scope %d is not a multiple of expected validator size: %d", scope, elemSize)
+	}
+	length := scope / elemSize
+	if length > spec.VALIDATOR_REGISTRY_LIMIT {
+		return fmt.Errorf("too many validators in registry: %d > %d", length, spec.VALIDATOR_REGISTRY_LIMIT)
+	}
+	var v Validator
+	for i := uint64(0); i < length; i++ {
+		sub, err := dr.SubScope(elemSize)
+		if err != nil {
+			return err
+		}
+		if err := v.Deserialize(sub); err != nil {
+			return err
+		}
+		if err := cb(ValidatorIndex(i), &v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (a ValidatorRegistry) Serialize(spec *Spec, w *codec.EncodingWriter) error {
@@ -45,6 +82,9 @@ func (c *Phase0Config) ValidatorsRegistry() ListTypeDef {
 	return ComplexListType(ValidatorType, c.VALIDATOR_REGISTRY_LIMIT)
 }
 
+// ValidatorsRegistryView is the tree-backed registry used by the state transition.
+// Every node caches its root, and setters only invalidate the path to the modified validator:
+// re-hashing after a change costs O(log(n)) per changed validator, not O(n).
 type ValidatorsRegistryView struct{ *ComplexListView }
 
 func AsValidatorsRegistry(v View, err error) (*ValidatorsRegistryView, error) {
@@ -80,6 +120,12 @@ func (spec *Spec) ProcessEpochRegistryUpdates(ctx context.Context, epc *EpochsCo
 			if err != nil {
 				return err
 			}
+			// Like initiate_validator_exit, don't exit a validator that is already exiting.
+			if exitEp, err := val.ExitEpoch(); err != nil {
+				return err
+			} else if exitEp != FAR_FUTURE_EPOCH {
+				continue
+			}
 			if err := val.SetExitEpoch(exitEnd); err != nil {
 				return err
 			}
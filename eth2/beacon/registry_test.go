@@ -0,0 +1,221 @@
+package beacon_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/codec"
+	"testing"
+)
+
+func TestEjectionsAfterVoluntaryExits(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	churnLimit := spec.GetChurnLimit(uint64(len(validators)))
+	// Exit more validators than fit in the churn of the first exit epoch, like voluntary exits in a block would.
+	exits := churnLimit + 2
+	for i := uint64(0); i < exits; i++ {
+		if err := spec.InitiateValidatorExit(epc, state, beacon.ValidatorIndex(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// And have some other validators ejected in the same epoch.
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ejections := churnLimit
+	for i := exits; i < exits+ejections; i++ {
+		val, err := vals.Validator(beacon.ValidatorIndex(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := val.SetEffectiveBalance(spec.EJECTION_BALANCE); err != nil {
+			t.Fatal(err)
+		}
+	}
+	process, err := spec.PrepareEpochProcess(context.Background(), epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(process.IndicesToEject)) != ejections {
+		t.Fatalf("expected %d ejections, got %d", ejections, len(process.IndicesToEject))
+	}
+	if err := spec.ProcessEpochRegistryUpdates(context.Background(), epc, process, state); err != nil {
+		t.Fatal(err)
+	}
+	vals, err = state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	churn := make(map[beacon.Epoch]uint64)
+	for i := uint64(0); i < exits+ejections; i++ {
+		val, err := vals.Validator(beacon.ValidatorIndex(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exitEp, err := val.ExitEpoch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		churn[exitEp]++
+	}
+	firstExit := spec.ComputeActivationExitEpoch(epc.CurrentEpoch.Epoch)
+	for ep := firstExit; ep < firstExit+3; ep++ {
+		expected := churnLimit
+		if ep == firstExit+2 {
+			expected = exits + ejections - 2*churnLimit
+		}
+		if churn[ep] != expected {
+			t.Errorf("expected %d validators to exit at epoch %d, got %d", expected, ep, churn[ep])
+		}
+	}
+}
+
+func TestInitiateValidatorExitWithQueue(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	queue, err := spec.ComputeExitQueueInfo(epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstExit := spec.ComputeActivationExitEpoch(epc.CurrentEpoch.Epoch)
+	churnLimit := spec.GetChurnLimit(64)
+	if queue.ExitQueueEnd != firstExit || queue.ExitQueueEndChurn != 0 || queue.ChurnLimit != churnLimit {
+		t.Fatalf("unexpected initial exit queue: %+v", queue)
+	}
+	// One more exit than the churn limit allows in an epoch.
+	exits := churnLimit + 1
+	for i := uint64(0); i < exits; i++ {
+		if err := spec.InitiateValidatorExitWithQueue(epc, state, beacon.ValidatorIndex(i), queue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Exiting validators are not queued again.
+	if err := spec.InitiateValidatorExitWithQueue(epc, state, 0, queue); err != nil {
+		t.Fatal(err)
+	}
+	if queue.ExitQueueEnd != firstExit+1 || queue.ExitQueueEndChurn != 1 {
+		t.Fatalf("unexpected exit queue after %d exits: %+v", exits, queue)
+	}
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := uint64(0); i < exits; i++ {
+		expected := firstExit
+		if i >= churnLimit {
+			expected++
+		}
+		val, err := vals.Validator(beacon.ValidatorIndex(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ep, err := val.ExitEpoch(); err != nil || ep != expected {
+			t.Fatalf("validator %d: expected exit epoch %d, got %d (err: %v)", i, expected, ep, err)
+		}
+		if ep, err := val.WithdrawableEpoch(); err != nil || ep != expected+spec.MIN_VALIDATOR_WITHDRAWABILITY_DELAY {
+			t.Fatalf("validator %d: unexpected withdrawable epoch %d (err: %v)", i, ep, err)
+		}
+	}
+	// Scanning the registry again gives the same queue.
+	rescanned, err := spec.ComputeExitQueueInfo(epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *rescanned != *queue {
+		t.Fatalf("rescanned exit queue %+v differs from updated queue %+v", rescanned, queue)
+	}
+}
+
+func TestValidatorRegistryDeserializeStreaming(t *testing.T) {
+	spec := configs.Minimal
+	var reg beacon.ValidatorRegistry
+	for i := 0; i < 5; i++ {
+		v := &beacon.Validator{
+			EffectiveBalance:           spec.MAX_EFFECTIVE_BALANCE - beacon.Gwei(i)*spec.EFFECTIVE_BALANCE_INCREMENT,
+			Slashed:                    i%2 == 1,
+			ActivationEligibilityEpoch: beacon.Epoch(i),
+			ActivationEpoch:            beacon.Epoch(i + 1),
+			ExitEpoch:                  beacon.Epoch(i + 2),
+			WithdrawableEpoch:          beacon.Epoch(i + 3),
+		}
+		v.Pubkey[0] = byte(i)
+		v.WithdrawalCredentials[31] = byte(i)
+		reg = append(reg, v)
+	}
+	var buf bytes.Buffer
+	if err := reg.Serialize(spec, codec.NewEncodingWriter(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if uint64(len(data)) != reg.ByteLength(spec) {
+		t.Fatalf("serialized %d bytes, expected %d", len(data), reg.ByteLength(spec))
+	}
+
+	var streamed []beacon.Validator
+	err := new(beacon.ValidatorRegistry).DeserializeStreaming(spec,
+		codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data))),
+		func(index beacon.ValidatorIndex, v *beacon.Validator) error {
+			if uint64(index) != uint64(len(streamed)) {
+				t.Fatalf("got index %d, expected %d", index, len(streamed))
+			}
+			streamed = append(streamed, *v)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamed) != len(reg) {
+		t.Fatalf("streamed %d validators, expected %d", len(streamed), len(reg))
+	}
+	for i, v := range streamed {
+		if v != *reg[i] {
+			t.Errorf("validator %d: got %+v, expected %+v", i, v, *reg[i])
+		}
+	}
+
+	var decoded beacon.ValidatorRegistry
+	if err := decoded.Deserialize(spec, codec.NewDecodingReader(bytes.NewReader(data), uint64(len(data)))); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(reg) {
+		t.Fatalf("decoded %d validators, expected %d", len(decoded), len(reg))
+	}
+	for i, v := range decoded {
+		if *v != *reg[i] {
+			t.Errorf("validator %d: got %+v, expected %+v", i, *v, *reg[i])
+		}
+	}
+
+	t.Run("truncated scope", func(t *testing.T) {
+		short := data[:len(data)-1]
+		err := new(beacon.ValidatorRegistry).DeserializeStreaming(spec,
+			codec.NewDecodingReader(bytes.NewReader(short), uint64(len(short))),
+			func(index beacon.ValidatorIndex, v *beacon.Validator) error {
+				return nil
+			})
+		if err == nil {
+			t.Fatal("expected error for a scope that is not a multiple of the validator size")
+		}
+	})
+	t.Run("truncated input", func(t *testing.T) {
+		// The scope claims the full registry, but the reader ends halfway the last validator.
+		short := data[:len(data)-10]
+		count := 0
+		err := new(beacon.ValidatorRegistry).DeserializeStreaming(spec,
+			codec.NewDecodingReader(bytes.NewReader(short), uint64(len(data))),
+			func(index beacon.ValidatorIndex, v *beacon.Validator) error {
+				count++
+				return nil
+			})
+		if err == nil {
+			t.Fatal("expected error for truncated input")
+		}
+		if count != len(reg)-1 {
+			t.Errorf("got %d validators before the error, expected %d", count, len(reg)-1)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package beacon
 
 import (
+	"context"
 	"encoding/binary"
+	"fmt"
 	. "github.com/protolambda/zrnt/eth2/util/hashing"
 )
 
@@ -40,6 +42,52 @@ func UnpermuteIndex(rounds uint8, index ValidatorIndex, listSize uint64, seed Ro
 	return innerPermuteIndex(Hash, rounds, index, listSize, seed, false)
 }
 
+// ComputeShuffledIndex is a direct implementation of the spec compute_shuffled_index function:
+// it returns the shuffled position of the index, with SHUFFLE_ROUND_COUNT rounds of swap-or-not.
+// It is slower than PermuteIndex, but independent of the optimized implementations, for verification.
+func (spec *Spec) ComputeShuffledIndex(index uint64, indexCount uint64, seed Root) (uint64, error) {
+	if index >= indexCount {
+		return 0, fmt.Errorf("index %d is not smaller than index count %d", index, indexCount)
+	}
+	for round := uint8(0); round < spec.SHUFFLE_ROUND_COUNT; round++ {
+		pivotHash := Hash(append(append(make([]byte, 0, 33), seed[:]...), round))
+		pivot := binary.LittleEndian.Uint64(pivotHash[:8]) % indexCount
+		flip := (pivot + indexCount - index) % indexCount
+		position := index
+		if flip > position {
+			position = flip
+		}
+		var positionWindow [4]byte
+		binary.LittleEndian.PutUint32(positionWindow[:], uint32(position/256))
+		source := Hash(append(append(append(make([]byte, 0, 37), seed[:]...), round), positionWindow[:]...))
+		if (source[(position%256)/8]>>(position%8))&1 == 1 {
+			index = flip
+		}
+	}
+	return index, nil
+}
+
+// ComputeCommitteeSlice is a direct implementation of the spec compute_committee function:
+// it returns committee number index out of count committees, of the shuffled indices.
+// Only the members of the committee are shuffled, see ComputeShuffledIndex.
+func (spec *Spec) ComputeCommitteeSlice(indices []ValidatorIndex, seed Root, index uint64, count uint64) ([]ValidatorIndex, error) {
+	if index >= count {
+		return nil, fmt.Errorf("committee index %d is not smaller than committee count %d", index, count)
+	}
+	total := uint64(len(indices))
+	start := (total * index) / count
+	end := (total * (index + 1)) / count
+	out := make([]ValidatorIndex, 0, end-start)
+	for i := start; i < end; i++ {
+		j, err := spec.ComputeShuffledIndex(i, total, seed)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, indices[j])
+	}
+	return out, nil
+}
+
 func innerPermuteIndex(hashFn HashFn, rounds uint8, input ValidatorIndex, listSize uint64, seed Root, dir bool) ValidatorIndex {
 	if rounds == 0 {
 		return input
@@ -151,24 +199,40 @@ Main differences, implemented by @protolambda:
 
 // ShuffleList shuffles a list, using the given seed for randomness.
 func ShuffleList(rounds uint8, input []ValidatorIndex, seed Root) {
-	hashFn := GetHashFn()
-	innerShuffleList(hashFn, rounds, input, seed, true)
+	_ = ShuffleListCtx(context.Background(), rounds, input, seed)
 }
 
 // UnshuffleList undoes a list shuffling using the seed of the shuffling.
 func UnshuffleList(rounds uint8, input []ValidatorIndex, seed Root) {
+	_ = UnshuffleListCtx(context.Background(), rounds, input, seed)
+}
+
+// ShuffleListCtx is like ShuffleList, but checks for cancellation between rounds.
+// If cancelled, TransitionCancelErr is returned, and the input is left partially shuffled.
+func ShuffleListCtx(ctx context.Context, rounds uint8, input []ValidatorIndex, seed Root) error {
 	hashFn := GetHashFn()
-	innerShuffleList(hashFn, rounds, input, seed, false)
+	return innerShuffleList(ctx, hashFn, rounds, input, seed, true)
+}
+
+// UnshuffleListCtx is like UnshuffleList, but checks for cancellation between rounds.
+// If cancelled, TransitionCancelErr is returned, and the input is left partially unshuffled.
+func UnshuffleListCtx(ctx context.Context, rounds uint8, input []ValidatorIndex, seed Root) error {
+	hashFn := GetHashFn()
+	return innerShuffleList(ctx, hashFn, rounds, input, seed, false)
 }
 
 // Shuffles or unshuffles, depending on the `dir` (true for shuffling, false for unshuffling
-func innerShuffleList(hashFn HashFn, rounds uint8, input []ValidatorIndex, seed Root, dir bool) {
+func innerShuffleList(ctx context.Context, hashFn HashFn, rounds uint8, input []ValidatorIndex, seed Root, dir bool) error {
 	if len(input) <= 1 || rounds == 0 {
 		// nothing to (un)shuffle
-		return
+		return nil
 	}
 	listSize := uint64(len(input))
 	buf := make([]byte, hTotalSize, hTotalSize)
+	// The source hashes of a round, one hash per block of 256 positions, laid out consecutively,
+	// so the bit of position j is simply bit (j % 8) of sources[j / 8].
+	// Only the blocks of the positions that identify a pair are hashed, and the buffer is reused across rounds.
+	sources := make([]byte, ((listSize+0xff)>>8)<<5)
 	r := uint8(0)
 	if !dir {
 		// Start at last round.
@@ -177,7 +241,40 @@ func innerShuffleList(hashFn HashFn, rounds uint8, input []ValidatorIndex, seed
 	}
 	// Seed is always the first 32 bytes of the hash input, we never have to change this part of the buffer.
 	copy(buf[:hSeedSize], seed[:])
+	// spec: source = hash(seed + int_to_bytes1(round) + int_to_bytes4(position // 256))
+	// - seed is still in 0:32 (excl., 32 bytes)
+	// - round number is still in 32
+	// - mix in the position for randomness, except the last byte of it,
+	//     which will be used later to select a bit from the resulting hash.
+	// Hashes the blocks of the positions from (incl) to (incl) into the sources buffer.
+	hashSources := func(from uint64, to uint64) {
+		for block := from >> 8; block <= to>>8; block++ {
+			binary.LittleEndian.PutUint32(buf[hPivotViewSize:], uint32(block))
+			h := hashFn(buf)
+			copy(sources[block<<5:], h[:])
+		}
+	}
+	// Swaps the pairs (i, j), with i counting up from start, and j counting down from end, until i reaches the mirror.
+	// The pair is i,j. With j being the bigger of the two, hence the "position" identifier of the pair.
+	swapPairs := func(start uint64, end uint64, mirror uint64) {
+		for i, j := start, end; i < mirror; i, j = i+1, j-1 {
+			// spec: byte = source[(position % 256) // 8], bit = (byte >> (position % 8)) % 2
+			bitV := (sources[j>>3] >> (j & 0x7)) & 0x1
+			// Swap the pair items if the bit is set, without branching: the coin-flips are random,
+			// and would be mispredicted half of the time. The mask is all ones if the bit is set, zero otherwise.
+			mask := -ValidatorIndex(bitV)
+			a, b := input[i], input[j]
+			x := (a ^ b) & mask
+			input[i], input[j] = a^x, b^x
+		}
+	}
 	for {
+		select {
+		case <-ctx.Done():
+			return TransitionCancelErr
+		default: // Don't block.
+			break
+		}
 		// spec: pivot = bytes_to_int(hash(seed + int_to_bytes1(round))[0:8]) % list_size
 		// This is the "int_to_bytes1(round)", appended to the seed.
 		buf[hSeedSize] = r
@@ -193,70 +290,21 @@ func innerShuffleList(hashFn HashFn, rounds uint8, input []ValidatorIndex, seed
 		// Print out some example even/odd sized index lists, with some even/odd pivots,
 		//  and you can deduce how the mirroring works exactly.
 		// Note that the mirror is strict enough to not consider swapping the index @mirror with itself.
-		mirror := (pivot + 1) >> 1
-		// Since we are iterating through the "positions" in order, we can just repeat the hash every 256th position.
-		// No need to pre-compute every possible hash for efficiency like in the example code.
-		// We only need it consecutively (we are going through each in reverse order however, but same thing)
-		//
-		// spec: source = hash(seed + int_to_bytes1(round) + int_to_bytes4(position // 256))
-		// - seed is still in 0:32 (excl., 32 bytes)
-		// - round number is still in 32
-		// - mix in the position for randomness, except the last byte of it,
-		//     which will be used later to select a bit from the resulting hash.
 		// We start from the pivot position, and work back to the mirror position (of the part left to the pivot).
-		// This makes us process each pear exactly once (instead of unnecessarily twice, like in the spec)
-		binary.LittleEndian.PutUint32(buf[hPivotViewSize:], uint32(pivot>>8))
-		source := hashFn(buf)
-		byteV := source[(pivot&0xff)>>3]
-		for i, j := uint64(0), pivot; i < mirror; i, j = i+1, j-1 {
-			// The pair is i,j. With j being the bigger of the two, hence the "position" identifier of the pair.
-			// Every 256th bit (aligned to j).
-			if j&0xff == 0xff {
-				// just overwrite the last part of the buffer, reuse the start (seed, round)
-				binary.LittleEndian.PutUint32(buf[hPivotViewSize:], uint32(j>>8))
-				source = hashFn(buf)
-			}
-			// Same trick with byte retrieval. Only every 8th.
-			if j&0x7 == 0x7 {
-				byteV = source[(j&0xff)>>3]
-			}
-			bitV := (byteV >> (j & 0x7)) & 0x1
-
-			if bitV == 1 {
-				// swap the pair items
-				input[i], input[j] = input[j], input[i]
-			}
+		// This makes us process each pair exactly once (instead of unnecessarily twice, like in the spec)
+		mirror := (pivot + 1) >> 1
+		if mirror > 0 {
+			// positions j range from pivot down to (excl) pivot - mirror
+			hashSources(pivot-mirror+1, pivot)
+			swapPairs(0, pivot, mirror)
 		}
 		// Now repeat, but for the part after the pivot.
+		// We start at the end, and work back to the mirror point.
 		mirror = (pivot + listSize + 1) >> 1
 		end := listSize - 1
-		// Again, seed and round input is in place, just update the position.
-		// We start at the end, and work back to the mirror point.
-		// This makes us process each pear exactly once (instead of unnecessarily twice, like in the spec)
-		binary.LittleEndian.PutUint32(buf[hPivotViewSize:], uint32(end>>8))
-		source = hashFn(buf)
-		byteV = source[(end&0xff)>>3]
-		for i, j := pivot+1, end; i < mirror; i, j = i+1, j-1 {
-			// Exact same thing (copy of above loop body)
-			//--------------------------------------------
-			// The pair is i,j. With j being the bigger of the two, hence the "position" identifier of the pair.
-			// Every 256th bit (aligned to j).
-			if j&0xff == 0xff {
-				// just overwrite the last part of the buffer, reuse the start (seed, round)
-				binary.LittleEndian.PutUint32(buf[hPivotViewSize:], uint32(j>>8))
-				source = hashFn(buf)
-			}
-			// Same trick with byte retrieval. Only every 8th.
-			if j&0x7 == 0x7 {
-				byteV = source[(j&0xff)>>3]
-			}
-			bitV := (byteV >> (j & 0x7)) & 0x1
-
-			if bitV == 1 {
-				// swap the pair items
-				input[i], input[j] = input[j], input[i]
-			}
-			//--------------------------------------------
+		if start := pivot + 1; start < mirror {
+			hashSources(end-(mirror-start)+1, end)
+			swapPairs(start, end, mirror)
 		}
 		// go forwards?
 		if dir {
@@ -273,4 +321,5 @@ func innerShuffleList(hashFn HashFn, rounds uint8, input []ValidatorIndex, seed
 			r--
 		}
 	}
+	return nil
 }
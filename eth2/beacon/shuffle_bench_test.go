@@ -44,7 +44,7 @@ func BenchmarkIndexComparison(b *testing.B) {
 }
 
 func BenchmarkShuffleList(b *testing.B) {
-	listSizes := []uint64{4000000, 40000, 400}
+	listSizes := []uint64{4000000, 500000, 40000, 400}
 
 	// "random" seed for testing. Can be any 32 bytes.
 	seed := [32]byte{123, 42}
@@ -64,3 +64,18 @@ func BenchmarkShuffleList(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkUnshuffleList(b *testing.B) {
+	listSize := uint64(500000)
+	seed := [32]byte{123, 42}
+	testIndices := make([]ValidatorIndex, listSize, listSize)
+	for i := uint64(0); i < listSize; i++ {
+		testIndices[i] = ValidatorIndex(i)
+	}
+	b.Run(fmt.Sprintf("UnshuffleList_%d", listSize), func(ib *testing.B) {
+		ib.ReportAllocs()
+		for i := 0; i < ib.N; i++ {
+			UnshuffleList(benchShuffleRounds, testIndices, seed)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package beacon_test
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestComputeShuffledIndex(t *testing.T) {
+	spec := configs.Mainnet
+	seed := beacon.Root{123, 42}
+	count := uint64(300)
+	for i := uint64(0); i < count; i++ {
+		got, err := spec.ComputeShuffledIndex(i, count, seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := beacon.PermuteIndex(spec.SHUFFLE_ROUND_COUNT, beacon.ValidatorIndex(i), count, seed); got != uint64(expected) {
+			t.Fatalf("index %d: shuffled to %d, but PermuteIndex shuffled to %d", i, got, expected)
+		}
+	}
+	if _, err := spec.ComputeShuffledIndex(count, count, seed); err == nil {
+		t.Fatal("expected error for out of range index")
+	}
+}
+
+func TestComputeCommitteeSlice(t *testing.T) {
+	spec := configs.Minimal
+	seed := beacon.Root{1, 2, 3}
+	indices := make([]beacon.BoundedIndex, 100)
+	active := make([]beacon.ValidatorIndex, 0, len(indices))
+	for i := range indices {
+		indices[i] = beacon.BoundedIndex{Index: beacon.ValidatorIndex(i), Activation: 0, Exit: beacon.FAR_FUTURE_EPOCH}
+		active = append(active, beacon.ValidatorIndex(i))
+	}
+	shep := spec.NewShufflingEpoch(indices, seed, 0)
+	committeesPerSlot := spec.CommitteeCount(uint64(len(active)))
+	count := committeesPerSlot * uint64(spec.SLOTS_PER_EPOCH)
+	for slot, slotComms := range shep.Committees {
+		for i, expected := range slotComms {
+			got, err := spec.ComputeCommitteeSlice(active, seed, uint64(slot)*committeesPerSlot+uint64(i), count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(expected) {
+				t.Fatalf("slot %d committee %d: length %d <> %d", slot, i, len(got), len(expected))
+			}
+			for j := range got {
+				if got[j] != expected[j] {
+					t.Fatalf("slot %d committee %d position %d: %d <> %d", slot, i, j, got[j], expected[j])
+				}
+			}
+		}
+	}
+}
+
+// TestShuffleListGolden checks that the list shuffling output stays bit-identical,
+// by comparing the SHA-256 of the shuffled indices (as little-endian uint64) to known digests.
+func TestShuffleListGolden(t *testing.T) {
+	cases := []struct {
+		size    uint64
+		shuffle bool
+		digest  string
+	}{
+		{2, true, "4cbbd8ca5215b8d161aec181a74b694f4e24b001d5b081dc0030ed797a8973e0"},
+		{2, false, "4cbbd8ca5215b8d161aec181a74b694f4e24b001d5b081dc0030ed797a8973e0"},
+		{3, true, "23e8d60b496f9e373ac6805ef95c5c0bc9769a2a1a60cf4e17e849ea02f89088"},
+		{3, false, "23e8d60b496f9e373ac6805ef95c5c0bc9769a2a1a60cf4e17e849ea02f89088"},
+		{255, true, "03d675ef1680813e3320706dcde12dc82c3eda1ef3ceef881a213e08dc159d10"},
+		{255, false, "e3ad31782c2ae733489cff447bf6a4a0249fb0ac57a7f8c6341af0684ac28fff"},
+		{256, true, "c75f5c47d51e01e7b11053a7785b898171c762ca2fe4346c637a274b46cccecd"},
+		{256, false, "e6146e00c3a9ed23dee78f5949bb9a0658433cd66b0eca3f70cb13151a0fe32f"},
+		{257, true, "315d6d3de95626053e4dca4ff67d647759518f0bd5ff5a95d5a916a156ed0079"},
+		{257, false, "617f3d129147691e137832049bf4dfd4b050f731d4e1cc7df026eade24393872"},
+		{1000, true, "7a16f21a2e371cd9f7fe8d379aba20f70d66e228d557cdb237109a727e904cee"},
+		{1000, false, "24e0af1aa93c5d110055372fef4197abccb79458ff23493c0fcc273f75154b51"},
+		{100000, true, "7144b5bdf2616d72af92e7a62e8caec629369e88bae8d7b734672219ef84a701"},
+		{100000, false, "76f6bc6495e66206a46114d8be2e4cb529d3c109541fa4cfd9521bed2553611e"},
+	}
+	for _, c := range cases {
+		list := make([]beacon.ValidatorIndex, c.size)
+		for i := range list {
+			list[i] = beacon.ValidatorIndex(i)
+		}
+		seed := beacon.Root{byte(c.size), 42}
+		if c.shuffle {
+			beacon.ShuffleList(90, list, seed)
+		} else {
+			beacon.UnshuffleList(90, list, seed)
+		}
+		h := sha256.New()
+		var b [8]byte
+		for _, v := range list {
+			binary.LittleEndian.PutUint64(b[:], uint64(v))
+			h.Write(b[:])
+		}
+		if digest := hex.EncodeToString(h.Sum(nil)); digest != c.digest {
+			t.Fatalf("size %d, shuffle %v: digest %s, expected %s", c.size, c.shuffle, digest, c.digest)
+		}
+	}
+}
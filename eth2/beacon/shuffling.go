@@ -1,5 +1,10 @@
 package beacon
 
+import (
+	"context"
+	"sync"
+)
+
 // With a high amount of shards, or low amount of validators,
 // some shards may not have a committee this epoch.
 type ShufflingEpoch struct {
@@ -8,6 +13,64 @@ type ShufflingEpoch struct {
 	Shuffling     []ValidatorIndex // the active validator indices, shuffled into their committee
 	// slot (vector SLOTS_PER_EPOCH) -> index of committee (< MAX_COMMITTEES_PER_SLOT) -> index of validator within committee -> validator
 	Committees [][][]ValidatorIndex // slices of Shuffling, 1 per slot. Committee can be nil slice.
+
+	// Reverse lookup of Committees, computed on first use, see AttesterDuty.
+	dutiesOnce sync.Once
+	duties     map[ValidatorIndex]attesterDuty
+}
+
+type attesterDuty struct {
+	// slot offset within the epoch
+	slot      Slot
+	committee CommitteeIndex
+	position  uint64
+}
+
+// AttesterDuty returns the slot offset within the epoch, the committee index and the position in the committee
+// of the given validator. The reverse lookup is computed once, on the first call, and then shared by later calls.
+// Ok is false if the validator is not active in the epoch.
+func (shep *ShufflingEpoch) AttesterDuty(index ValidatorIndex) (slot Slot, committeeIndex CommitteeIndex, position uint64, ok bool) {
+	shep.dutiesOnce.Do(func() {
+		// cannot be cancelled without a context
+		shep.duties, _ = shep.computeDuties(context.Background())
+	})
+	duty, ok := shep.duties[index]
+	return duty.slot, duty.committee, duty.position, ok
+}
+
+// PrecomputeDuties computes the reverse lookup of AttesterDuty ahead of time, if it was not computed yet.
+// If cancelled, nothing is stored, and the lookup is computed on first use instead.
+func (shep *ShufflingEpoch) PrecomputeDuties(ctx context.Context) error {
+	duties, err := shep.computeDuties(ctx)
+	if err != nil {
+		return err
+	}
+	shep.dutiesOnce.Do(func() {
+		shep.duties = duties
+	})
+	return nil
+}
+
+func (shep *ShufflingEpoch) computeDuties(ctx context.Context) (map[ValidatorIndex]attesterDuty, error) {
+	duties := make(map[ValidatorIndex]attesterDuty, len(shep.Shuffling))
+	for slot, slotComms := range shep.Committees {
+		select {
+		case <-ctx.Done():
+			return nil, TransitionCancelErr
+		default: // Don't block.
+			break
+		}
+		for committeeIndex, committee := range slotComms {
+			for position, v := range committee {
+				duties[v] = attesterDuty{
+					slot:      Slot(slot),
+					committee: CommitteeIndex(committeeIndex),
+					position:  uint64(position),
+				}
+			}
+		}
+	}
+	return duties, nil
 }
 
 func (spec *Spec) CommitteeCount(activeValidators uint64) uint64 {
@@ -23,6 +86,11 @@ func (spec *Spec) CommitteeCount(activeValidators uint64) uint64 {
 }
 
 func (spec *Spec) ShufflingEpoch(state *BeaconStateView, indicesBounded []BoundedIndex, epoch Epoch) (*ShufflingEpoch, error) {
+	return spec.ShufflingEpochCtx(context.Background(), state, indicesBounded, epoch)
+}
+
+// ShufflingEpochCtx is like ShufflingEpoch, but the shuffling can be cancelled with the context.
+func (spec *Spec) ShufflingEpochCtx(ctx context.Context, state *BeaconStateView, indicesBounded []BoundedIndex, epoch Epoch) (*ShufflingEpoch, error) {
 	mixes, err := state.RandaoMixes()
 	if err != nil {
 		return nil, err
@@ -31,10 +99,17 @@ func (spec *Spec) ShufflingEpoch(state *BeaconStateView, indicesBounded []Bounde
 	if err != nil {
 		return nil, err
 	}
-	return spec.NewShufflingEpoch(indicesBounded, seed, epoch), nil
+	return spec.NewShufflingEpochCtx(ctx, indicesBounded, seed, epoch)
 }
 
 func (spec *Spec) NewShufflingEpoch(indicesBounded []BoundedIndex, seed Root, epoch Epoch) *ShufflingEpoch {
+	// Without cancellation there is no error to handle.
+	shep, _ := spec.NewShufflingEpochCtx(context.Background(), indicesBounded, seed, epoch)
+	return shep
+}
+
+// NewShufflingEpochCtx is like NewShufflingEpoch, but the shuffling can be cancelled with the context.
+func (spec *Spec) NewShufflingEpochCtx(ctx context.Context, indicesBounded []BoundedIndex, seed Root, epoch Epoch) (*ShufflingEpoch, error) {
 	shep := &ShufflingEpoch{
 		Epoch: epoch,
 	}
@@ -53,7 +128,9 @@ func (spec *Spec) NewShufflingEpoch(indicesBounded []BoundedIndex, seed Root, ep
 	}
 	// shuffles the active indices into the shuffling
 	// (name is misleading, unshuffle as a list results in original indices to be traced back to their functional committee position)
-	UnshuffleList(spec.SHUFFLE_ROUND_COUNT, shep.Shuffling, seed)
+	if err := UnshuffleListCtx(ctx, spec.SHUFFLE_ROUND_COUNT, shep.Shuffling, seed); err != nil {
+		return nil, err
+	}
 
 	validatorCount := uint64(len(shep.Shuffling))
 	committeesPerSlot := spec.CommitteeCount(validatorCount)
@@ -69,5 +146,5 @@ func (spec *Spec) NewShufflingEpoch(indicesBounded []BoundedIndex, seed Root, ep
 			shep.Committees[slot] = append(shep.Committees[slot], committee)
 		}
 	}
-	return shep
+	return shep, nil
 }
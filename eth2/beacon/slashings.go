@@ -2,6 +2,7 @@ package beacon
 
 import (
 	"context"
+	"fmt"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
 	. "github.com/protolambda/ztyp/view"
@@ -95,54 +96,36 @@ func (sl *SlashingsView) Total() (sum Gwei, err error) {
 }
 
 // Slash the validator with the given index.
+// If the whistleblower index is nil, the proposer of the current slot is rewarded as whistleblower.
 func (spec *Spec) SlashValidator(epc *EpochsContext, state *BeaconStateView, slashedIndex ValidatorIndex, whistleblowerIndex *ValidatorIndex) error {
-	currentEpoch := epc.CurrentEpoch.Epoch
-	if err := spec.InitiateValidatorExit(epc, state, slashedIndex); err != nil {
-		return err
-	}
-	vals, err := state.Validators()
-	if err != nil {
-		return err
-	}
-	v, err := vals.Validator(slashedIndex)
-	if err != nil {
-		return err
-	}
-	if err := v.MakeSlashed(); err != nil {
-		return err
-	}
-	prevWithdrawalEpoch, err := v.WithdrawableEpoch()
-	if err != nil {
-		return err
-	}
-	withdrawalEpoch := currentEpoch + spec.EPOCHS_PER_SLASHINGS_VECTOR
-	if withdrawalEpoch > prevWithdrawalEpoch {
-		if err := v.SetWithdrawableEpoch(withdrawalEpoch); err != nil {
+	if whistleblowerIndex == nil {
+		slot, err := state.Slot()
+		if err != nil {
 			return err
 		}
+		propIndex, err := epc.GetBeaconProposer(slot)
+		if err != nil {
+			return err
+		}
+		whistleblowerIndex = &propIndex
 	}
+	return spec.SlashValidators(epc, state, []ValidatorIndex{slashedIndex}, *whistleblowerIndex)
+}
 
-	effectiveBalance, err := v.EffectiveBalance()
-	if err != nil {
-		return err
-	}
-
-	slashings, err := state.Slashings()
-	if err != nil {
-		return err
-	}
-	if err := slashings.AddSlashing(currentEpoch, effectiveBalance); err != nil {
-		return err
+// SlashValidators slashes all the validators with the given indices, in order,
+// rewarding the proposer of the current slot and the given whistleblower for each of them.
+// The balances and slashings vector are only loaded once, and the exit queue is only computed once,
+// instead of once per slashed validator. The registry is loaded again for every index, after the exit
+// is initiated: a view loaded before the exit would overwrite it with stale data.
+func (spec *Spec) SlashValidators(epc *EpochsContext, state *BeaconStateView, indices []ValidatorIndex, whistleblower ValidatorIndex) error {
+	if len(indices) == 0 {
+		return nil
 	}
-
+	currentEpoch := epc.CurrentEpoch.Epoch
 	bals, err := state.Balances()
 	if err != nil {
 		return err
 	}
-	if err := bals.DecreaseBalance(slashedIndex, effectiveBalance/Gwei(spec.MIN_SLASHING_PENALTY_QUOTIENT)); err != nil {
-		return err
-	}
-
 	slot, err := state.Slot()
 	if err != nil {
 		return err
@@ -151,18 +134,82 @@ func (spec *Spec) SlashValidator(epc *EpochsContext, state *BeaconStateView, sla
 	if err != nil {
 		return err
 	}
-	if whistleblowerIndex == nil {
-		whistleblowerIndex = &propIndex
-	}
-	whistleblowerReward := effectiveBalance / Gwei(spec.WHISTLEBLOWER_REWARD_QUOTIENT)
-	proposerReward := whistleblowerReward / Gwei(spec.PROPOSER_REWARD_QUOTIENT)
-	if err := bals.IncreaseBalance(propIndex, proposerReward); err != nil {
-		return err
+	// computed lazily, only if any of the validators still has to initiate an exit
+	var queue *ExitQueueInfo
+	slashedSum := Gwei(0)
+	for _, slashedIndex := range indices {
+		vals, err := state.Validators()
+		if err != nil {
+			return err
+		}
+		v, err := vals.Validator(slashedIndex)
+		if err != nil {
+			return err
+		}
+		exitEp, err := v.ExitEpoch()
+		if err != nil {
+			return err
+		}
+		if exitEp == FAR_FUTURE_EPOCH {
+			if queue == nil {
+				queue, err = spec.ComputeExitQueueInfo(epc, state)
+				if err != nil {
+					return err
+				}
+			}
+			if err := spec.InitiateValidatorExitWithQueue(epc, state, slashedIndex, queue); err != nil {
+				return err
+			}
+			// The exit is written through new views, writing to the old views would undo it.
+			vals, err = state.Validators()
+			if err != nil {
+				return err
+			}
+			v, err = vals.Validator(slashedIndex)
+			if err != nil {
+				return err
+			}
+		}
+		if err := v.MakeSlashed(); err != nil {
+			return err
+		}
+		prevWithdrawalEpoch, err := v.WithdrawableEpoch()
+		if err != nil {
+			return err
+		}
+		withdrawalEpoch := currentEpoch + spec.EPOCHS_PER_SLASHINGS_VECTOR
+		if withdrawalEpoch > prevWithdrawalEpoch {
+			if err := v.SetWithdrawableEpoch(withdrawalEpoch); err != nil {
+				return err
+			}
+		}
+
+		effectiveBalance, err := v.EffectiveBalance()
+		if err != nil {
+			return err
+		}
+		slashedSum += effectiveBalance
+
+		if err := bals.DecreaseBalance(slashedIndex, effectiveBalance/Gwei(spec.MIN_SLASHING_PENALTY_QUOTIENT)); err != nil {
+			return err
+		}
+
+		// Rewards are applied per slashed validator, to keep the same balance clamping as slashing them one by one.
+		whistleblowerReward := effectiveBalance / Gwei(spec.WHISTLEBLOWER_REWARD_QUOTIENT)
+		proposerReward := whistleblowerReward / Gwei(spec.PROPOSER_REWARD_QUOTIENT)
+		if err := bals.IncreaseBalance(propIndex, proposerReward); err != nil {
+			return err
+		}
+		if err := bals.IncreaseBalance(whistleblower, whistleblowerReward-proposerReward); err != nil {
+			return err
+		}
 	}
-	if err := bals.IncreaseBalance(*whistleblowerIndex, whistleblowerReward-proposerReward); err != nil {
+
+	slashings, err := state.Slashings()
+	if err != nil {
 		return err
 	}
-	return nil
+	return slashings.AddSlashing(currentEpoch, slashedSum)
 }
 
 func (spec *Spec) ProcessEpochSlashings(ctx context.Context, epc *EpochsContext, process *EpochProcess, state *BeaconStateView) error {
@@ -183,28 +230,78 @@ func (spec *Spec) ProcessEpochSlashings(ctx context.Context, epc *EpochsContext,
 	if err != nil {
 		return err
 	}
+	adjustedTotalSlashingBalance := spec.adjustedTotalSlashingBalance(slashingsSum, totalBalance)
+
+	for _, index := range process.IndicesToSlash {
+		slashedEffectiveBal := process.Statuses[index].Validator.EffectiveBalance
+		penalty := spec.slashingPenalty(slashedEffectiveBal, adjustedTotalSlashingBalance, totalBalance)
+		if err := state.DecreaseBalance(index, penalty); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (spec *Spec) adjustedTotalSlashingBalance(slashingsSum Gwei, totalBalance Gwei) Gwei {
 	slashingsWeight := slashingsSum * Gwei(spec.PROPORTIONAL_SLASHING_MULTIPLIER)
-	var adjustedTotalSlashingBalance Gwei
 	if totalBalance < slashingsWeight {
-		adjustedTotalSlashingBalance = totalBalance
-	} else {
-		adjustedTotalSlashingBalance = slashingsWeight
+		return totalBalance
 	}
+	return slashingsWeight
+}
 
-	bals, err := state.Balances()
+func (spec *Spec) slashingPenalty(effectiveBalance Gwei, adjustedTotalSlashingBalance Gwei, totalBalance Gwei) Gwei {
+	// Factored out from penalty numerator to avoid uint64 overflow
+	penaltyNumerator := effectiveBalance / spec.EFFECTIVE_BALANCE_INCREMENT
+	penaltyNumerator *= adjustedTotalSlashingBalance
+	return penaltyNumerator / totalBalance * spec.EFFECTIVE_BALANCE_INCREMENT
+}
+
+// ComputeSlashingPenalty computes the proportional penalty that the validator would get,
+// if it were slashed in the current epoch of the state, and no other validators were slashed after it.
+// The slashings of the state include the validator itself, if it was not slashed already.
+//
+// The penalty is based on the current total active balance, the actual penalty is applied halfway
+// the EPOCHS_PER_SLASHINGS_VECTOR withdrawal delay, and the total active balance may change until then.
+// The minimum penalty, applied immediately when slashed, is not included.
+func (spec *Spec) ComputeSlashingPenalty(state *BeaconStateView, index ValidatorIndex) (Gwei, error) {
+	slot, err := state.Slot()
 	if err != nil {
-		return err
+		return 0, err
 	}
-	for _, index := range process.IndicesToSlash {
-		// Factored out from penalty numerator to avoid uint64 overflow
-		slashedEffectiveBal := process.Statuses[index].Validator.EffectiveBalance
-		penaltyNumerator := slashedEffectiveBal / spec.EFFECTIVE_BALANCE_INCREMENT
-		penaltyNumerator *= adjustedTotalSlashingBalance
-		penalty := penaltyNumerator / totalBalance * spec.EFFECTIVE_BALANCE_INCREMENT
-
-		if err := bals.DecreaseBalance(index, penalty); err != nil {
-			return err
+	epoch := spec.SlotToEpoch(slot)
+	validators, err := state.FlatValidators()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(index) >= uint64(len(validators)) {
+		return 0, fmt.Errorf("validator index %d out of range, %d validators", index, len(validators))
+	}
+	totalBalance := Gwei(0)
+	for i := range validators {
+		if validators[i].IsActive(epoch) {
+			if totalBalance, err = totalBalance.SafeAdd(validators[i].EffectiveBalance); err != nil {
+				return 0, err
+			}
 		}
 	}
-	return nil
+	if totalBalance < spec.EFFECTIVE_BALANCE_INCREMENT {
+		totalBalance = spec.EFFECTIVE_BALANCE_INCREMENT
+	}
+	slashings, err := state.Slashings()
+	if err != nil {
+		return 0, err
+	}
+	slashingsSum, err := slashings.Total()
+	if err != nil {
+		return 0, err
+	}
+	validator := &validators[index]
+	if !validator.Slashed {
+		if slashingsSum, err = slashingsSum.SafeAdd(validator.EffectiveBalance); err != nil {
+			return 0, err
+		}
+	}
+	adjustedTotalSlashingBalance := spec.adjustedTotalSlashingBalance(slashingsSum, totalBalance)
+	return spec.slashingPenalty(validator.EffectiveBalance, adjustedTotalSlashingBalance, totalBalance), nil
 }
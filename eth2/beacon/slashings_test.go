@@ -0,0 +1,246 @@
+package beacon_test
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"math/rand"
+	"testing"
+)
+
+func TestComputeSlashingPenalty(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	total := spec.MAX_EFFECTIVE_BALANCE * beacon.Gwei(len(validators))
+	expectPenalty := func(index beacon.ValidatorIndex, slashedCount uint64) {
+		t.Helper()
+		weight := spec.MAX_EFFECTIVE_BALANCE * beacon.Gwei(slashedCount*spec.PROPORTIONAL_SLASHING_MULTIPLIER)
+		if weight > total {
+			weight = total
+		}
+		expected := spec.MAX_EFFECTIVE_BALANCE / spec.EFFECTIVE_BALANCE_INCREMENT * weight / total * spec.EFFECTIVE_BALANCE_INCREMENT
+		penalty, err := spec.ComputeSlashingPenalty(state, index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if penalty != expected {
+			t.Fatalf("validator %d: expected penalty %d, got %d", index, expected, penalty)
+		}
+	}
+	// Only the validator itself would be slashed.
+	expectPenalty(0, 1)
+	if err := spec.SlashValidator(epc, state, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	// The validator is not counted twice after it is slashed.
+	expectPenalty(0, 1)
+	// Another validator is slashed on top of the previous slashing.
+	expectPenalty(1, 2)
+
+	if _, err := spec.ComputeSlashingPenalty(state, beacon.ValidatorIndex(len(validators))); err == nil {
+		t.Fatal("expected error for unknown validator")
+	}
+}
+
+func TestSlashValidators(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	proposer, err := epc.GetBeaconProposer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	whistleblower := (proposer + 1) % 64
+	// One more than the churn limit, the last validator exits an epoch later.
+	churnLimit := spec.GetChurnLimit(64)
+	var indices []beacon.ValidatorIndex
+	for i := beacon.ValidatorIndex(0); uint64(len(indices)) <= churnLimit; i++ {
+		if i != proposer && i != whistleblower {
+			indices = append(indices, i)
+		}
+	}
+	if err := spec.SlashValidators(epc, state, indices, whistleblower); err != nil {
+		t.Fatal(err)
+	}
+
+	exitEpoch := spec.ComputeActivationExitEpoch(0)
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bals, err := state.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	penalty := spec.MAX_EFFECTIVE_BALANCE / beacon.Gwei(spec.MIN_SLASHING_PENALTY_QUOTIENT)
+	for j, i := range indices {
+		expectedExit := exitEpoch
+		if uint64(j) >= churnLimit {
+			expectedExit++
+		}
+		expectedWithdrawable := expectedExit + spec.MIN_VALIDATOR_WITHDRAWABILITY_DELAY
+		if ep := spec.EPOCHS_PER_SLASHINGS_VECTOR; ep > expectedWithdrawable {
+			expectedWithdrawable = ep
+		}
+		v, err := vals.Validator(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slashed, err := v.Slashed(); err != nil || !slashed {
+			t.Fatalf("validator %d: expected to be slashed (err: %v)", i, err)
+		}
+		if ep, err := v.ExitEpoch(); err != nil || ep != expectedExit {
+			t.Fatalf("validator %d: expected exit epoch %d, got %d (err: %v)", i, expectedExit, ep, err)
+		}
+		if ep, err := v.WithdrawableEpoch(); err != nil || ep != expectedWithdrawable {
+			t.Fatalf("validator %d: expected withdrawable epoch %d, got %d (err: %v)", i, expectedWithdrawable, ep, err)
+		}
+		if bal, err := bals.GetBalance(i); err != nil || bal != spec.MAX_EFFECTIVE_BALANCE-penalty {
+			t.Fatalf("validator %d: unexpected balance %d (err: %v)", i, bal, err)
+		}
+	}
+	count := beacon.Gwei(len(indices))
+	whistleblowerReward := spec.MAX_EFFECTIVE_BALANCE / beacon.Gwei(spec.WHISTLEBLOWER_REWARD_QUOTIENT)
+	proposerReward := whistleblowerReward / beacon.Gwei(spec.PROPOSER_REWARD_QUOTIENT)
+	if bal, err := bals.GetBalance(proposer); err != nil || bal != spec.MAX_EFFECTIVE_BALANCE+count*proposerReward {
+		t.Fatalf("unexpected proposer balance %d (err: %v)", bal, err)
+	}
+	if bal, err := bals.GetBalance(whistleblower); err != nil || bal != spec.MAX_EFFECTIVE_BALANCE+count*(whistleblowerReward-proposerReward) {
+		t.Fatalf("unexpected whistleblower balance %d (err: %v)", bal, err)
+	}
+	slashings, err := state.Slashings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total, err := slashings.GetSlashingsValue(0); err != nil || total != count*spec.MAX_EFFECTIVE_BALANCE {
+		t.Fatalf("unexpected slashings total %d (err: %v)", total, err)
+	}
+
+	// Without whistleblower, the proposer gets the full whistleblower reward.
+	state, epc = testState(t, spec)
+	if err := spec.SlashValidator(epc, state, indices[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	vals, err = state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := vals.Validator(indices[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep, err := v.ExitEpoch(); err != nil || ep != exitEpoch {
+		t.Fatalf("expected exit epoch %d, got %d (err: %v)", exitEpoch, ep, err)
+	}
+	bals, err = state.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal, err := bals.GetBalance(proposer); err != nil || bal != spec.MAX_EFFECTIVE_BALANCE+whistleblowerReward {
+		t.Fatalf("unexpected proposer balance %d (err: %v)", bal, err)
+	}
+}
+
+func TestFindSlashableAttestationPairs(t *testing.T) {
+	att := func(source beacon.Epoch, target beacon.Epoch, root byte, indices ...beacon.ValidatorIndex) *beacon.IndexedAttestation {
+		return &beacon.IndexedAttestation{
+			AttestingIndices: indices,
+			Data: beacon.AttestationData{
+				BeaconBlockRoot: beacon.Root{root},
+				Source:          beacon.Checkpoint{Epoch: source},
+				Target:          beacon.Checkpoint{Epoch: target},
+			},
+		}
+	}
+	atts := []*beacon.IndexedAttestation{
+		att(0, 1, 1, 1, 2),
+		att(0, 1, 2, 2, 3), // double vote with the first, by validator 2
+		att(1, 2, 1, 5),
+		att(0, 3, 1, 5), // surrounds the third, by validator 5
+		att(0, 1, 1, 1), // same data as the first, not slashable
+		att(0, 1, 3, 4), // double vote, but no overlap
+	}
+	pairs := beacon.FindSlashableAttestationPairs(atts)
+	expected := [][2]*beacon.IndexedAttestation{{atts[0], atts[1]}, {atts[3], atts[2]}}
+	if len(pairs) != len(expected) {
+		t.Fatalf("expected %d pairs, got %d", len(expected), len(pairs))
+	}
+	for i := range expected {
+		if pairs[i] != expected[i] {
+			t.Fatalf("pair %d: unexpected attestations", i)
+		}
+		if !beacon.IsSlashableAttestationData(&pairs[i][0].Data, &pairs[i][1].Data) {
+			t.Fatalf("pair %d is not slashable in order", i)
+		}
+	}
+}
+
+func TestBuildProposerSlashing(t *testing.T) {
+	a := &beacon.SignedBeaconBlockHeader{Message: beacon.BeaconBlockHeader{Slot: 10, ProposerIndex: 3, StateRoot: beacon.Root{1}}}
+	b := &beacon.SignedBeaconBlockHeader{Message: beacon.BeaconBlockHeader{Slot: 10, ProposerIndex: 3, StateRoot: beacon.Root{2}}}
+	ps1, err := beacon.BuildProposerSlashing(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps2, err := beacon.BuildProposerSlashing(b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *ps1 != *ps2 {
+		t.Fatal("expected canonical order of the headers, regardless of argument order")
+	}
+	if _, err := beacon.BuildProposerSlashing(a, a); err == nil {
+		t.Fatal("expected error for equal headers")
+	}
+	c := *b
+	c.Message.Slot = 11
+	if _, err := beacon.BuildProposerSlashing(a, &c); err == nil {
+		t.Fatal("expected error for headers of different slots")
+	}
+	c = *b
+	c.Message.ProposerIndex = 4
+	if _, err := beacon.BuildProposerSlashing(a, &c); err == nil {
+		t.Fatal("expected error for headers of different proposers")
+	}
+}
+
+func TestFindSlashableAttestationPairsRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1234))
+	atts := make([]*beacon.IndexedAttestation, 200)
+	for i := range atts {
+		source := beacon.Epoch(rng.Intn(10))
+		atts[i] = &beacon.IndexedAttestation{
+			AttestingIndices: []beacon.ValidatorIndex{beacon.ValidatorIndex(rng.Intn(8))},
+			Data: beacon.AttestationData{
+				BeaconBlockRoot: beacon.Root{byte(rng.Intn(2))},
+				Source:          beacon.Checkpoint{Epoch: source},
+				Target:          beacon.Checkpoint{Epoch: source + beacon.Epoch(rng.Intn(10))},
+			},
+		}
+	}
+	// Compare all pairs, without the sorting and early exits.
+	expected := make(map[[2]*beacon.IndexedAttestation]struct{})
+	for i, a := range atts {
+		for _, b := range atts[i+1:] {
+			if a.AttestingIndices[0] != b.AttestingIndices[0] {
+				continue
+			}
+			if beacon.IsDoubleVote(&a.Data, &b.Data) || beacon.IsSurroundVote(&a.Data, &b.Data) {
+				expected[[2]*beacon.IndexedAttestation{a, b}] = struct{}{}
+			} else if beacon.IsSurroundVote(&b.Data, &a.Data) {
+				expected[[2]*beacon.IndexedAttestation{b, a}] = struct{}{}
+			}
+		}
+	}
+	if len(expected) == 0 {
+		t.Fatal("expected random attestations to contain slashable pairs")
+	}
+	pairs := beacon.FindSlashableAttestationPairs(atts)
+	if len(pairs) != len(expected) {
+		t.Fatalf("expected %d pairs, got %d", len(expected), len(pairs))
+	}
+	for i, pair := range pairs {
+		if _, ok := expected[pair]; !ok {
+			t.Fatalf("unexpected pair %d", i)
+		}
+	}
+}
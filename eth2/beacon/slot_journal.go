@@ -0,0 +1,131 @@
+package beacon
+
+import (
+	"fmt"
+)
+
+// SlotJournalEntry records the state values that the processing of a single slot overwrites.
+type SlotJournalEntry struct {
+	// The slot of the state before processing.
+	Slot Slot
+	// Previous entry of the state roots ring at the slot.
+	PrevStateRoot Root
+	// Previous entry of the block roots ring at the slot.
+	PrevBlockRoot Root
+	// True if the state root of the latest block header was empty, and filled in by the slot processing.
+	FilledHeaderStateRoot bool
+	// True if the slot was the last of an epoch, and the epoch transition ran after it.
+	EpochTransition bool
+}
+
+// SlotProcessingJournal records the changes of slot processing, to be able to roll back a state by a few slots,
+// without replaying from an older state. See Spec.ProcessSlotsJournaled and BeaconStateView.ApplyJournalReverse.
+//
+// Only slot processing is covered: the epoch transition changes too much of the state to journal,
+// and cannot be reversed. Slot processing does not modify the randao mixes: those only change with blocks
+// and in the epoch transition.
+type SlotProcessingJournal struct {
+	Entries []SlotJournalEntry
+}
+
+func (j *SlotProcessingJournal) record(state *BeaconStateView, slot Slot) error {
+	stateRoots, err := state.StateRoots()
+	if err != nil {
+		return err
+	}
+	prevStateRoot, err := stateRoots.GetRoot(slot)
+	if err != nil {
+		return err
+	}
+	blockRoots, err := state.BlockRoots()
+	if err != nil {
+		return err
+	}
+	prevBlockRoot, err := blockRoots.GetRoot(slot)
+	if err != nil {
+		return err
+	}
+	latestHeader, err := state.LatestBlockHeader()
+	if err != nil {
+		return err
+	}
+	headerStateRoot, err := latestHeader.StateRoot()
+	if err != nil {
+		return err
+	}
+	j.Entries = append(j.Entries, SlotJournalEntry{
+		Slot:                  slot,
+		PrevStateRoot:         prevStateRoot,
+		PrevBlockRoot:         prevBlockRoot,
+		FilledHeaderStateRoot: headerStateRoot == (Root{}),
+	})
+	return nil
+}
+
+// ApplyJournalReverse rolls the state back to the target slot, by undoing the journaled slot processing.
+// The journal entries of the undone slots are removed from the journal.
+//
+// The journal must cover every slot from the target up to the current slot of the state,
+// none of those slots may be followed by an epoch transition, and no block may have been processed after the target slot.
+// The state is not modified if the rollback is not possible.
+// The epochs context does not have to change, as the rollback stays within the same epoch.
+func (state *BeaconStateView) ApplyJournalReverse(journal *SlotProcessingJournal, targetSlot Slot) error {
+	slot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	if targetSlot > slot {
+		return fmt.Errorf("cannot roll back state at slot %d to later slot %d", slot, targetSlot)
+	}
+	latestHeader, err := state.LatestBlockHeader()
+	if err != nil {
+		return err
+	}
+	headerSlot, err := latestHeader.Slot()
+	if err != nil {
+		return err
+	}
+	if headerSlot > targetSlot {
+		return fmt.Errorf("cannot roll back to slot %d, block of slot %d was processed since", targetSlot, headerSlot)
+	}
+	count := uint64(slot - targetSlot)
+	if uint64(len(journal.Entries)) < count {
+		return fmt.Errorf("journal has %d entries, cannot roll back %d slots", len(journal.Entries), count)
+	}
+	undo := journal.Entries[uint64(len(journal.Entries))-count:]
+	for i, entry := range undo {
+		if entry.Slot != targetSlot+Slot(i) {
+			return fmt.Errorf("journal entry for slot %d does not match state, expected slot %d", entry.Slot, targetSlot+Slot(i))
+		}
+		if entry.EpochTransition {
+			return fmt.Errorf("cannot roll back the epoch transition after slot %d", entry.Slot)
+		}
+	}
+	stateRoots, err := state.StateRoots()
+	if err != nil {
+		return err
+	}
+	blockRoots, err := state.BlockRoots()
+	if err != nil {
+		return err
+	}
+	for i := len(undo) - 1; i >= 0; i-- {
+		entry := &undo[i]
+		if err := stateRoots.SetRoot(entry.Slot, entry.PrevStateRoot); err != nil {
+			return err
+		}
+		if err := blockRoots.SetRoot(entry.Slot, entry.PrevBlockRoot); err != nil {
+			return err
+		}
+		if entry.FilledHeaderStateRoot {
+			if err := latestHeader.SetStateRoot(Root{}); err != nil {
+				return err
+			}
+		}
+	}
+	if err := state.SetSlot(targetSlot); err != nil {
+		return err
+	}
+	journal.Entries = journal.Entries[:uint64(len(journal.Entries))-count]
+	return nil
+}
@@ -0,0 +1,114 @@
+package beacon
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// LoadSpecConfigYAML parses a spec config, in the YAML format of the consensus-spec configs, into a new Spec.
+// The phase0 keys are required, phase1 keys are optional, and may be included in the same document.
+// Unknown keys are rejected, to not silently ignore typos or settings of forks that are not supported.
+// The resulting spec is checked for internal consistency, e.g. to not divide by zero during the transition.
+func LoadSpecConfigYAML(r io.Reader) (*Spec, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	var keys map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %v", err)
+	}
+	var missing []string
+	for _, key := range yamlKeys(reflect.TypeOf(Phase0Config{})) {
+		if _, ok := keys[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("config is missing required keys: %s", strings.Join(missing, ", "))
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var spec Spec
+	if err := dec.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %v", err)
+	}
+	if err := spec.validateConfig(); err != nil {
+		return nil, fmt.Errorf("invalid config: %v", err)
+	}
+	return &spec, nil
+}
+
+func yamlKeys(typ reflect.Type) (out []string) {
+	for i := 0; i < typ.NumField(); i++ {
+		name := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+		if name != "" && name != "-" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+func (spec *Spec) validateConfig() error {
+	nonZero := []struct {
+		name  string
+		value uint64
+	}{
+		{"MAX_COMMITTEES_PER_SLOT", spec.MAX_COMMITTEES_PER_SLOT},
+		{"TARGET_COMMITTEE_SIZE", spec.TARGET_COMMITTEE_SIZE},
+		{"CHURN_LIMIT_QUOTIENT", spec.CHURN_LIMIT_QUOTIENT},
+		{"HYSTERESIS_QUOTIENT", spec.HYSTERESIS_QUOTIENT},
+		{"TARGET_AGGREGATORS_PER_COMMITTEE", spec.TARGET_AGGREGATORS_PER_COMMITTEE},
+		{"EFFECTIVE_BALANCE_INCREMENT", uint64(spec.EFFECTIVE_BALANCE_INCREMENT)},
+		{"SECONDS_PER_SLOT", uint64(spec.SECONDS_PER_SLOT)},
+		{"SLOTS_PER_EPOCH", uint64(spec.SLOTS_PER_EPOCH)},
+		{"EPOCHS_PER_ETH1_VOTING_PERIOD", uint64(spec.EPOCHS_PER_ETH1_VOTING_PERIOD)},
+		{"SLOTS_PER_HISTORICAL_ROOT", uint64(spec.SLOTS_PER_HISTORICAL_ROOT)},
+		{"EPOCHS_PER_HISTORICAL_VECTOR", uint64(spec.EPOCHS_PER_HISTORICAL_VECTOR)},
+		{"EPOCHS_PER_SLASHINGS_VECTOR", uint64(spec.EPOCHS_PER_SLASHINGS_VECTOR)},
+		{"BASE_REWARD_FACTOR", spec.BASE_REWARD_FACTOR},
+		{"WHISTLEBLOWER_REWARD_QUOTIENT", spec.WHISTLEBLOWER_REWARD_QUOTIENT},
+		{"PROPOSER_REWARD_QUOTIENT", spec.PROPOSER_REWARD_QUOTIENT},
+		{"INACTIVITY_PENALTY_QUOTIENT", spec.INACTIVITY_PENALTY_QUOTIENT},
+		{"MIN_SLASHING_PENALTY_QUOTIENT", spec.MIN_SLASHING_PENALTY_QUOTIENT},
+	}
+	for _, v := range nonZero {
+		if v.value == 0 {
+			return fmt.Errorf("%s must not be zero", v.name)
+		}
+	}
+	if spec.TARGET_COMMITTEE_SIZE > spec.MAX_VALIDATORS_PER_COMMITTEE {
+		return fmt.Errorf("TARGET_COMMITTEE_SIZE %d exceeds MAX_VALIDATORS_PER_COMMITTEE %d",
+			spec.TARGET_COMMITTEE_SIZE, spec.MAX_VALIDATORS_PER_COMMITTEE)
+	}
+	if spec.MIN_SEED_LOOKAHEAD > spec.MAX_SEED_LOOKAHEAD {
+		return fmt.Errorf("MIN_SEED_LOOKAHEAD %d exceeds MAX_SEED_LOOKAHEAD %d",
+			spec.MIN_SEED_LOOKAHEAD, spec.MAX_SEED_LOOKAHEAD)
+	}
+	if spec.EPOCHS_PER_HISTORICAL_VECTOR <= spec.MIN_SEED_LOOKAHEAD {
+		return fmt.Errorf("EPOCHS_PER_HISTORICAL_VECTOR %d must exceed MIN_SEED_LOOKAHEAD %d",
+			spec.EPOCHS_PER_HISTORICAL_VECTOR, spec.MIN_SEED_LOOKAHEAD)
+	}
+	if spec.SLOTS_PER_HISTORICAL_ROOT%spec.SLOTS_PER_EPOCH != 0 {
+		return fmt.Errorf("SLOTS_PER_HISTORICAL_ROOT %d is not a multiple of SLOTS_PER_EPOCH %d",
+			spec.SLOTS_PER_HISTORICAL_ROOT, spec.SLOTS_PER_EPOCH)
+	}
+	if spec.MAX_EFFECTIVE_BALANCE%spec.EFFECTIVE_BALANCE_INCREMENT != 0 {
+		return fmt.Errorf("MAX_EFFECTIVE_BALANCE %d is not a multiple of EFFECTIVE_BALANCE_INCREMENT %d",
+			spec.MAX_EFFECTIVE_BALANCE, spec.EFFECTIVE_BALANCE_INCREMENT)
+	}
+	if spec.EJECTION_BALANCE > spec.MAX_EFFECTIVE_BALANCE {
+		return fmt.Errorf("EJECTION_BALANCE %d exceeds MAX_EFFECTIVE_BALANCE %d",
+			spec.EJECTION_BALANCE, spec.MAX_EFFECTIVE_BALANCE)
+	}
+	if spec.MIN_DEPOSIT_AMOUNT > spec.MAX_EFFECTIVE_BALANCE {
+		return fmt.Errorf("MIN_DEPOSIT_AMOUNT %d exceeds MAX_EFFECTIVE_BALANCE %d",
+			spec.MIN_DEPOSIT_AMOUNT, spec.MAX_EFFECTIVE_BALANCE)
+	}
+	return nil
+}
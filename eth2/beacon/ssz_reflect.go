@@ -0,0 +1,341 @@
+package beacon
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/protolambda/ztyp/bitfields"
+	"github.com/protolambda/ztyp/tree"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SSZMarshal encodes a value with SSZ, using reflection to determine the SSZ type. Intended for prototyping,
+// e.g. new fork objects, the hand-written Serialize implementations are much faster.
+//
+// Structs are containers, with the exported fields in order of declaration. Fields are typed by their Go kind:
+// bool and unsigned integers are basic types, arrays are vectors, slices are lists. Pointers are followed.
+// Lists must declare their limit with a struct tag, e.g. `ssz:"max=16"`, and a byte slice can be
+// declared a bitlist with `ssz:"bitlist,max=2048"`. Fields tagged with `ssz:"-"` are skipped.
+// Lists nested directly in lists or vectors are not supported, wrap them in a container.
+func SSZMarshal(v interface{}) ([]byte, error) {
+	return sszEncode(reflect.ValueOf(v), sszTag{})
+}
+
+// SSZHashTreeRoot computes the hash-tree-root of a value, typed like SSZMarshal does.
+func SSZHashTreeRoot(v interface{}, hFn tree.HashFn) (Root, error) {
+	return sszHashTreeRoot(reflect.ValueOf(v), sszTag{}, hFn)
+}
+
+type sszTag struct {
+	bitlist bool
+	hasMax  bool
+	max     uint64
+}
+
+func parseSSZTag(field reflect.StructField) (tag sszTag, skip bool, err error) {
+	raw, ok := field.Tag.Lookup("ssz")
+	if !ok {
+		return tag, false, nil
+	}
+	if raw == "-" {
+		return tag, true, nil
+	}
+	for _, part := range strings.Split(raw, ",") {
+		switch {
+		case part == "bitlist":
+			tag.bitlist = true
+		case strings.HasPrefix(part, "max="):
+			tag.max, err = strconv.ParseUint(strings.TrimPrefix(part, "max="), 10, 64)
+			if err != nil {
+				return tag, false, fmt.Errorf("field %s has invalid ssz max: %v", field.Name, err)
+			}
+			tag.hasMax = true
+		default:
+			return tag, false, fmt.Errorf("field %s has unknown ssz tag option %q", field.Name, part)
+		}
+	}
+	return tag, false, nil
+}
+
+// sszFields returns the indices and tags of the struct fields that are part of the container.
+func sszFields(t reflect.Type) (indices []int, tags []sszTag, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		tag, skip, err := parseSSZTag(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		if skip {
+			continue
+		}
+		indices = append(indices, i)
+		tags = append(tags, tag)
+	}
+	return indices, tags, nil
+}
+
+func sszBasicSize(k reflect.Kind) (uint64, bool) {
+	switch k {
+	case reflect.Bool, reflect.Uint8:
+		return 1, true
+	case reflect.Uint16:
+		return 2, true
+	case reflect.Uint32:
+		return 4, true
+	case reflect.Uint64:
+		return 8, true
+	default:
+		return 0, false
+	}
+}
+
+// sszFixedSize returns the size of a type, or false if the type is variable-size.
+func sszFixedSize(t reflect.Type) (uint64, bool, error) {
+	if size, ok := sszBasicSize(t.Kind()); ok {
+		return size, true, nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return sszFixedSize(t.Elem())
+	case reflect.Array:
+		size, fixed, err := sszFixedSize(t.Elem())
+		if err != nil || !fixed {
+			return 0, fixed, err
+		}
+		return size * uint64(t.Len()), true, nil
+	case reflect.Slice:
+		return 0, false, nil
+	case reflect.Struct:
+		indices, _, err := sszFields(t)
+		if err != nil {
+			return 0, false, err
+		}
+		total := uint64(0)
+		for _, i := range indices {
+			size, fixed, err := sszFixedSize(t.Field(i).Type)
+			if err != nil || !fixed {
+				return 0, fixed, err
+			}
+			total += size
+		}
+		return total, true, nil
+	default:
+		return 0, false, fmt.Errorf("type %s is not supported by SSZ", t)
+	}
+}
+
+func sszEncodeBasic(v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return []byte{1}
+		}
+		return []byte{0}
+	case reflect.Uint8:
+		return []byte{uint8(v.Uint())}
+	case reflect.Uint16:
+		out := make([]byte, 2)
+		binary.LittleEndian.PutUint16(out, uint16(v.Uint()))
+		return out
+	case reflect.Uint32:
+		out := make([]byte, 4)
+		binary.LittleEndian.PutUint32(out, uint32(v.Uint()))
+		return out
+	default:
+		out := make([]byte, 8)
+		binary.LittleEndian.PutUint64(out, v.Uint())
+		return out
+	}
+}
+
+// sszEncodeSeries encodes the elements of a vector or list, or the fields of a container,
+// with offsets for the variable-size elements.
+func sszEncodeSeries(elems []reflect.Value, tags []sszTag) ([]byte, error) {
+	parts := make([][]byte, len(elems))
+	variable := make([]bool, len(elems))
+	fixedLen := uint64(0)
+	for i, el := range elems {
+		enc, err := sszEncode(el, tags[i])
+		if err != nil {
+			return nil, err
+		}
+		_, fixed, err := sszFixedSize(el.Type())
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = enc
+		if fixed {
+			fixedLen += uint64(len(enc))
+		} else {
+			variable[i] = true
+			fixedLen += 4
+		}
+	}
+	out := make([]byte, 0, fixedLen)
+	offset := fixedLen
+	for i, part := range parts {
+		if !variable[i] {
+			out = append(out, part...)
+			continue
+		}
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], uint32(offset))
+		out = append(out, tmp[:]...)
+		offset += uint64(len(part))
+	}
+	for i, part := range parts {
+		if variable[i] {
+			out = append(out, part...)
+		}
+	}
+	return out, nil
+}
+
+func sszElems(v reflect.Value) ([]reflect.Value, []sszTag) {
+	elems := make([]reflect.Value, v.Len())
+	for i := range elems {
+		elems[i] = v.Index(i)
+	}
+	return elems, make([]sszTag, len(elems))
+}
+
+func sszEncode(v reflect.Value, tag sszTag) ([]byte, error) {
+	if _, ok := sszBasicSize(v.Kind()); ok {
+		return sszEncodeBasic(v), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, fmt.Errorf("cannot encode nil %s", v.Type())
+		}
+		return sszEncode(v.Elem(), tag)
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice {
+			if !tag.hasMax {
+				return nil, fmt.Errorf("list %s has no ssz max tag", v.Type())
+			}
+			length := uint64(v.Len())
+			if tag.bitlist {
+				if v.Type().Elem().Kind() != reflect.Uint8 {
+					return nil, fmt.Errorf("bitlist %s must be a byte slice", v.Type())
+				}
+				if length == 0 {
+					return nil, fmt.Errorf("bitlist %s is missing the delimiter bit", v.Type())
+				}
+				length = bitfields.BitlistLen(v.Bytes())
+			}
+			if length > tag.max {
+				return nil, fmt.Errorf("list %s has length %d, exceeding the limit %d", v.Type(), length, tag.max)
+			}
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			out := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(out), v)
+			return out, nil
+		}
+		elems, tags := sszElems(v)
+		return sszEncodeSeries(elems, tags)
+	case reflect.Struct:
+		indices, tags, err := sszFields(v.Type())
+		if err != nil {
+			return nil, err
+		}
+		fields := make([]reflect.Value, len(indices))
+		for i, fi := range indices {
+			fields[i] = v.Field(fi)
+		}
+		return sszEncodeSeries(fields, tags)
+	default:
+		return nil, fmt.Errorf("type %s is not supported by SSZ", v.Type())
+	}
+}
+
+// sszPackedRoot merkleizes packed basic values, with the limit in number of elements.
+func sszPackedRoot(packed []byte, elemSize uint64, limit uint64, hFn tree.HashFn) Root {
+	chunks := (uint64(len(packed)) + 31) / 32
+	return hFn.ChunksHTR(func(i uint64) (out Root) {
+		copy(out[:], packed[i*32:])
+		return
+	}, chunks, (limit*elemSize+31)/32)
+}
+
+func sszHashTreeRoot(v reflect.Value, tag sszTag, hFn tree.HashFn) (Root, error) {
+	if _, ok := sszBasicSize(v.Kind()); ok {
+		var out Root
+		copy(out[:], sszEncodeBasic(v))
+		return out, nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return Root{}, fmt.Errorf("cannot hash nil %s", v.Type())
+		}
+		return sszHashTreeRoot(v.Elem(), tag, hFn)
+	case reflect.Array, reflect.Slice:
+		isList := v.Kind() == reflect.Slice
+		length := uint64(v.Len())
+		limit := length
+		if isList {
+			if !tag.hasMax {
+				return Root{}, fmt.Errorf("list %s has no ssz max tag", v.Type())
+			}
+			limit = tag.max
+		}
+		if isList && tag.bitlist {
+			if v.Type().Elem().Kind() != reflect.Uint8 || length == 0 {
+				return Root{}, fmt.Errorf("bitlist %s must be a byte slice with a delimiter bit", v.Type())
+			}
+			return hFn.BitListHTR(v.Bytes(), limit), nil
+		}
+		if length > limit {
+			return Root{}, fmt.Errorf("list %s has length %d, exceeding the limit %d", v.Type(), length, limit)
+		}
+		var root Root
+		if elemSize, ok := sszBasicSize(v.Type().Elem().Kind()); ok {
+			packed, err := sszEncode(v, tag)
+			if err != nil {
+				return Root{}, err
+			}
+			root = sszPackedRoot(packed, elemSize, limit, hFn)
+		} else {
+			roots := make([]Root, length)
+			for i := range roots {
+				r, err := sszHashTreeRoot(v.Index(i), sszTag{}, hFn)
+				if err != nil {
+					return Root{}, err
+				}
+				roots[i] = r
+			}
+			root = tree.Merkleize(hFn, length, limit, func(i uint64) Root {
+				return roots[i]
+			})
+		}
+		if isList {
+			root = hFn.Mixin(root, length)
+		}
+		return root, nil
+	case reflect.Struct:
+		indices, tags, err := sszFields(v.Type())
+		if err != nil {
+			return Root{}, err
+		}
+		roots := make([]Root, len(indices))
+		for i, fi := range indices {
+			r, err := sszHashTreeRoot(v.Field(fi), tags[i], hFn)
+			if err != nil {
+				return Root{}, err
+			}
+			roots[i] = r
+		}
+		count := uint64(len(roots))
+		return tree.Merkleize(hFn, count, count, func(i uint64) Root {
+			return roots[i]
+		}), nil
+	default:
+		return Root{}, fmt.Errorf("type %s is not supported by SSZ", v.Type())
+	}
+}
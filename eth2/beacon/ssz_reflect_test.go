@@ -0,0 +1,118 @@
+package beacon_test
+
+import (
+	"bytes"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/codec"
+	"github.com/protolambda/ztyp/tree"
+	"github.com/protolambda/ztyp/view"
+	"testing"
+)
+
+type sszCodec interface {
+	Serialize(w *codec.EncodingWriter) error
+	HashTreeRoot(hFn tree.HashFn) beacon.Root
+}
+
+type specSSZCodec interface {
+	Serialize(spec *beacon.Spec, w *codec.EncodingWriter) error
+	HashTreeRoot(spec *beacon.Spec, hFn tree.HashFn) beacon.Root
+}
+
+// Mirrors of the spec-dependent types, with the mainnet limits as tags.
+type reflectIndexedAttestation struct {
+	AttestingIndices []beacon.ValidatorIndex `ssz:"max=2048"`
+	Data             beacon.AttestationData
+	Signature        beacon.BLSSignature
+}
+
+type reflectAttestation struct {
+	AggregationBits beacon.CommitteeBits `ssz:"bitlist,max=2048"`
+	Data            beacon.AttestationData
+	Signature       beacon.BLSSignature
+	// Not part of the SSZ type
+	Note string `ssz:"-"`
+}
+
+func TestSSZReflect(t *testing.T) {
+	spec := configs.Mainnet
+	hFn := tree.GetHashFn()
+	data := beacon.AttestationData{
+		Slot:            123,
+		Index:           4,
+		BeaconBlockRoot: beacon.Root{1},
+		Source:          beacon.Checkpoint{Epoch: 2, Root: beacon.Root{2}},
+		Target:          beacon.Checkpoint{Epoch: 3, Root: beacon.Root{3}},
+	}
+	bits := make(beacon.CommitteeBits, 2)
+	bits.SetBit(9, true) // delimiter bit
+	bits.SetBit(3, true)
+	indexed := &beacon.IndexedAttestation{AttestingIndices: beacon.CommitteeIndices{3, 7, 42}, Data: data, Signature: beacon.BLSSignature{5}}
+	att := &beacon.Attestation{AggregationBits: bits, Data: data, Signature: beacon.BLSSignature{6}}
+	exit := &beacon.VoluntaryExit{Epoch: 10, ValidatorIndex: 20}
+
+	check := func(name string, v interface{}, expectedRoot beacon.Root, serialize func(w *codec.EncodingWriter) error) {
+		var buf bytes.Buffer
+		if err := serialize(codec.NewEncodingWriter(&buf)); err != nil {
+			t.Fatal(err)
+		}
+		enc, err := beacon.SSZMarshal(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(enc, buf.Bytes()) {
+			t.Errorf("%s: encoding mismatch: %x <> %x", name, enc, buf.Bytes())
+		}
+		root, err := beacon.SSZHashTreeRoot(v, hFn)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if root != expectedRoot {
+			t.Errorf("%s: root mismatch: %s <> %s", name, root, expectedRoot)
+		}
+	}
+	for name, v := range map[string]sszCodec{
+		"voluntary exit":        exit,
+		"signed voluntary exit": &beacon.SignedVoluntaryExit{Message: *exit, Signature: beacon.BLSSignature{7}},
+		"attestation data":      &data,
+	} {
+		check(name, v, v.HashTreeRoot(hFn), v.Serialize)
+	}
+	for name, c := range map[string]struct {
+		v   interface{}
+		ref specSSZCodec
+	}{
+		"indexed attestation": {
+			v:   &reflectIndexedAttestation{AttestingIndices: indexed.AttestingIndices, Data: data, Signature: indexed.Signature},
+			ref: indexed,
+		},
+		"attestation": {
+			v:   reflectAttestation{AggregationBits: bits, Data: data, Signature: att.Signature, Note: "ignored"},
+			ref: att,
+		},
+	} {
+		ref := c.ref
+		check(name, c.v, ref.HashTreeRoot(spec, hFn), func(w *codec.EncodingWriter) error {
+			return ref.Serialize(spec, w)
+		})
+	}
+
+	// The exit type is derived from the views as well
+	exitView := beacon.VoluntaryExitType.New()
+	if err := exitView.Set(0, view.Uint64View(exit.Epoch)); err != nil {
+		t.Fatal(err)
+	}
+	if err := exitView.Set(1, view.Uint64View(exit.ValidatorIndex)); err != nil {
+		t.Fatal(err)
+	}
+	if root, err := beacon.SSZHashTreeRoot(exit, hFn); err != nil {
+		t.Fatal(err)
+	} else if viewRoot := exitView.HashTreeRoot(hFn); root != viewRoot {
+		t.Fatalf("root mismatch with VoluntaryExitType view: %s <> %s", root, viewRoot)
+	}
+
+	if _, err := beacon.SSZMarshal(struct{ Indices []uint64 }{}); err == nil {
+		t.Fatal("expected error for list without limit")
+	}
+}
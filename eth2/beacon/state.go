@@ -2,6 +2,8 @@ package beacon
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
 	. "github.com/protolambda/ztyp/view"
@@ -253,6 +255,39 @@ func (state *BeaconStateView) Balances() (*RegistryBalancesView, error) {
 	return AsRegistryBalances(state.Get(_stateBalances))
 }
 
+// IncreaseBalance adds delta to the balance of the validator.
+func (state *BeaconStateView) IncreaseBalance(index ValidatorIndex, delta Gwei) error {
+	bals, err := state.Balances()
+	if err != nil {
+		return err
+	}
+	return bals.IncreaseBalance(index, delta)
+}
+
+// BalancesIterator returns a readonly iterator over the balances, in validator index order.
+func (state *BeaconStateView) BalancesIterator() (*BalanceIter, error) {
+	bals, err := state.Balances()
+	if err != nil {
+		return nil, err
+	}
+	return bals.IterBalances(), nil
+}
+
+// DecreaseBalance subtracts delta from the balance of the validator, clamped at zero.
+func (state *BeaconStateView) DecreaseBalance(index ValidatorIndex, delta Gwei) error {
+	bals, err := state.Balances()
+	if err != nil {
+		return err
+	}
+	return bals.DecreaseBalance(index, delta)
+}
+
+// EstimateStateSize returns the exact SSZ byte length of the state, including the offsets
+// of the variable-length fields, without serializing it.
+func (spec *Spec) EstimateStateSize(state *BeaconStateView) (uint64, error) {
+	return state.ValueByteLength()
+}
+
 func (state *BeaconStateView) RandaoMixes() (*RandaoMixesView, error) {
 	return AsRandaoMixes(state.Get(_stateRandaoMixes))
 }
@@ -289,6 +324,55 @@ func (state *BeaconStateView) FinalizedCheckpoint() (*CheckpointView, error) {
 	return AsCheckPoint(state.Get(_stateFinalizedCheckpoint))
 }
 
+// ProveField builds a merkle proof of the node at the given generalized index in the state tree.
+// The proof is the list of sibling roots, ordered from the bottom (sibling of the node) to the top,
+// and can be verified against the state hash-tree-root.
+func (state *BeaconStateView) ProveField(gindex tree.Gindex) ([]Root, error) {
+	iter, depth := gindex.BitIter()
+	branch := make([]Root, depth, depth)
+	hFn := tree.GetHashFn()
+	node := state.Backing()
+	for d := depth; d > 0; d-- {
+		right, ok := iter.Next()
+		if !ok {
+			return nil, fmt.Errorf("gindex ended early at depth %d", depth-d)
+		}
+		if node.IsLeaf() {
+			return nil, fmt.Errorf("gindex points below a leaf node at depth %d", depth-d)
+		}
+		leftNode, err := node.Left()
+		if err != nil {
+			return nil, err
+		}
+		rightNode, err := node.Right()
+		if err != nil {
+			return nil, err
+		}
+		if right {
+			branch[d-1] = leftNode.MerkleRoot(hFn)
+			node = rightNode
+		} else {
+			branch[d-1] = rightNode.MerkleRoot(hFn)
+			node = leftNode
+		}
+	}
+	return branch, nil
+}
+
+// fieldGindex returns the generalized index of the state field at the given field index.
+func (state *BeaconStateView) fieldGindex(i uint64) (tree.Gindex64, error) {
+	return tree.ToGindex64(i, tree.CoverDepth(state.FieldCount()))
+}
+
+// ProveFinalizedCheckpoint builds a merkle proof of the finalized checkpoint in the state, see ProveField.
+func (state *BeaconStateView) ProveFinalizedCheckpoint() ([]Root, error) {
+	gindex, err := state.fieldGindex(_stateFinalizedCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	return state.ProveField(gindex)
+}
+
 func (state *BeaconStateView) IsValidIndex(index ValidatorIndex) (bool, error) {
 	vals, err := state.Validators()
 	if err != nil {
@@ -314,3 +398,31 @@ func (state *BeaconStateView) Raw(spec *Spec) (*BeaconState, error) {
 	}
 	return &raw, nil
 }
+
+// View converts the flattened native Go structure into a tree-structured state.
+func (v *BeaconState) View(spec *Spec) (*BeaconStateView, error) {
+	var buf bytes.Buffer
+	if err := v.Serialize(spec, codec.NewEncodingWriter(&buf)); err != nil {
+		return nil, err
+	}
+	return AsBeaconStateView(spec.BeaconState().Deserialize(
+		codec.NewDecodingReader(bytes.NewReader(buf.Bytes()), uint64(len(buf.Bytes())))))
+}
+
+// ToJSON encodes the state as JSON, with the consensus-spec field names, quoted integers and hex-encoded bytes.
+func (state *BeaconStateView) ToJSON(spec *Spec) ([]byte, error) {
+	raw, err := state.Raw(spec)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// BeaconStateFromJSON decodes a JSON state, as encoded by BeaconStateView.ToJSON, into a tree-structured state.
+func (spec *Spec) BeaconStateFromJSON(data []byte) (*BeaconStateView, error) {
+	var raw BeaconState
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw.View(spec)
+}
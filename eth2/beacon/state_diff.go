@@ -0,0 +1,182 @@
+package beacon
+
+import "github.com/protolambda/ztyp/tree"
+
+// StateFieldDiff is a top-level state field that differs between two states.
+type StateFieldDiff struct {
+	// Name of the state field, e.g. "validators".
+	Path string `json:"path"`
+	// The changed elements, only for the validators and balances. Sorted.
+	// Includes the elements that only exist in one of the two states.
+	Indices []ValidatorIndex `json:"indices,omitempty"`
+}
+
+// DiffStates compares the top-level fields of two states, and for the validators and balances also the elements.
+// Subtrees with equal roots are not compared any further: the costs scale with the changes, not the size of the state.
+func DiffStates(a, b *BeaconStateView) ([]StateFieldDiff, error) {
+	hFn := tree.GetHashFn()
+	var out []StateFieldDiff
+	for i, field := range a.Fields {
+		fa, err := a.Get(uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		fb, err := b.Get(uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		if fa.HashTreeRoot(hFn) == fb.HashTreeRoot(hFn) {
+			continue
+		}
+		diff := StateFieldDiff{Path: field.Name}
+		switch uint64(i) {
+		case _stateValidators:
+			diff.Indices, err = diffValidators(a, b, hFn)
+		case _stateBalances:
+			diff.Indices, err = diffBalances(a, b, hFn)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, diff)
+	}
+	return out, nil
+}
+
+// diffListLengths returns the shortest length, and appends the indices that only exist in the longer list.
+func diffListLengths(a, b interface{ Length() (uint64, error) }) (minLen uint64, extra []ValidatorIndex, err error) {
+	lenA, err := a.Length()
+	if err != nil {
+		return 0, nil, err
+	}
+	lenB, err := b.Length()
+	if err != nil {
+		return 0, nil, err
+	}
+	minLen, maxLen := lenA, lenB
+	if minLen > maxLen {
+		minLen, maxLen = maxLen, minLen
+	}
+	for i := minLen; i < maxLen; i++ {
+		extra = append(extra, ValidatorIndex(i))
+	}
+	return minLen, extra, nil
+}
+
+func diffValidators(a, b *BeaconStateView, hFn tree.HashFn) ([]ValidatorIndex, error) {
+	valsA, err := a.Validators()
+	if err != nil {
+		return nil, err
+	}
+	valsB, err := b.Validators()
+	if err != nil {
+		return nil, err
+	}
+	minLen, extra, err := diffListLengths(valsA, valsB)
+	if err != nil {
+		return nil, err
+	}
+	var out []ValidatorIndex
+	err = diffListContents(valsA.BackingNode, valsB.BackingNode, tree.CoverDepth(valsA.ListLimit), hFn,
+		func(index uint64) error {
+			if index < minLen {
+				out = append(out, ValidatorIndex(index))
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return append(out, extra...), nil
+}
+
+func diffBalances(a, b *BeaconStateView, hFn tree.HashFn) ([]ValidatorIndex, error) {
+	balsA, err := a.Balances()
+	if err != nil {
+		return nil, err
+	}
+	balsB, err := b.Balances()
+	if err != nil {
+		return nil, err
+	}
+	minLen, extra, err := diffListLengths(balsA, balsB)
+	if err != nil {
+		return nil, err
+	}
+	var out []ValidatorIndex
+	perChunk := balsA.ElementsPerBottomNode()
+	err = diffListContents(balsA.BackingNode, balsB.BackingNode, tree.CoverDepth(balsA.BottomNodeLimit()), hFn,
+		func(chunk uint64) error {
+			// Multiple balances per chunk, find the ones that changed.
+			for i := chunk * perChunk; i < (chunk+1)*perChunk && i < minLen; i++ {
+				balA, err := balsA.GetBalance(ValidatorIndex(i))
+				if err != nil {
+					return err
+				}
+				balB, err := balsB.GetBalance(ValidatorIndex(i))
+				if err != nil {
+					return err
+				}
+				if balA != balB {
+					out = append(out, ValidatorIndex(i))
+				}
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return append(out, extra...), nil
+}
+
+// diffListContents calls onDiff, in order, with the index of every bottom node that differs between the
+// contents of the two list backings. The contents are the left subtree of the backing, next to the length mix-in.
+func diffListContents(a, b tree.Node, depth uint8, hFn tree.HashFn, onDiff func(index uint64) error) error {
+	contentsA, err := a.Left()
+	if err != nil {
+		return err
+	}
+	contentsB, err := b.Left()
+	if err != nil {
+		return err
+	}
+	return diffNodes(contentsA, contentsB, depth, 0, hFn, onDiff)
+}
+
+func diffNodes(a, b tree.Node, depth uint8, index uint64, hFn tree.HashFn, onDiff func(index uint64) error) error {
+	if a.MerkleRoot(hFn) == b.MerkleRoot(hFn) {
+		return nil
+	}
+	if depth == 0 {
+		return onDiff(index)
+	}
+	leftA, rightA, err := diffChildren(a, depth)
+	if err != nil {
+		return err
+	}
+	leftB, rightB, err := diffChildren(b, depth)
+	if err != nil {
+		return err
+	}
+	if err := diffNodes(leftA, leftB, depth-1, index<<1, hFn, onDiff); err != nil {
+		return err
+	}
+	return diffNodes(rightA, rightB, depth-1, (index<<1)|1, hFn, onDiff)
+}
+
+func diffChildren(n tree.Node, depth uint8) (left tree.Node, right tree.Node, err error) {
+	// Zero subtrees are summarized as a single zero-hash leaf node.
+	if n.IsLeaf() {
+		z := tree.ZeroNode(uint32(depth) - 1)
+		return z, z, nil
+	}
+	left, err = n.Left()
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err = n.Right()
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
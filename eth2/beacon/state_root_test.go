@@ -0,0 +1,159 @@
+// +build !bls_off
+
+package beacon_test
+
+import (
+	"context"
+	"errors"
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+	"time"
+)
+
+type mismatchObserver struct {
+	err  *beacon.StateRootMismatchError
+	diff []beacon.StateFieldDiff
+}
+
+func (o *mismatchObserver) OnEpochStep(name string, dur time.Duration) {}
+
+func (o *mismatchObserver) OnStateRootMismatch(err *beacon.StateRootMismatchError, blockChanges []beacon.StateFieldDiff) {
+	o.err = err
+	o.diff = blockChanges
+}
+
+// signedTestBlock builds an empty block for the given slot on top of the state, signed by the proposer.
+// The state root is only valid if validStateRoot is true.
+func signedTestBlock(t *testing.T, spec *beacon.Spec, keys []hbls.SecretKey, epc *beacon.EpochsContext, state *beacon.BeaconStateView, slot beacon.Slot, validStateRoot bool) *beacon.SignedBeaconBlock {
+	ctx := context.Background()
+	// prepare the block on a copy, the transition processes the slots of the original state
+	pre, err := beacon.AsBeaconStateView(state.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	preEpc := epc.Clone()
+	if err := spec.ProcessSlots(ctx, preEpc, pre, slot); err != nil {
+		t.Fatal(err)
+	}
+	proposer, err := preEpc.GetBeaconProposer(slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latestHeader, err := pre.LatestBlockHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth1Data, err := pre.Eth1Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth1, err := eth1Data.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hFn := tree.GetHashFn()
+	epoch := spec.SlotToEpoch(slot)
+	randaoDom, err := pre.GetDomain(spec.DOMAIN_RANDAO, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	randaoRoot := beacon.ComputeSigningRoot(epoch.HashTreeRoot(hFn), randaoDom)
+	block := &beacon.SignedBeaconBlock{
+		Message: beacon.BeaconBlock{
+			Slot:          slot,
+			ProposerIndex: proposer,
+			ParentRoot:    latestHeader.HashTreeRoot(hFn),
+			StateRoot:     beacon.Root{0xaa},
+			Body:          beacon.BeaconBlockBody{Eth1Data: eth1},
+		},
+	}
+	copy(block.Message.Body.RandaoReveal[:], keys[proposer].SignHash(randaoRoot[:]).Serialize())
+	if validStateRoot {
+		if err := spec.ProcessBlock(ctx, preEpc, pre, &block.Message); err != nil {
+			t.Fatal(err)
+		}
+		block.Message.StateRoot = pre.HashTreeRoot(hFn)
+	}
+	proposerDom, err := pre.GetDomain(spec.DOMAIN_BEACON_PROPOSER, epoch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockRoot := beacon.ComputeSigningRoot(block.Message.HashTreeRoot(spec, hFn), proposerDom)
+	copy(block.Signature[:], keys[proposer].SignHash(blockRoot[:]).Serialize())
+	return block
+}
+
+func testKeysAndState(t *testing.T, spec *beacon.Spec) ([]hbls.SecretKey, *beacon.BeaconStateView, *beacon.EpochsContext) {
+	keys := make([]hbls.SecretKey, 64)
+	validators := make([]beacon.KickstartValidatorData, len(keys))
+	for i := range validators {
+		keys[i].SetByCSPRNG()
+		copy(validators[i].Pubkey[:], keys[i].GetPublicKey().Serialize())
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	state, epc, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys, state, epc
+}
+
+func TestStateRootMismatch(t *testing.T) {
+	spec := configs.Minimal
+	keys, state, epc := testKeysAndState(t, spec)
+	ctx := context.Background()
+	slot := beacon.Slot(1)
+	hFn := tree.GetHashFn()
+	block := signedTestBlock(t, spec, keys, epc, state, slot, false)
+
+	var obs mismatchObserver
+	err := spec.StateTransitionObserved(ctx, epc, state, block, true, &obs)
+	var mismatchErr *beacon.StateRootMismatchError
+	if !errors.As(err, &mismatchErr) {
+		t.Fatalf("expected state root mismatch error, got: %v", err)
+	}
+	if mismatchErr.Slot != slot || mismatchErr.Expected != block.Message.StateRoot || mismatchErr.Computed != state.HashTreeRoot(hFn) {
+		t.Fatalf("unexpected mismatch details: %v", mismatchErr)
+	}
+	if obs.err != mismatchErr {
+		t.Fatal("expected observer to be notified of the mismatch")
+	}
+	changed := make(map[string]bool)
+	for _, d := range obs.diff {
+		changed[d.Path] = true
+	}
+	for _, field := range []string{"latest_block_header", "randao_mixes", "eth1_data_votes"} {
+		if !changed[field] {
+			t.Fatalf("expected block to change %s, got diff: %v", field, obs.diff)
+		}
+	}
+}
+
+func TestVerifyAndProcessBlock(t *testing.T) {
+	spec := configs.Minimal
+	keys, state, epc := testKeysAndState(t, spec)
+	ctx := context.Background()
+	block := signedTestBlock(t, spec, keys, epc, state, 1, true)
+
+	// an invalid signature is rejected, on a copy of the state
+	tampered := *block
+	tampered.Signature[0] ^= 0xff
+	stateCopy, err := beacon.AsBeaconStateView(state.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spec.VerifyAndProcessBlock(ctx, epc.Clone(), stateCopy, &tampered); err == nil {
+		t.Fatal("expected block with invalid signature to be rejected")
+	}
+
+	if err := spec.VerifyAndProcessBlock(ctx, epc, state, block); err != nil {
+		t.Fatal(err)
+	}
+	if root := state.HashTreeRoot(tree.GetHashFn()); root != block.Message.StateRoot {
+		t.Fatalf("expected post-state root %s, got %s", block.Message.StateRoot, root)
+	}
+}
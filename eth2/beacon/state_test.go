@@ -0,0 +1,362 @@
+package beacon_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/util/merkle"
+	"github.com/protolambda/ztyp/tree"
+	"reflect"
+	"testing"
+)
+
+func TestBeaconStateJSON(t *testing.T) {
+	spec := configs.Minimal
+	state, _ := testState(t, spec)
+	data, err := state.ToJSON(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"genesis_time":"1564000000"`)) {
+		t.Fatal("expected quoted genesis time")
+	}
+	decoded, err := spec.BeaconStateFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hFn := tree.GetHashFn()
+	if a, b := state.HashTreeRoot(hFn), decoded.HashTreeRoot(hFn); a != b {
+		t.Fatalf("state root changed after JSON round trip: %s <> %s", a, b)
+	}
+}
+
+func TestBeaconStateProveField(t *testing.T) {
+	spec := configs.Minimal
+	validators := make([]beacon.KickstartValidatorData, 64)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE + beacon.Gwei(i)
+	}
+	state, _, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finalized, err := state.FinalizedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := finalized.Set(&beacon.Checkpoint{Epoch: 3, Root: beacon.Root{42}}); err != nil {
+		t.Fatal(err)
+	}
+	hFn := tree.GetHashFn()
+	stateRoot := state.HashTreeRoot(hFn)
+
+	proof, err := state.ProveFinalizedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 21 fields, depth 5, finalized checkpoint is field 20
+	if !merkle.VerifyMerkleBranch(finalized.HashTreeRoot(hFn), proof, 5, 20, stateRoot) {
+		t.Fatal("invalid finalized checkpoint proof")
+	}
+
+	// Balances are field 12, a list of uint64, packed 4 per chunk.
+	// Go into the contents (left of the length mix-in), to the chunk of validator 5.
+	chunkDepth := uint64(tree.CoverDepth(spec.VALIDATOR_REGISTRY_LIMIT / 4))
+	depth := 5 + 1 + chunkDepth
+	index := ((uint64(12) << 1) << chunkDepth) | 1
+	proof, err = state.ProveField(tree.Gindex64((uint64(1) << depth) | index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunk tree.Root
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(chunk[i*8:], uint64(validators[4+i].Balance))
+	}
+	if !merkle.VerifyMerkleBranch(chunk, proof, depth, index, stateRoot) {
+		t.Fatal("invalid balance proof")
+	}
+}
+
+func TestDiffStates(t *testing.T) {
+	spec := configs.Minimal
+	a, _ := testState(t, spec)
+	b, err := beacon.AsBeaconStateView(a.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, err := beacon.DiffStates(a, b); err != nil {
+		t.Fatal(err)
+	} else if len(diff) != 0 {
+		t.Fatalf("expected no differences, got %v", diff)
+	}
+	if err := b.SetSlot(3); err != nil {
+		t.Fatal(err)
+	}
+	bals, err := b.Balances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []beacon.ValidatorIndex{5, 6, 41} {
+		if err := bals.SetBalance(i, 123); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vals, err := b.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := vals.Validator(17)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := val.SetExitEpoch(10); err != nil {
+		t.Fatal(err)
+	}
+	// A validator that only exists in one of the states.
+	extra := beacon.Validator{Pubkey: beacon.BLSPubkey{0xff}}
+	if err := vals.Append(extra.View()); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := beacon.DiffStates(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []beacon.StateFieldDiff{
+		{Path: "slot"},
+		{Path: "validators", Indices: []beacon.ValidatorIndex{17, 64}},
+		{Path: "balances", Indices: []beacon.ValidatorIndex{5, 6, 41}},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("unexpected diff: %v", diff)
+	}
+}
+
+func TestFlatValidators(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, _ := testState(t, spec)
+	vals, err := state.Validators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	val, err := vals.Validator(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := val.SetExitEpoch(2); err != nil {
+		t.Fatal(err)
+	}
+	flat, err := state.FlatValidators()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flat) != len(validators) {
+		t.Fatalf("expected %d validators, got %d", len(validators), len(flat))
+	}
+	for i := range flat {
+		val, err := vals.Validator(beacon.ValidatorIndex(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		active, err := spec.IsActive(val, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flat[i].IsActive(2) != active {
+			t.Fatalf("validator %d: flat activity does not match", i)
+		}
+	}
+	if flat[3].IsActive(2) || !flat[3].IsActive(1) {
+		t.Fatal("expected validator 3 to exit at epoch 2")
+	}
+}
+
+func TestBalanceIncreaseDecrease(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, _ := testState(t, spec)
+	expectBalance := func(index beacon.ValidatorIndex, expected beacon.Gwei) {
+		bals, err := state.Balances()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bal, err := bals.GetBalance(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bal != expected {
+			t.Fatalf("validator %d: expected balance %d, got %d", index, expected, bal)
+		}
+	}
+	if err := state.IncreaseBalance(1, 100); err != nil {
+		t.Fatal(err)
+	}
+	expectBalance(1, spec.MAX_EFFECTIVE_BALANCE+100)
+	if err := state.DecreaseBalance(1, 300); err != nil {
+		t.Fatal(err)
+	}
+	expectBalance(1, spec.MAX_EFFECTIVE_BALANCE-200)
+	// penalties larger than the balance clamp at zero
+	if err := state.DecreaseBalance(2, spec.MAX_EFFECTIVE_BALANCE+1); err != nil {
+		t.Fatal(err)
+	}
+	expectBalance(2, 0)
+	if err := state.DecreaseBalance(beacon.ValidatorIndex(len(validators)), 1); err == nil {
+		t.Fatal("expected error for unknown validator")
+	}
+}
+
+func TestFlatValidatorStatus(t *testing.T) {
+	far := beacon.FAR_FUTURE_EPOCH
+	testCases := []struct {
+		v        beacon.FlatValidator
+		epoch    beacon.Epoch
+		expected string
+	}{
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEligibilityEpoch: far, ActivationEpoch: far, ExitEpoch: far, WithdrawableEpoch: far}, 3, "pending_initialized"},
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEligibilityEpoch: 4, ActivationEpoch: far, ExitEpoch: far, WithdrawableEpoch: far}, 3, "pending_queued"}, // eligibility set, but in the future
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEligibilityEpoch: 2, ActivationEpoch: far, ExitEpoch: far, WithdrawableEpoch: far}, 3, "pending_queued"},
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEligibilityEpoch: 1, ActivationEpoch: 3, ExitEpoch: far, WithdrawableEpoch: far}, 3, "active_ongoing"},
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEpoch: 0, ExitEpoch: 5, WithdrawableEpoch: 10}, 3, "active_exiting"},
+		{beacon.FlatValidator{EffectiveBalance: 1, Slashed: true, ActivationEpoch: 0, ExitEpoch: 5, WithdrawableEpoch: 10}, 3, "active_slashed"},
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEpoch: 0, ExitEpoch: 5, WithdrawableEpoch: 10}, 5, "exited_unslashed"},
+		{beacon.FlatValidator{EffectiveBalance: 1, Slashed: true, ActivationEpoch: 0, ExitEpoch: 5, WithdrawableEpoch: 10}, 9, "exited_slashed"},
+		{beacon.FlatValidator{EffectiveBalance: 1, ActivationEpoch: 0, ExitEpoch: 5, WithdrawableEpoch: 10}, 10, "withdrawal_possible"},
+		{beacon.FlatValidator{EffectiveBalance: 0, ActivationEpoch: 0, ExitEpoch: 5, WithdrawableEpoch: 10}, 12, "withdrawal_done"},
+	}
+	for i, tc := range testCases {
+		if got := tc.v.Status(tc.epoch).String(); got != tc.expected {
+			t.Errorf("case %d: expected status %s, got %s", i, tc.expected, got)
+		}
+	}
+}
+
+func TestProcessDepositIndex(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	topUp := beacon.Deposit{Data: beacon.DepositData{
+		Pubkey: validators[5].Pubkey,
+		Amount: 1000,
+	}}
+	index, isNew, err := spec.ProcessDeposit(epc, state, &topUp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 5 || isNew {
+		t.Fatalf("expected top-up of validator 5, got index %d, new: %v", index, isNew)
+	}
+	newDep := beacon.Deposit{Data: beacon.DepositData{
+		Pubkey: beacon.BLSPubkey{0xaa},
+		Amount: spec.MAX_EFFECTIVE_BALANCE,
+	}}
+	index, isNew, err = spec.ProcessDeposit(epc, state, &newDep, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 64 || !isNew {
+		t.Fatalf("expected new validator 64, got index %d, new: %v", index, isNew)
+	}
+	if cached, ok := epc.PubkeyCache.ValidatorIndex(newDep.Data.Pubkey); !ok || cached != index {
+		t.Fatalf("pubkey cache not updated: %d, %v", cached, ok)
+	}
+}
+
+func TestBalancesIterator(t *testing.T) {
+	spec := configs.Minimal
+	validators := make([]beacon.KickstartValidatorData, 64)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE + beacon.Gwei(i)
+	}
+	state, _, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter, err := state.BalancesIterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for {
+		i, bal, ok, err := iter.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		if i != beacon.ValidatorIndex(count) {
+			t.Fatalf("expected index %d, got %d", count, i)
+		}
+		if bal != validators[i].Balance {
+			t.Fatalf("validator %d: expected balance %d, got %d", i, validators[i].Balance, bal)
+		}
+		count++
+	}
+	if count != len(validators) {
+		t.Fatalf("expected %d balances, got %d", len(validators), count)
+	}
+}
+
+func TestForkDigest(t *testing.T) {
+	spec := configs.Mainnet
+	var genesisValidatorsRoot beacon.Root
+	if err := genesisValidatorsRoot.UnmarshalText([]byte("0x4b363db94e286120d76eb905340fdd4e54bfe9f06bf33ff6cf5ad27f511bfe95")); err != nil {
+		t.Fatal(err)
+	}
+	// the phase0 mainnet fork digest
+	if digest := spec.ComputeForkDigest(spec.GENESIS_FORK_VERSION, genesisValidatorsRoot); digest != (beacon.ForkDigest{0xb5, 0x30, 0x3f, 0x2a}) {
+		t.Fatalf("unexpected mainnet fork digest: %s", digest)
+	}
+
+	spec = configs.Minimal
+	state, _ := testState(t, spec)
+	root, err := state.GenesisValidatorsRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := spec.CurrentForkDigest(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := beacon.ComputeForkDigest(spec.GENESIS_FORK_VERSION, root); digest != expected {
+		t.Fatalf("expected fork digest %s, got %s", expected, digest)
+	}
+}
+
+func TestSigningRoot(t *testing.T) {
+	spec := configs.Minimal
+	state, _ := testState(t, spec)
+	hFn := tree.GetHashFn()
+
+	block := &beacon.BeaconBlock{Slot: 3, ProposerIndex: 5}
+	dom, err := state.GetDomain(spec.DOMAIN_BEACON_PROPOSER, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := spec.SigningRoot(state, block, spec.DOMAIN_BEACON_PROPOSER, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := beacon.ComputeSigningRoot(block.HashTreeRoot(spec, hFn), dom); root != expected {
+		t.Fatalf("block signing root %s, expected %s", root, expected)
+	}
+
+	exit := &beacon.VoluntaryExit{Epoch: 2, ValidatorIndex: 7}
+	dom, err = state.GetDomain(spec.DOMAIN_VOLUNTARY_EXIT, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err = beacon.SigningRoot(state, exit, spec.DOMAIN_VOLUNTARY_EXIT, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := beacon.ComputeSigningRoot(exit.HashTreeRoot(hFn), dom); root != expected {
+		t.Fatalf("exit signing root %s, expected %s", root, expected)
+	}
+}
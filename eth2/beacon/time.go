@@ -10,6 +10,7 @@ import (
 // Unix timestamp
 type Timestamp Uint64View
 
+// TimeToSlot returns the slot that the time is in. Times before genesis are in slot 0.
 func (spec *Spec) TimeToSlot(t Timestamp, genesisTime Timestamp) Slot {
 	if t < genesisTime {
 		return 0
@@ -17,6 +18,33 @@ func (spec *Spec) TimeToSlot(t Timestamp, genesisTime Timestamp) Slot {
 	return Slot((t - genesisTime) / spec.SECONDS_PER_SLOT)
 }
 
+// CurrentSlot returns the slot at the given time, e.g. the wall clock. Times before genesis are in slot 0.
+func (spec *Spec) CurrentSlot(genesisTime Timestamp, now Timestamp) Slot {
+	return spec.TimeToSlot(now, genesisTime)
+}
+
+// SlotToTime returns the start time of the slot.
+func (spec *Spec) SlotToTime(genesisTime Timestamp, slot Slot) Timestamp {
+	return genesisTime + Timestamp(slot)*spec.SECONDS_PER_SLOT
+}
+
+// SlotFractionTime returns the time at the given fraction (numerator / denominator) into the slot,
+// e.g. 1/2 for the middle of the slot. Rounded down to the second.
+func (spec *Spec) SlotFractionTime(genesisTime Timestamp, slot Slot, numerator uint64, denominator uint64) Timestamp {
+	return spec.SlotToTime(genesisTime, slot) + spec.SECONDS_PER_SLOT*Timestamp(numerator)/Timestamp(denominator)
+}
+
+// AttestationDueTime returns the time, 1/3 into the slot, when validators attest to the head of the slot,
+// if no block was received before then.
+func (spec *Spec) AttestationDueTime(genesisTime Timestamp, slot Slot) Timestamp {
+	return spec.SlotFractionTime(genesisTime, slot, 1, 3)
+}
+
+// AggregateDueTime returns the time, 2/3 into the slot, when aggregators broadcast the aggregates of the slot.
+func (spec *Spec) AggregateDueTime(genesisTime Timestamp, slot Slot) Timestamp {
+	return spec.SlotFractionTime(genesisTime, slot, 2, 3)
+}
+
 func (a *Timestamp) Deserialize(dr *codec.DecodingReader) error {
 	return (*Uint64View)(a).Deserialize(dr)
 }
@@ -0,0 +1,29 @@
+package beacon_test
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestSlotTime(t *testing.T) {
+	spec := configs.Mainnet
+	genesis := beacon.Timestamp(1606824023)
+	if got := spec.SlotToTime(genesis, 10); got != genesis+120 {
+		t.Fatalf("unexpected slot time: %d", got)
+	}
+	for _, c := range []struct {
+		now  beacon.Timestamp
+		slot beacon.Slot
+	}{{genesis - 1, 0}, {genesis, 0}, {genesis + 11, 0}, {genesis + 12, 1}, {genesis + 120 + 5, 10}} {
+		if got := spec.CurrentSlot(genesis, c.now); got != c.slot {
+			t.Fatalf("expected slot %d at time %d, got %d", c.slot, c.now, got)
+		}
+	}
+	if got := spec.AttestationDueTime(genesis, 10); got != genesis+124 {
+		t.Fatalf("unexpected attestation due time: %d", got)
+	}
+	if got := spec.AggregateDueTime(genesis, 10); got != genesis+128 {
+		t.Fatalf("unexpected aggregate due time: %d", got)
+	}
+}
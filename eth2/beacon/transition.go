@@ -6,10 +6,39 @@ import (
 	"fmt"
 	"github.com/protolambda/zrnt/eth2/util/bls"
 	"github.com/protolambda/ztyp/tree"
+	"time"
 )
 
+// TransitionCancelErr is returned when the context of a transition is done.
+// It is never wrapped in a TransitionError, check for it with errors.Is.
 var TransitionCancelErr = errors.New("state transition was cancelled")
 
+// TransitionError is a failure of a phase of the state transition, e.g. "epoch_process" or "voluntary_exit".
+type TransitionError struct {
+	Phase string
+	Err   error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Phase, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// wrapTransitionErr wraps the error in a TransitionError, unless it is nil, a cancellation, or already wrapped.
+func wrapTransitionErr(phase string, err error) error {
+	if err == nil || errors.Is(err, TransitionCancelErr) {
+		return err
+	}
+	var tErr *TransitionError
+	if errors.As(err, &tErr) {
+		return err
+	}
+	return &TransitionError{Phase: phase, Err: err}
+}
+
 func (spec *Spec) ProcessSlot(ctx context.Context, state *BeaconStateView) error {
 	select {
 	case <-ctx.Done():
@@ -61,26 +90,60 @@ func (spec *Spec) ProcessSlot(ctx context.Context, state *BeaconStateView) error
 	return nil
 }
 
+// TransitionObserver is notified of the duration of the steps of the epoch transition, e.g. for metrics.
+type TransitionObserver interface {
+	// OnEpochStep is called after every successful step, with the name of the step,
+	// the same as the phase of a TransitionError: "epoch_process", "justification_and_finalization",
+	// "rewards_and_penalties", "registry_updates", "slashings" and "final_updates".
+	// The final updates are split in two steps: "effective_balance_updates", which includes the eth1 votes reset,
+	// and "final_updates" for the remainder.
+	OnEpochStep(name string, dur time.Duration)
+}
+
 func (spec *Spec) ProcessEpoch(ctx context.Context, epc *EpochsContext, state *BeaconStateView) error {
+	return spec.ProcessEpochObserved(ctx, epc, state, nil)
+}
+
+// ProcessEpochObserved is like ProcessEpoch, but reports the duration of every step to the observer, if not nil.
+func (spec *Spec) ProcessEpochObserved(ctx context.Context, epc *EpochsContext, state *BeaconStateView, observer TransitionObserver) error {
+	// Only measure the time if there is an observer.
+	var start time.Time
+	var observe func(name string)
+	if observer != nil {
+		start = time.Now()
+		observe = func(name string) {
+			now := time.Now()
+			observer.OnEpochStep(name, now.Sub(start))
+			start = now
+		}
+	} else {
+		observe = func(name string) {}
+	}
 	process, err := spec.PrepareEpochProcess(ctx, epc, state)
 	if err != nil {
-		return err
+		return wrapTransitionErr("epoch_process", err)
 	}
+	observe("epoch_process")
 	if err := spec.ProcessEpochJustification(ctx, epc, process, state); err != nil {
-		return err
+		return wrapTransitionErr("justification_and_finalization", err)
 	}
+	observe("justification_and_finalization")
 	if err := spec.ProcessEpochRewardsAndPenalties(ctx, epc, process, state); err != nil {
-		return err
+		return wrapTransitionErr("rewards_and_penalties", err)
 	}
+	observe("rewards_and_penalties")
 	if err := spec.ProcessEpochRegistryUpdates(ctx, epc, process, state); err != nil {
-		return err
+		return wrapTransitionErr("registry_updates", err)
 	}
+	observe("registry_updates")
 	if err := spec.ProcessEpochSlashings(ctx, epc, process, state); err != nil {
-		return err
+		return wrapTransitionErr("slashings", err)
 	}
-	if err := spec.ProcessEpochFinalUpdates(ctx, epc, process, state); err != nil {
-		return err
+	observe("slashings")
+	if err := spec.processEpochFinalUpdates(ctx, epc, process, state, observe); err != nil {
+		return wrapTransitionErr("final_updates", err)
 	}
+	observe("final_updates")
 	return nil
 }
 
@@ -88,6 +151,16 @@ func (spec *Spec) ProcessEpoch(ctx context.Context, epc *EpochsContext, state *B
 // Returns an error if the slot is older than the state is already at.
 // Mutates the state, does not copy.
 func (spec *Spec) ProcessSlots(ctx context.Context, epc *EpochsContext, state *BeaconStateView, slot Slot) error {
+	return spec.ProcessSlotsJournaled(ctx, epc, state, slot, nil)
+}
+
+// ProcessSlotsJournaled is like ProcessSlots, but records the changes of each processed slot in the journal,
+// if not nil, to be able to roll back with BeaconStateView.ApplyJournalReverse.
+func (spec *Spec) ProcessSlotsJournaled(ctx context.Context, epc *EpochsContext, state *BeaconStateView, slot Slot, journal *SlotProcessingJournal) error {
+	return spec.processSlots(ctx, epc, state, slot, journal, nil)
+}
+
+func (spec *Spec) processSlots(ctx context.Context, epc *EpochsContext, state *BeaconStateView, slot Slot, journal *SlotProcessingJournal, observer TransitionObserver) error {
 	// happens at the start of every CurrentSlot
 	currentSlot, err := state.Slot()
 	if err != nil {
@@ -103,14 +176,22 @@ func (spec *Spec) ProcessSlots(ctx context.Context, epc *EpochsContext, state *B
 		default:
 			break // Continue slot processing, don't block.
 		}
+		if journal != nil {
+			if err := journal.record(state, currentSlot); err != nil {
+				return err
+			}
+		}
 		if err := spec.ProcessSlot(ctx, state); err != nil {
-			return err
+			return wrapTransitionErr("slot", err)
 		}
 		// Per-epoch transition happens at the start of the first slot of every epoch.
 		// (with the slot still at the end of the last epoch)
 		isEpochEnd := spec.SlotToEpoch(currentSlot+1) != spec.SlotToEpoch(currentSlot)
 		if isEpochEnd {
-			if err := spec.ProcessEpoch(ctx, epc, state); err != nil {
+			if journal != nil {
+				journal.Entries[len(journal.Entries)-1].EpochTransition = true
+			}
+			if err := spec.ProcessEpochObserved(ctx, epc, state, observer); err != nil {
 				return err
 			}
 		}
@@ -119,60 +200,125 @@ func (spec *Spec) ProcessSlots(ctx context.Context, epc *EpochsContext, state *B
 			return err
 		}
 		if isEpochEnd {
-			if err := epc.RotateEpochs(state); err != nil {
-				return err
+			if err := epc.RotateEpochsCtx(ctx, state); err != nil {
+				return wrapTransitionErr("epochs_context_rotation", err)
 			}
 		}
 	}
 	return nil
 }
 
+// ValidationMode selects which signatures are verified when processing a block.
+type ValidationMode uint8
+
+const (
+	// FullValidation verifies all signatures.
+	FullValidation ValidationMode = iota
+	// SkipRandao does not verify the RANDAO reveal, the reveal is still mixed in.
+	SkipRandao
+	// SkipAllSignatures does not verify the RANDAO reveal, slashings, attestations and voluntary exits signatures,
+	// e.g. when replaying a finalized chain that is already trusted.
+	// Deposit signatures are still verified: a deposit with an invalid signature is skipped, not rejected,
+	// and changes the resulting state.
+	SkipAllSignatures
+)
+
+func (mode ValidationMode) verifyRandao() bool {
+	return mode == FullValidation
+}
+
+func (mode ValidationMode) verifySignatures() bool {
+	return mode != SkipAllSignatures
+}
+
 func (spec *Spec) ProcessBlock(ctx context.Context, epc *EpochsContext, state *BeaconStateView, block *BeaconBlock) error {
+	return spec.ProcessBlockWithMode(ctx, epc, state, block, FullValidation)
+}
+
+// ProcessBlockWithMode is like ProcessBlock, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessBlockWithMode(ctx context.Context, epc *EpochsContext, state *BeaconStateView, block *BeaconBlock, mode ValidationMode) error {
 	if err := spec.ProcessHeader(ctx, epc, state, block); err != nil {
-		return err
+		return wrapTransitionErr("block_header", err)
 	}
 	body := &block.Body
-	if err := spec.ProcessRandaoReveal(ctx, epc, state, body.RandaoReveal); err != nil {
-		return err
+	if err := spec.ProcessRandaoRevealWithMode(ctx, epc, state, body.RandaoReveal, mode); err != nil {
+		return wrapTransitionErr("randao", err)
 	}
 	if err := spec.ProcessEth1Vote(ctx, epc, state, body.Eth1Data); err != nil {
-		return err
+		return wrapTransitionErr("eth1_data", err)
 	}
 	// Safety checks, in case the user of the function provided too many operations
 	if err := body.CheckLimits(spec); err != nil {
-		return err
+		return wrapTransitionErr("operations", err)
 	}
 
-	if err := spec.ProcessProposerSlashings(ctx, epc, state, body.ProposerSlashings); err != nil {
-		return err
+	if err := spec.ProcessProposerSlashingsWithMode(ctx, epc, state, body.ProposerSlashings, mode); err != nil {
+		return wrapTransitionErr("proposer_slashing", err)
 	}
-	if err := spec.ProcessAttesterSlashings(ctx, epc, state, body.AttesterSlashings); err != nil {
-		return err
+	if err := spec.ProcessAttesterSlashingsWithMode(ctx, epc, state, body.AttesterSlashings, mode); err != nil {
+		return wrapTransitionErr("attester_slashing", err)
 	}
-	if err := spec.ProcessAttestations(ctx, epc, state, body.Attestations); err != nil {
-		return err
+	if err := spec.ProcessAttestationsWithMode(ctx, epc, state, body.Attestations, mode); err != nil {
+		return wrapTransitionErr("attestation", err)
 	}
 	if err := spec.ProcessDeposits(ctx, epc, state, body.Deposits); err != nil {
-		return err
+		return wrapTransitionErr("deposit", err)
 	}
-	if err := spec.ProcessVoluntaryExits(ctx, epc, state, body.VoluntaryExits); err != nil {
-		return err
+	if err := spec.ProcessVoluntaryExitsWithMode(ctx, epc, state, body.VoluntaryExits, mode); err != nil {
+		return wrapTransitionErr("voluntary_exit", err)
 	}
 	return nil
 }
 
+// StateRootMismatchError is returned when the state root of a block does not match the post-state.
+type StateRootMismatchError struct {
+	Slot     Slot
+	Expected Root
+	Computed Root
+}
+
+func (e *StateRootMismatchError) Error() string {
+	return fmt.Sprintf("block has invalid state root at slot %d: expected %s, computed %s", e.Slot, e.Expected, e.Computed)
+}
+
+// StateRootMismatchObserver can optionally be implemented by a TransitionObserver,
+// to debug blocks with an invalid state root.
+type StateRootMismatchObserver interface {
+	// OnStateRootMismatch is called with the state root error, and the state fields that were changed by the block,
+	// compared to the state after slot processing, to narrow down where the processing of the block diverged.
+	OnStateRootMismatch(err *StateRootMismatchError, blockChanges []StateFieldDiff)
+}
+
 // StateTransition to the slot of the given block, then process the block.
 // Returns an error if the slot is older or equal to what the state is already at.
 // Mutates the state, does not copy.
 func (spec *Spec) StateTransition(ctx context.Context, epc *EpochsContext, state *BeaconStateView, block *SignedBeaconBlock, validateResult bool) error {
-	if err := spec.ProcessSlots(ctx, epc, state, block.Message.Slot); err != nil {
+	return spec.StateTransitionObserved(ctx, epc, state, block, validateResult, nil)
+}
+
+// StateTransitionObserved is like StateTransition, but notifies the observer, if not nil,
+// of the epoch transition steps, and of a state root mismatch if it implements StateRootMismatchObserver.
+func (spec *Spec) StateTransitionObserved(ctx context.Context, epc *EpochsContext, state *BeaconStateView, block *SignedBeaconBlock, validateResult bool, observer TransitionObserver) error {
+	if err := spec.processSlots(ctx, epc, state, block.Message.Slot, nil, observer); err != nil {
 		return err
 	}
-	return spec.PostSlotTransition(ctx, epc, state, block, validateResult)
+	return spec.postSlotTransition(ctx, epc, state, block, validateResult, observer)
+}
+
+// VerifyAndProcessBlock is the full state transition of the spec: process the slots up to the slot of the block,
+// verify the block signature against the proposer, process the block header, RANDAO, eth1 data and operations
+// in spec order, with all signatures verified, and verify that the post-state root matches the block state root.
+// Mutates the state, does not copy: on error the state should be discarded.
+func (spec *Spec) VerifyAndProcessBlock(ctx context.Context, epc *EpochsContext, state *BeaconStateView, signed *SignedBeaconBlock) error {
+	return spec.StateTransition(ctx, epc, state, signed, true)
 }
 
 // PostSlotTransition finishes a state transition after applying ProcessSlots(..., block.Slot).
 func (spec *Spec) PostSlotTransition(ctx context.Context, epc *EpochsContext, state *BeaconStateView, block *SignedBeaconBlock, validateResult bool) error {
+	return spec.postSlotTransition(ctx, epc, state, block, validateResult, nil)
+}
+
+func (spec *Spec) postSlotTransition(ctx context.Context, epc *EpochsContext, state *BeaconStateView, block *SignedBeaconBlock, validateResult bool, observer TransitionObserver) error {
 	slot, err := state.Slot()
 	if err != nil {
 		return err
@@ -186,13 +332,30 @@ func (spec *Spec) PostSlotTransition(ctx context.Context, epc *EpochsContext, st
 			return errors.New("block has invalid signature")
 		}
 	}
+	// Keep the pre-block state around to diff against, only if there is an observer for it. Copies are cheap.
+	mismatchObserver, _ := observer.(StateRootMismatchObserver)
+	var preState *BeaconStateView
+	if validateResult && mismatchObserver != nil {
+		preState, err = AsBeaconStateView(state.Copy())
+		if err != nil {
+			return err
+		}
+	}
 	if err := spec.ProcessBlock(ctx, epc, state, &block.Message); err != nil {
 		return err
 	}
 
 	// State root verification
-	if validateResult && block.Message.StateRoot != state.HashTreeRoot(tree.GetHashFn()) {
-		return errors.New("block has invalid state root")
+	if validateResult {
+		if computed := state.HashTreeRoot(tree.GetHashFn()); block.Message.StateRoot != computed {
+			mismatchErr := &StateRootMismatchError{Slot: slot, Expected: block.Message.StateRoot, Computed: computed}
+			if mismatchObserver != nil {
+				// The diff is best-effort debugging information, the mismatch is the error to return.
+				diff, _ := DiffStates(preState, state)
+				mismatchObserver.OnStateRootMismatch(mismatchErr, diff)
+			}
+			return mismatchErr
+		}
 	}
 	return nil
 }
@@ -212,9 +375,9 @@ func (spec *Spec) VerifyBlockSignature(epc *EpochsContext, state *BeaconStateVie
 	if !ok {
 		return false
 	}
-	domain, err := state.GetDomain(spec.DOMAIN_BEACON_PROPOSER, spec.SlotToEpoch(block.Message.Slot))
+	signingRoot, err := spec.SigningRoot(state, &block.Message, spec.DOMAIN_BEACON_PROPOSER, spec.SlotToEpoch(block.Message.Slot))
 	if err != nil {
 		return false
 	}
-	return bls.Verify(pub, ComputeSigningRoot(block.Message.HashTreeRoot(spec, tree.GetHashFn()), domain), block.Signature)
+	return bls.Verify(pub, signingRoot, block.Signature)
 }
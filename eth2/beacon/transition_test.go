@@ -0,0 +1,199 @@
+package beacon_test
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/zrnt/eth2/util/math"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestTransitionErrors(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := spec.ProcessSlots(ctx, epc, state, spec.SLOTS_PER_EPOCH)
+	var tErr *beacon.TransitionError
+	if !errors.Is(err, beacon.TransitionCancelErr) || errors.As(err, &tErr) {
+		t.Fatalf("expected plain cancellation error, got: %v", err)
+	}
+	// A block for a different slot than the state fails the header processing.
+	block := &beacon.BeaconBlock{Slot: 3}
+	err = spec.ProcessBlock(context.Background(), epc, state, block)
+	if !errors.As(err, &tErr) || tErr.Phase != "block_header" {
+		t.Fatalf("expected block header transition error, got: %v", err)
+	}
+}
+
+func TestApplyJournalReverse(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	start := spec.SLOTS_PER_EPOCH + 1
+	if err := spec.ProcessSlots(ctx, epc, state, start); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := beacon.AsBeaconStateView(state.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var journal beacon.SlotProcessingJournal
+	if err := spec.ProcessSlotsJournaled(ctx, epc, state, spec.SLOTS_PER_EPOCH*2-1, &journal); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.ApplyJournalReverse(&journal, start); err != nil {
+		t.Fatal(err)
+	}
+	hFn := tree.GetHashFn()
+	if a, b := expected.HashTreeRoot(hFn), state.HashTreeRoot(hFn); a != b {
+		t.Fatalf("rolled back state differs: %s <> %s", a, b)
+	}
+	if len(journal.Entries) != 0 {
+		t.Fatalf("expected empty journal, got %d entries", len(journal.Entries))
+	}
+	// The epoch transition cannot be rolled back.
+	if err := spec.ProcessSlotsJournaled(ctx, epc, state, spec.SLOTS_PER_EPOCH*2+1, &journal); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.ApplyJournalReverse(&journal, start); err == nil {
+		t.Fatal("expected error for rollback of epoch transition")
+	}
+	if err := state.ApplyJournalReverse(&journal, spec.SLOTS_PER_EPOCH*2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateProposerIndex(t *testing.T) {
+	spec := configs.Minimal
+	validators := testValidators(spec)
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	if err := spec.ProcessSlots(ctx, epc, state, 1); err != nil {
+		t.Fatal(err)
+	}
+	proposer, err := epc.GetBeaconProposer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latestHeader, err := state.LatestBlockHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &beacon.BeaconBlock{
+		Slot:          1,
+		ProposerIndex: proposer,
+		ParentRoot:    latestHeader.HashTreeRoot(tree.GetHashFn()),
+	}
+	if err := spec.ValidateProposerIndex(epc, block); err != nil {
+		t.Fatal(err)
+	}
+	block.ProposerIndex = (proposer + 1) % beacon.ValidatorIndex(len(validators))
+	if err := spec.ValidateProposerIndex(epc, block); !errors.Is(err, beacon.ErrWrongProposerIndex) {
+		t.Fatalf("expected wrong proposer error, got: %v", err)
+	}
+	if err := spec.ProcessHeader(ctx, epc, state, block); !errors.Is(err, beacon.ErrWrongProposerIndex) {
+		t.Fatalf("expected header processing to fail with wrong proposer error, got: %v", err)
+	}
+}
+
+func TestProcessOperationsLimits(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	expectLimitErr := func(name string, err error, limit uint64) {
+		var limitErr *beacon.TooManyOperationsError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("%s: expected too many operations error, got: %v", name, err)
+		}
+		if limitErr.Count != limit+1 || limitErr.Limit != limit {
+			t.Fatalf("%s: unexpected count %d and limit %d", name, limitErr.Count, limitErr.Limit)
+		}
+	}
+	exits := make([]beacon.SignedVoluntaryExit, spec.MAX_VOLUNTARY_EXITS+1)
+	expectLimitErr("exits", spec.ProcessVoluntaryExits(ctx, epc, state, exits), spec.MAX_VOLUNTARY_EXITS)
+	atts := make([]beacon.Attestation, spec.MAX_ATTESTATIONS+1)
+	expectLimitErr("attestations", spec.ProcessAttestations(ctx, epc, state, atts), spec.MAX_ATTESTATIONS)
+	deps := make([]beacon.Deposit, spec.MAX_DEPOSITS+1)
+	expectLimitErr("deposits", spec.ProcessDeposits(ctx, epc, state, deps), spec.MAX_DEPOSITS)
+}
+
+func TestComputeProposerReward(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	committee, err := epc.GetBeaconCommittee(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := uint64(len(committee))
+	bits := make(beacon.CommitteeBits, n/8+1)
+	bits.SetBit(n, true) // delimiter bit
+	for i := uint64(0); i < n; i++ {
+		bits.SetBit(i, true)
+	}
+	att := beacon.Attestation{AggregationBits: bits, Data: beacon.AttestationData{Slot: 0, Index: 0}}
+	slashed := committee[0]
+	var block beacon.BeaconBlock
+	block.Body.ProposerSlashings = []beacon.ProposerSlashing{{}}
+	block.Body.ProposerSlashings[0].SignedHeader1.Message.ProposerIndex = slashed
+	// the same attesters, included twice, are only rewarded once
+	block.Body.Attestations = []beacon.Attestation{att, att}
+
+	reward, err := spec.ComputeProposerReward(epc, state, &block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	effBalance := spec.MAX_EFFECTIVE_BALANCE
+	totalBalance := effBalance * 64
+	baseReward := effBalance * beacon.Gwei(spec.BASE_REWARD_FACTOR) /
+		beacon.Gwei(math.IntegerSquareroot(uint64(totalBalance))) / beacon.BASE_REWARDS_PER_EPOCH
+	// the slashed attester is not rewarded for inclusion
+	expected := effBalance/beacon.Gwei(spec.WHISTLEBLOWER_REWARD_QUOTIENT) +
+		beacon.Gwei(n-1)*(baseReward/beacon.Gwei(spec.PROPOSER_REWARD_QUOTIENT))
+	if reward != expected {
+		t.Fatalf("expected proposer reward %d, got %d", expected, reward)
+	}
+}
+
+func TestProcessOperationsLimited(t *testing.T) {
+	spec := configs.Minimal
+	state, epc := testState(t, spec)
+	ctx := context.Background()
+	// invalid attestations, only processed if within the limit
+	atts := make([]beacon.Attestation, 3)
+	rest, err := spec.ProcessAttestationsLimited(ctx, epc, state, atts, beacon.SkipAllSignatures, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != len(atts) {
+		t.Fatalf("expected all %d attestations to remain, got %d", len(atts), len(rest))
+	}
+	if rest, err := spec.ProcessAttestationsLimited(ctx, epc, state, atts, beacon.SkipAllSignatures, 1); err == nil {
+		t.Fatal("expected error for invalid attestation within the limit")
+	} else if len(rest) != len(atts) {
+		t.Fatalf("expected the failing attestation and the rest to remain, got %d", len(rest))
+	}
+	// all genesis deposits are processed, none are outstanding
+	deps := make([]beacon.Deposit, 2)
+	restDeps, err := spec.ProcessDepositsLimited(ctx, epc, state, deps, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restDeps) != len(deps) {
+		t.Fatalf("expected all %d deposits to remain, got %d", len(deps), len(restDeps))
+	}
+	// a deposit index beyond the deposit count is an invalid state, not a huge amount of outstanding deposits
+	if err := state.IncrementDepositIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if restDeps, err := spec.ProcessDepositsLimited(ctx, epc, state, deps, -1); err == nil {
+		t.Fatal("expected error for deposit index beyond the deposit count")
+	} else if len(restDeps) != len(deps) {
+		t.Fatalf("expected all %d deposits to remain, got %d", len(deps), len(restDeps))
+	}
+	if err := spec.ProcessDeposits(ctx, epc, state, nil); err == nil {
+		t.Fatal("expected error for deposit index beyond the deposit count")
+	}
+}
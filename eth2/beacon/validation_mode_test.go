@@ -0,0 +1,82 @@
+// +build !bls_off
+
+package beacon_test
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestValidationMode(t *testing.T) {
+	spec := configs.Minimal
+	pre, preEpc := testState(t, spec)
+	ctx := context.Background()
+	if err := spec.ProcessSlots(ctx, preEpc, pre, 2); err != nil {
+		t.Fatal(err)
+	}
+	header, err := pre.LatestBlockHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proposer, err := preEpc.GetBeaconProposer(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	justified, err := pre.CurrentJustifiedCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := justified.Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	committee, err := preEpc.GetBeaconCommittee(1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits := make(beacon.CommitteeBits, len(committee)/8+1)
+	bits.SetBit(uint64(len(committee)), true) // delimiter bit
+	bits.SetBit(0, true)
+
+	// The block only has invalid signatures: a zero RANDAO reveal, and an unsigned attestation.
+	block := &beacon.BeaconBlock{
+		Slot:          2,
+		ProposerIndex: proposer,
+		ParentRoot:    header.HashTreeRoot(tree.GetHashFn()),
+	}
+	block.Body.Attestations = beacon.Attestations{{
+		AggregationBits: bits,
+		Data: beacon.AttestationData{
+			Slot:   1,
+			Source: source,
+			Target: beacon.Checkpoint{Epoch: 0},
+		},
+	}}
+	for _, c := range []struct {
+		mode  beacon.ValidationMode
+		phase string
+	}{
+		{beacon.FullValidation, "randao"},
+		{beacon.SkipRandao, "attestation"},
+		{beacon.SkipAllSignatures, ""},
+	} {
+		state, err := beacon.AsBeaconStateView(pre.Copy())
+		if err != nil {
+			t.Fatal(err)
+		}
+		epc := preEpc.Clone()
+		err = spec.ProcessBlockWithMode(ctx, epc, state, block, c.mode)
+		var tErr *beacon.TransitionError
+		if c.phase == "" {
+			if err != nil {
+				t.Fatalf("mode %d: unexpected error: %v", c.mode, err)
+			}
+		} else if !errors.As(err, &tErr) || tErr.Phase != c.phase {
+			t.Fatalf("mode %d: expected %s transition error, got: %v", c.mode, c.phase, err)
+		}
+	}
+}
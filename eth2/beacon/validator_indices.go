@@ -262,3 +262,67 @@ func (vs ValidatorSet) ZigZagJoin(target ValidatorSet, onIn func(i ValidatorInde
 		}
 	}
 }
+
+// appendUnique appends the index to the set, unless it is the same as the last index.
+func (vs ValidatorSet) appendUnique(i ValidatorIndex) ValidatorSet {
+	if len(vs) > 0 && vs[len(vs)-1] == i {
+		return vs
+	}
+	return append(vs, i)
+}
+
+// Union of the two sets, producing a new set. Duplicates are removed.
+func (vs ValidatorSet) Union(other ValidatorSet) ValidatorSet {
+	out := make(ValidatorSet, 0, len(vs)+len(other))
+	a, b := 0, 0
+	for a < len(vs) || b < len(other) {
+		if b >= len(other) || (a < len(vs) && vs[a] < other[b]) {
+			out = out.appendUnique(vs[a])
+			a++
+		} else if a >= len(vs) || vs[a] > other[b] {
+			out = out.appendUnique(other[b])
+			b++
+		} else {
+			out = out.appendUnique(vs[a])
+			a++
+			b++
+		}
+	}
+	return out
+}
+
+// Intersection of the two sets, producing a new set. Duplicates are removed.
+func (vs ValidatorSet) Intersect(other ValidatorSet) ValidatorSet {
+	out := make(ValidatorSet, 0)
+	a, b := 0, 0
+	for a < len(vs) && b < len(other) {
+		if vs[a] < other[b] {
+			a++
+		} else if vs[a] > other[b] {
+			b++
+		} else {
+			out = out.appendUnique(vs[a])
+			a++
+			b++
+		}
+	}
+	return out
+}
+
+// Difference of the two sets: the indices of this set that are not in the other set, as a new set.
+// Duplicates are removed.
+func (vs ValidatorSet) Difference(other ValidatorSet) ValidatorSet {
+	out := make(ValidatorSet, 0, len(vs))
+	a, b := 0, 0
+	for a < len(vs) {
+		if b >= len(other) || vs[a] < other[b] {
+			out = out.appendUnique(vs[a])
+			a++
+		} else if vs[a] > other[b] {
+			b++
+		} else {
+			a++
+		}
+	}
+	return out
+}
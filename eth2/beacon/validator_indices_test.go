@@ -0,0 +1,29 @@
+package beacon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatorSetOps(t *testing.T) {
+	a := ValidatorSet{1, 2, 2, 4, 7, 9}
+	b := ValidatorSet{2, 3, 4, 4, 8, 9, 10}
+	if got, expected := a.Union(b), (ValidatorSet{1, 2, 3, 4, 7, 8, 9, 10}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("union: got %v, expected %v", got, expected)
+	}
+	if got, expected := a.Intersect(b), (ValidatorSet{2, 4, 9}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("intersect: got %v, expected %v", got, expected)
+	}
+	if got, expected := a.Difference(b), (ValidatorSet{1, 7}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("difference: got %v, expected %v", got, expected)
+	}
+	if got, expected := b.Difference(a), (ValidatorSet{3, 8, 10}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("difference: got %v, expected %v", got, expected)
+	}
+	if got := a.Intersect(nil); len(got) != 0 {
+		t.Errorf("intersect with empty set: got %v", got)
+	}
+	if got, expected := ValidatorSet(nil).Union(a), (ValidatorSet{1, 2, 4, 7, 9}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("union with empty set: got %v, expected %v", got, expected)
+	}
+}
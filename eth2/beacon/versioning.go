@@ -157,6 +157,8 @@ func ComputeForkDataRoot(currentVersion Version, genesisValidatorsRoot Root) Roo
 	return data.HashTreeRoot(tree.GetHashFn())
 }
 
+// ComputeForkDigest computes the 4-byte fork digest, as used in gossip topics and the ENR,
+// from the first 4 bytes of the fork data root.
 func ComputeForkDigest(currentVersion Version, genesisValidatorsRoot Root) ForkDigest {
 	var digest ForkDigest
 	dataRoot := ComputeForkDataRoot(currentVersion, genesisValidatorsRoot)
@@ -164,6 +166,29 @@ func ComputeForkDigest(currentVersion Version, genesisValidatorsRoot Root) ForkD
 	return digest
 }
 
+// ComputeForkDigest computes the fork digest of the given fork version and genesis validators root,
+// see the package-level ComputeForkDigest.
+func (spec *Spec) ComputeForkDigest(forkVersion Version, genesisValidatorsRoot Root) ForkDigest {
+	return ComputeForkDigest(forkVersion, genesisValidatorsRoot)
+}
+
+// CurrentForkDigest computes the fork digest of the current fork version of the state.
+func (spec *Spec) CurrentForkDigest(state *BeaconStateView) (ForkDigest, error) {
+	fork, err := state.Fork()
+	if err != nil {
+		return ForkDigest{}, err
+	}
+	currentVersion, err := fork.CurrentVersion()
+	if err != nil {
+		return ForkDigest{}, err
+	}
+	genesisValidatorsRoot, err := state.GenesisValidatorsRoot()
+	if err != nil {
+		return ForkDigest{}, err
+	}
+	return spec.ComputeForkDigest(currentVersion, genesisValidatorsRoot), nil
+}
+
 type Fork struct {
 	PreviousVersion Version `json:"previous_version" yaml:"previous_version"`
 	CurrentVersion  Version `json:"current_version" yaml:"current_version"`
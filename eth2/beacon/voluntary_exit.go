@@ -47,19 +47,57 @@ func (li VoluntaryExits) HashTreeRoot(spec *Spec, hFn tree.HashFn) Root {
 	}, length, spec.MAX_VOLUNTARY_EXITS)
 }
 
+// ProcessVoluntaryExitsLimited processes at most limit voluntary exits, and at most MAX_VOLUNTARY_EXITS,
+// and returns the remainder that was not processed. A negative limit processes as many as a block can contain.
+// On error, the exits before the failing exit stay applied, and the remainder starts at the failing exit.
+func (spec *Spec) ProcessVoluntaryExitsLimited(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []SignedVoluntaryExit, mode ValidationMode, limit int) ([]SignedVoluntaryExit, error) {
+	n := limitOperations(len(ops), limit, spec.MAX_VOLUNTARY_EXITS)
+	done, err := spec.processVoluntaryExits(ctx, epc, state, ops[:n], mode)
+	if err != nil {
+		return ops[done:], err
+	}
+	return ops[n:], nil
+}
+
 func (spec *Spec) ProcessVoluntaryExits(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []SignedVoluntaryExit) error {
+	return spec.ProcessVoluntaryExitsWithMode(ctx, epc, state, ops, FullValidation)
+}
+
+// ProcessVoluntaryExitsWithMode is like ProcessVoluntaryExits, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessVoluntaryExitsWithMode(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []SignedVoluntaryExit, mode ValidationMode) error {
+	if err := checkOperationsLimit("voluntary exits", len(ops), spec.MAX_VOLUNTARY_EXITS); err != nil {
+		return err
+	}
+	_, err := spec.processVoluntaryExits(ctx, epc, state, ops, mode)
+	return err
+}
+
+// processVoluntaryExits processes the exits in order, and returns how many were processed before any error.
+func (spec *Spec) processVoluntaryExits(ctx context.Context, epc *EpochsContext, state *BeaconStateView, ops []SignedVoluntaryExit, mode ValidationMode) (int, error) {
+	var queue *ExitQueueInfo
 	for i := range ops {
 		select {
 		case <-ctx.Done():
-			return TransitionCancelErr
+			return i, TransitionCancelErr
 		default: // Don't block.
 			break
 		}
-		if err := spec.ProcessVoluntaryExit(epc, state, &ops[i]); err != nil {
-			return err
+		if err := spec.validateVoluntaryExit(epc, state, &ops[i], mode.verifySignatures()); err != nil {
+			return i, err
+		}
+		// Only scan the registry for the exit queue once, then update it as validators exit.
+		if queue == nil {
+			q, err := spec.ComputeExitQueueInfo(epc, state)
+			if err != nil {
+				return i, err
+			}
+			queue = q
+		}
+		if err := spec.InitiateValidatorExitWithQueue(epc, state, ops[i].Message.ValidatorIndex, queue); err != nil {
+			return i, err
 		}
 	}
-	return nil
+	return len(ops), nil
 }
 
 type VoluntaryExit struct {
@@ -124,6 +162,10 @@ var SignedVoluntaryExitType = ContainerType("SignedVoluntaryExit", []FieldDef{
 })
 
 func (spec *Spec) ValidateVoluntaryExit(epc *EpochsContext, state *BeaconStateView, signedExit *SignedVoluntaryExit) error {
+	return spec.validateVoluntaryExit(epc, state, signedExit, true)
+}
+
+func (spec *Spec) validateVoluntaryExit(epc *EpochsContext, state *BeaconStateView, signedExit *SignedVoluntaryExit, verifySig bool) error {
 	exit := &signedExit.Message
 	currentEpoch := epc.CurrentEpoch.Epoch
 	if valid, err := state.IsValidIndex(exit.ValidatorIndex); err != nil {
@@ -165,11 +207,14 @@ func (spec *Spec) ValidateVoluntaryExit(epc *EpochsContext, state *BeaconStateVi
 	if currentEpoch < registeredActivationEpoch+spec.SHARD_COMMITTEE_PERIOD {
 		return errors.New("exit is too soon")
 	}
+	if !verifySig {
+		return nil
+	}
 	pubkey, ok := epc.PubkeyCache.Pubkey(exit.ValidatorIndex)
 	if !ok {
 		return errors.New("could not find index of exiting validator")
 	}
-	domain, err := state.GetDomain(spec.DOMAIN_VOLUNTARY_EXIT, exit.Epoch)
+	domain, err := epc.GetDomain(state, spec.DOMAIN_VOLUNTARY_EXIT, exit.Epoch)
 	if err != nil {
 		return err
 	}
@@ -184,52 +229,53 @@ func (spec *Spec) ValidateVoluntaryExit(epc *EpochsContext, state *BeaconStateVi
 }
 
 func (spec *Spec) ProcessVoluntaryExit(epc *EpochsContext, state *BeaconStateView, signedExit *SignedVoluntaryExit) error {
-	if err := spec.ValidateVoluntaryExit(epc, state, signedExit); err != nil {
+	return spec.ProcessVoluntaryExitWithMode(epc, state, signedExit, FullValidation)
+}
+
+// ProcessVoluntaryExitWithMode is like ProcessVoluntaryExit, but only verifies the signatures selected by the validation mode.
+func (spec *Spec) ProcessVoluntaryExitWithMode(epc *EpochsContext, state *BeaconStateView, signedExit *SignedVoluntaryExit, mode ValidationMode) error {
+	if err := spec.validateVoluntaryExit(epc, state, signedExit, mode.verifySignatures()); err != nil {
 		return err
 	}
 	return spec.InitiateValidatorExit(epc, state, signedExit.Message.ValidatorIndex)
 }
 
-// Initiate the exit of the validator of the given index
-func (spec *Spec) InitiateValidatorExit(epc *EpochsContext, state *BeaconStateView, index ValidatorIndex) error {
+// ExitQueueInfo describes the end of the exit queue, to initiate validator exits without re-scanning the registry.
+// It is updated in-place as validators are queued to exit, and is only valid within the same epoch.
+type ExitQueueInfo struct {
+	// The epoch that the last queued validator exits at
+	ExitQueueEnd Epoch
+	// The amount of validators exiting at ExitQueueEnd
+	ExitQueueEndChurn uint64
+	// The maximum amount of validators exiting at any epoch
+	ChurnLimit uint64
+}
+
+// ComputeExitQueueInfo scans the registry for the current end of the exit queue.
+func (spec *Spec) ComputeExitQueueInfo(epc *EpochsContext, state *BeaconStateView) (*ExitQueueInfo, error) {
 	validators, err := state.Validators()
 	if err != nil {
-		return err
-	}
-	v, err := validators.Validator(index)
-	if err != nil {
-		return err
-	}
-	exitEp, err := v.ExitEpoch()
-	if err != nil {
-		return err
-	}
-	// Return if validator already initiated exit
-	if exitEp != FAR_FUTURE_EPOCH {
-		return nil
+		return nil, err
 	}
-	currentEpoch := epc.CurrentEpoch.Epoch
-
-	// Set validator exit epoch and withdrawable epoch
 	valIter := validators.ReadonlyIter()
 
-	exitQueueEnd := spec.ComputeActivationExitEpoch(currentEpoch)
+	exitQueueEnd := spec.ComputeActivationExitEpoch(epc.CurrentEpoch.Epoch)
 	exitQueueEndChurn := uint64(0)
 	for {
 		valContainer, ok, err := valIter.Next()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if !ok {
 			break
 		}
 		val, err := AsValidator(valContainer, nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		valExit, err := val.ExitEpoch()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if valExit == FAR_FUTURE_EPOCH {
 			continue
@@ -241,17 +287,72 @@ func (spec *Spec) InitiateValidatorExit(epc *EpochsContext, state *BeaconStateVi
 			exitQueueEndChurn = 1
 		}
 	}
-	churnLimit := spec.GetChurnLimit(uint64(len(epc.CurrentEpoch.ActiveIndices)))
-	if exitQueueEndChurn >= churnLimit {
-		exitQueueEnd++
+	return &ExitQueueInfo{
+		ExitQueueEnd:      exitQueueEnd,
+		ExitQueueEndChurn: exitQueueEndChurn,
+		ChurnLimit:        spec.GetChurnLimit(uint64(len(epc.CurrentEpoch.ActiveIndices))),
+	}, nil
+}
+
+// Initiate the exit of the validator of the given index
+// The registry is scanned for the end of the exit queue, use InitiateValidatorExitWithQueue to initiate many exits.
+func (spec *Spec) InitiateValidatorExit(epc *EpochsContext, state *BeaconStateView, index ValidatorIndex) error {
+	validators, err := state.Validators()
+	if err != nil {
+		return err
+	}
+	v, err := validators.Validator(index)
+	if err != nil {
+		return err
 	}
+	exitEp, err := v.ExitEpoch()
+	if err != nil {
+		return err
+	}
+	// Return if validator already initiated exit
+	if exitEp != FAR_FUTURE_EPOCH {
+		return nil
+	}
+	queue, err := spec.ComputeExitQueueInfo(epc, state)
+	if err != nil {
+		return err
+	}
+	return spec.InitiateValidatorExitWithQueue(epc, state, index, queue)
+}
 
-	exitEp = exitQueueEnd
+// InitiateValidatorExitWithQueue initiates the exit of the validator at the end of the given exit queue,
+// and updates the queue info in-place to include the exiting validator.
+func (spec *Spec) InitiateValidatorExitWithQueue(epc *EpochsContext, state *BeaconStateView, index ValidatorIndex, queue *ExitQueueInfo) error {
+	validators, err := state.Validators()
+	if err != nil {
+		return err
+	}
+	v, err := validators.Validator(index)
+	if err != nil {
+		return err
+	}
+	exitEp, err := v.ExitEpoch()
+	if err != nil {
+		return err
+	}
+	// Return if validator already initiated exit
+	if exitEp != FAR_FUTURE_EPOCH {
+		return nil
+	}
+
+	if queue.ExitQueueEndChurn >= queue.ChurnLimit {
+		queue.ExitQueueEnd++
+		queue.ExitQueueEndChurn = 0
+	}
+
+	// Set validator exit epoch and withdrawable epoch
+	exitEp = queue.ExitQueueEnd
 	if err := v.SetExitEpoch(exitEp); err != nil {
 		return err
 	}
 	if err := v.SetWithdrawableEpoch(exitEp + spec.MIN_VALIDATOR_WITHDRAWABILITY_DELAY); err != nil {
 		return err
 	}
+	queue.ExitQueueEndChurn++
 	return nil
 }
@@ -0,0 +1,86 @@
+package beacon
+
+import "fmt"
+
+const ETH_TO_GWEI = 1000000000
+
+// SAFETY_DECAY is the maximum percentage decay of the safety margin of finality during the weak subjectivity period.
+const SAFETY_DECAY = 10
+
+// ComputeWeakSubjectivityPeriod computes the number of epochs after the state that the state can be trusted to sync from,
+// based on the active validator count and the average active balance of the state.
+func (spec *Spec) ComputeWeakSubjectivityPeriod(state *BeaconStateView) (Epoch, error) {
+	slot, err := state.Slot()
+	if err != nil {
+		return 0, err
+	}
+	epoch := spec.SlotToEpoch(slot)
+	validators, err := state.FlatValidators()
+	if err != nil {
+		return 0, err
+	}
+	activeCount := uint64(0)
+	totalActiveBalance := Gwei(0)
+	for i := range validators {
+		if validators[i].IsActive(epoch) {
+			activeCount++
+			totalActiveBalance += validators[i].EffectiveBalance
+		}
+	}
+	if activeCount == 0 {
+		return 0, fmt.Errorf("no active validators at epoch %d", epoch)
+	}
+	if totalActiveBalance < spec.EFFECTIVE_BALANCE_INCREMENT {
+		totalActiveBalance = spec.EFFECTIVE_BALANCE_INCREMENT
+	}
+
+	wsPeriod := spec.MIN_VALIDATOR_WITHDRAWABILITY_DELAY
+	N := activeCount
+	t := uint64(totalActiveBalance) / N / ETH_TO_GWEI
+	T := uint64(spec.MAX_EFFECTIVE_BALANCE) / ETH_TO_GWEI
+	delta := spec.GetChurnLimit(activeCount)
+	Delta := spec.MAX_DEPOSITS * uint64(spec.SLOTS_PER_EPOCH)
+	D := uint64(SAFETY_DECAY)
+
+	if T*(200+3*D) < t*(200+12*D) {
+		epochsForValidatorSetChurn := N * (t*(200+12*D) - T*(200+3*D)) / (600 * delta * (2*t + T))
+		epochsForBalanceTopUps := N * (200 + 3*D) / (600 * Delta)
+		if epochsForValidatorSetChurn > epochsForBalanceTopUps {
+			wsPeriod += Epoch(epochsForValidatorSetChurn)
+		} else {
+			wsPeriod += Epoch(epochsForBalanceTopUps)
+		}
+	} else {
+		wsPeriod += Epoch(3 * N * D * t / (200 * Delta * (T - t)))
+	}
+	return wsPeriod, nil
+}
+
+// IsWithinWeakSubjectivityPeriod checks if the current slot is still within the weak subjectivity period of the state.
+// The state must match the weak subjectivity checkpoint, an error is returned otherwise.
+func (spec *Spec) IsWithinWeakSubjectivityPeriod(currentSlot Slot, wsState *BeaconStateView, wsCheckpoint Checkpoint) (bool, error) {
+	header, err := wsState.LatestBlockHeader()
+	if err != nil {
+		return false, err
+	}
+	stateRoot, err := header.StateRoot()
+	if err != nil {
+		return false, err
+	}
+	if stateRoot != wsCheckpoint.Root {
+		return false, fmt.Errorf("weak subjectivity state root %s does not match checkpoint root %s", stateRoot, wsCheckpoint.Root)
+	}
+	slot, err := wsState.Slot()
+	if err != nil {
+		return false, err
+	}
+	wsStateEpoch := spec.SlotToEpoch(slot)
+	if wsStateEpoch != wsCheckpoint.Epoch {
+		return false, fmt.Errorf("weak subjectivity state epoch %d does not match checkpoint epoch %d", wsStateEpoch, wsCheckpoint.Epoch)
+	}
+	wsPeriod, err := spec.ComputeWeakSubjectivityPeriod(wsState)
+	if err != nil {
+		return false, err
+	}
+	return spec.SlotToEpoch(currentSlot) <= wsStateEpoch+wsPeriod, nil
+}
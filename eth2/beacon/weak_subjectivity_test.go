@@ -0,0 +1,60 @@
+package beacon_test
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+func TestWeakSubjectivityPeriod(t *testing.T) {
+	spec := configs.Mainnet
+	validators := make([]beacon.KickstartValidatorData, 32768)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].Pubkey[1] = byte(i >> 8)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	state, _, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	period, err := spec.ComputeWeakSubjectivityPeriod(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Value from the weak subjectivity period table of the spec, for 32 ETH average balance.
+	if period != 665 {
+		t.Fatalf("expected period 665, got %d", period)
+	}
+
+	header, err := state.LatestBlockHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := header.SetStateRoot(state.HashTreeRoot(tree.GetHashFn())); err != nil {
+		t.Fatal(err)
+	}
+	root, err := header.StateRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint := beacon.Checkpoint{Epoch: 0, Root: root}
+	lastSlot := beacon.Slot(665+1)*spec.SLOTS_PER_EPOCH - 1
+	if ok, err := spec.IsWithinWeakSubjectivityPeriod(lastSlot, state, checkpoint); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected last slot of the period to be within the period")
+	}
+	if ok, err := spec.IsWithinWeakSubjectivityPeriod(lastSlot+1, state, checkpoint); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatal("expected slot after the period to be outside of the period")
+	}
+	if _, err := spec.IsWithinWeakSubjectivityPeriod(0, state, beacon.Checkpoint{Epoch: 1, Root: root}); err == nil {
+		t.Fatal("expected error for checkpoint of a different epoch")
+	}
+	if _, err := spec.IsWithinWeakSubjectivityPeriod(0, state, beacon.Checkpoint{Epoch: 0}); err == nil {
+		t.Fatal("expected error for checkpoint with a different root")
+	}
+}
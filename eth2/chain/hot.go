@@ -243,6 +243,10 @@ func NewUnfinalizedChain(anchorState *beacon.BeaconStateView, sink BlockSink, sp
 	if err != nil {
 		return nil, err
 	}
+	genesisTime, err := anchorState.GenesisTime()
+	if err != nil {
+		return nil, err
+	}
 	fc, err := proto.NewProtoForkChoice(
 		spec,
 		finCh,
@@ -255,6 +259,7 @@ func NewUnfinalizedChain(anchorState *beacon.BeaconStateView, sink BlockSink, sp
 	if err != nil {
 		return nil, err
 	}
+	fc.SetGenesisTime(genesisTime)
 	uc.ForkChoice = fc
 	return uc, nil
 }
@@ -408,7 +413,7 @@ func (uc *UnfinalizedChain) Towards(ctx context.Context, fromBlockRoot Root, toS
 			return nil, err
 		}
 		if isEpochEnd {
-			if err := epc.RotateEpochs(state); err != nil {
+			if err := epc.RotateEpochsCtx(ctx, state); err != nil {
 				return nil, err
 			}
 		}
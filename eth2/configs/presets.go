@@ -0,0 +1,19 @@
+package configs
+
+import (
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+)
+
+// PresetByName returns the spec of a known preset, by its CONFIG_NAME: "mainnet" or "minimal".
+// The spec is shared, and must not be modified.
+func PresetByName(name string) (*beacon.Spec, error) {
+	switch name {
+	case Mainnet.CONFIG_NAME:
+		return Mainnet, nil
+	case Minimal.CONFIG_NAME:
+		return Minimal, nil
+	default:
+		return nil, fmt.Errorf("unknown preset: %q", name)
+	}
+}
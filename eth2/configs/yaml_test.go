@@ -1,6 +1,7 @@
 package configs
 
 import (
+	"bytes"
 	"github.com/protolambda/zrnt/eth2/beacon"
 	"gopkg.in/yaml.v3"
 	"io/ioutil"
@@ -56,3 +57,54 @@ func TestYamlDecodingMinimalPhase1(t *testing.T) {
 		t.Fatal("Failed to load minimal phase1 config")
 	}
 }
+
+func TestPresetByName(t *testing.T) {
+	for _, name := range []string{"mainnet", "minimal"} {
+		spec, err := PresetByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spec.CONFIG_NAME != name {
+			t.Fatalf("preset %q has config name %q", name, spec.CONFIG_NAME)
+		}
+		var conf beacon.Phase0Config
+		if err := yaml.Unmarshal(mustLoad(name, "phase0"), &conf); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(conf, spec.Phase0Config) {
+			t.Fatalf("preset %q does not match its phase0 config", name)
+		}
+	}
+	if _, err := PresetByName("foobar"); err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+}
+
+func TestLoadSpecConfigYAML(t *testing.T) {
+	for name, expected := range map[string]*beacon.Spec{"mainnet": Mainnet, "minimal": Minimal} {
+		// the phase1 config repeats the config name
+		phase1 := bytes.Replace(mustLoad(name, "phase1"), []byte("CONFIG_NAME:"), []byte("# CONFIG_NAME:"), 1)
+		spec, err := beacon.LoadSpecConfigYAML(bytes.NewReader(append(mustLoad(name, "phase0"), phase1...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(spec, expected) {
+			t.Fatalf("failed to load %s config", name)
+		}
+	}
+	conf := mustLoad("minimal", "phase0")
+	invalid := map[string][]byte{
+		"missing key": bytes.Replace(conf, []byte("SLOTS_PER_EPOCH:"), []byte("# SLOTS_PER_EPOCH:"), 1),
+		"unknown key": append(append([]byte{}, conf...), []byte("\nFOOBAR: 123\n")...),
+		"bad version": bytes.Replace(conf, []byte("GENESIS_FORK_VERSION: 0x00000001"), []byte("GENESIS_FORK_VERSION: 0x01"), 1),
+		"zero slots":  bytes.Replace(conf, []byte("SLOTS_PER_EPOCH: 8"), []byte("SLOTS_PER_EPOCH: 0"), 1),
+	}
+	for name, data := range invalid {
+		if bytes.Equal(data, conf) {
+			t.Fatalf("%s: test input was not modified", name)
+		}
+		if _, err := beacon.LoadSpecConfigYAML(bytes.NewReader(data)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -4,9 +4,26 @@ import (
 	"context"
 	"fmt"
 	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
 	"sync"
 )
 
+// Percentage of the committee weight that is added to the score of a timely block.
+const PROPOSER_SCORE_BOOST = 40
+
+// Blocks are timely if they arrive within the first interval of their slot.
+const INTERVALS_PER_SLOT = 3
+
+// IsTimelyBlock checks if a block, received at the given time, is eligible for proposer boost:
+// it must arrive during its own slot, before the attesting interval of the slot starts.
+func IsTimelyBlock(spec *beacon.Spec, genesisTime Timestamp, blockSlot Slot, now Timestamp) bool {
+	if now < genesisTime {
+		return false
+	}
+	timeIntoSlot := (now - genesisTime) % spec.SECONDS_PER_SLOT
+	return spec.TimeToSlot(now, genesisTime) == blockSlot && timeIntoSlot < spec.SECONDS_PER_SLOT/INTERVALS_PER_SLOT
+}
+
 type ProtoForkChoice struct {
 	mu         sync.RWMutex
 	protoArray ForkchoiceGraph
@@ -19,6 +36,21 @@ type ProtoForkChoice struct {
 	justified Checkpoint
 	finalized Checkpoint
 	spec      *beacon.Spec
+
+	// The genesis time to compute the current slot with, see SetGenesisTime.
+	genesisTime Timestamp
+	// The current slot, advanced by OnTick. Blocks after this slot are from the future.
+	currentSlot Slot
+	// The best unrealized checkpoints, applied by OnTick at the start of the next epoch.
+	unrealizedJustified Checkpoint
+	unrealizedFinalized Checkpoint
+	unrealizedBalances  func() ([]Gwei, error)
+
+	// The block that should be boosted, if any.
+	proposerBoost *NodeRef
+	// The block of which the score currently includes the boost, and the applied boost.
+	appliedBoost      *NodeRef
+	appliedBoostScore Gwei
 }
 
 var _ Forkchoice = (*ProtoForkChoice)(nil)
@@ -27,16 +59,21 @@ func NewForkChoice(spec *beacon.Spec, finalized Checkpoint, justified Checkpoint
 	anchorRoot Root, anchorSlot Slot, graph ForkchoiceGraph, votes VoteStore,
 	initialBalances []Gwei) (Forkchoice, error) {
 	fc := &ProtoForkChoice{
-		protoArray: graph,
-		voteStore:  votes,
-		balances:   nil,
-		justified:  justified,
-		finalized:  finalized,
-		spec:       spec,
+		protoArray:          graph,
+		voteStore:           votes,
+		balances:            nil,
+		justified:           justified,
+		finalized:           finalized,
+		spec:                spec,
+		currentSlot:         anchorSlot,
+		unrealizedJustified: justified,
+		unrealizedFinalized: finalized,
 	}
 	if err := fc.SetPin(anchorRoot, anchorSlot); err != nil {
 		return nil, err
 	}
+	epochStart, _ := spec.EpochStartSlot(spec.SlotToEpoch(anchorSlot))
+	graph.SetCurrentEpochStart(epochStart)
 	if err := fc.updateJustified(finalized, justified, func() ([]Gwei, error) {
 		return initialBalances, nil
 	}); err != nil {
@@ -66,6 +103,12 @@ func (fc *ProtoForkChoice) SetPin(root Root, slot Slot) error {
 	return nil
 }
 
+func (fc *ProtoForkChoice) SetGenesisTime(genesisTime Timestamp) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.genesisTime = genesisTime
+}
+
 // UpdateJustified updates what is recognized as justified and finalized checkpoint,
 // and adjusts justified balances for vote weights.
 // If the finalized checkpoint changes, it triggers pruning.
@@ -82,7 +125,7 @@ func (fc *ProtoForkChoice) UpdateJustified(ctx context.Context, trigger Root, ju
 	}
 	if fc.pin != nil && trigger != fc.pin.Root {
 		// check trigger against pin, to ensure no justification/finalization of data that conflicts with the pin.
-		if unknown, inSubtree := fc.InSubtree(fc.pin.Root, trigger); unknown {
+		if unknown, inSubtree := fc.protoArray.InSubtree(fc.pin.Root, trigger); unknown {
 			return fmt.Errorf("cannot justify/finalize with unknown trigger when forkchoice is pinned")
 		} else if !inSubtree {
 			return fmt.Errorf("cannot justify/finalize outside of pinned forkchoice tree")
@@ -114,7 +157,7 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 
 	// check if new finalized checkpoint is valid
 	if fc.finalized != finalized {
-		if unknown, inSubtree := fc.InSubtree(fc.finalized.Root, finalized.Root); unknown {
+		if unknown, inSubtree := fc.protoArray.InSubtree(fc.finalized.Root, finalized.Root); unknown {
 			return fmt.Errorf("unknown finalized checkpoint: %s", finalized)
 		} else if !inSubtree || fc.finalized.Epoch > finalized.Epoch {
 			return fmt.Errorf("new finalized checkpoint %s is outside of finalized subtree: %s",
@@ -122,7 +165,7 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 		}
 	}
 	if fc.justified != justified {
-		if unknown, inSubtree := fc.InSubtree(fc.finalized.Root, justified.Root); unknown {
+		if unknown, inSubtree := fc.protoArray.InSubtree(fc.finalized.Root, justified.Root); unknown {
 			return fmt.Errorf("unknown justified checkpoint: %s", justified)
 		} else if !inSubtree || fc.finalized.Epoch > justified.Epoch {
 			return fmt.Errorf("new justified checkpoint %s is outside of finalized subtree: %s",
@@ -136,31 +179,91 @@ func (fc *ProtoForkChoice) updateJustified(finalized Checkpoint, justified Check
 		return err
 	}
 
-	deltas := fc.voteStore.ComputeDeltas(fc.protoArray.Indices(), oldBals, newBals)
+	indices := fc.protoArray.Indices()
+	deltas := fc.voteStore.ComputeDeltas(indices, oldBals, newBals)
+	fc.balances = newBals
+	fc.applyProposerBoost(indices, deltas)
 
 	if err := fc.protoArray.ApplyScoreChanges(deltas, justified.Epoch, finalized.Epoch); err != nil {
 		return err
 	}
 
-	fc.balances = newBals
 	fc.justified = justified
 	fc.finalized = finalized
 
 	return nil
 }
 
+// UpdateBalances replaces the balances used to weigh the votes.
+// Only the votes of validators with a changed balance, or a changed vote, result in score changes.
+func (fc *ProtoForkChoice) UpdateBalances(newBalances []Gwei) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	indices := fc.protoArray.Indices()
+	deltas := fc.voteStore.ComputeDeltas(indices, fc.balances, newBalances)
+	fc.balances = newBalances
+	fc.applyProposerBoost(indices, deltas)
+
+	return fc.protoArray.ApplyScoreChanges(deltas, fc.justified.Epoch, fc.finalized.Epoch)
+}
+
 // TODO: skip based on time (like rate limiting) or based on amount of changes
 //  (if not bigger than previous difference between head-node contenders)
 func (fc *ProtoForkChoice) updateVotesMaybe() error {
-	if !fc.voteStore.HasChanges() {
+	if !fc.voteStore.HasChanges() && fc.proposerBoost == fc.appliedBoost {
 		return nil
 	}
 
-	deltas := fc.voteStore.ComputeDeltas(fc.protoArray.Indices(), fc.balances, fc.balances)
+	indices := fc.protoArray.Indices()
+	deltas := fc.voteStore.ComputeDeltas(indices, fc.balances, fc.balances)
+	fc.applyProposerBoost(indices, deltas)
 
 	return fc.protoArray.ApplyScoreChanges(deltas, fc.justified.Epoch, fc.finalized.Epoch)
 }
 
+// applyProposerBoost adds the score changes of the proposer boost to the deltas:
+// the previously applied boost is undone, and the current boost is applied, if the block is known.
+func (fc *ProtoForkChoice) applyProposerBoost(indices map[NodeRef]NodeIndex, deltas []SignedGwei) {
+	if fc.appliedBoost != nil {
+		if i, ok := indices[*fc.appliedBoost]; ok {
+			deltas[i] -= SignedGwei(fc.appliedBoostScore)
+		}
+		fc.appliedBoost = nil
+		fc.appliedBoostScore = 0
+	}
+	if fc.proposerBoost != nil {
+		if i, ok := indices[*fc.proposerBoost]; ok {
+			// The boost is a fraction of the weight of a single committee: total balance / SLOTS_PER_EPOCH
+			total := Gwei(0)
+			for _, b := range fc.balances {
+				total += b
+			}
+			score := (total / Gwei(fc.spec.SLOTS_PER_EPOCH)) * PROPOSER_SCORE_BOOST / 100
+			deltas[i] += SignedGwei(score)
+			fc.appliedBoost = fc.proposerBoost
+			fc.appliedBoostScore = score
+		}
+	}
+}
+
+func (fc *ProtoForkChoice) ApplyProposerBoost(root Root, slot Slot) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.proposerBoost = &NodeRef{Root: root, Slot: slot}
+}
+
+func (fc *ProtoForkChoice) ResetProposerBoost() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.proposerBoost = nil
+}
+
+func (fc *ProtoForkChoice) OnAttesterSlashing(slashed []ValidatorIndex) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.voteStore.OnAttesterSlashing(slashed)
+}
+
 func (fc *ProtoForkChoice) Justified() Checkpoint {
 	fc.mu.RLock()
 	defer fc.mu.RUnlock()
@@ -202,6 +305,208 @@ func (fc *ProtoForkChoice) ProcessBlock(parentRoot Root, blockRoot Root, blockSl
 	return fc.protoArray.ProcessBlock(parentRoot, blockRoot, blockSlot, justifiedEpoch, finalizedEpoch)
 }
 
+// OnBlock validates and inserts a block, given the post-state of the block.
+// The block must not be from the future: its slot must have been reached with OnTick.
+// The parent must be known, and the block must descend from the finalized checkpoint.
+// Newer justified and finalized checkpoints of the post-state are applied, with the balances of the post-state.
+func (fc *ProtoForkChoice) OnBlock(ctx context.Context, signedBlock *beacon.SignedBeaconBlock, state *beacon.BeaconStateView) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	block := &signedBlock.Message
+	if block.Slot > fc.currentSlot {
+		return fmt.Errorf("block slot %d is in the future, current slot is %d", block.Slot, fc.currentSlot)
+	}
+	parentSlot, ok := fc.protoArray.GetSlot(block.ParentRoot)
+	if !ok {
+		return fmt.Errorf("unknown parent block %s", block.ParentRoot)
+	}
+	if parentSlot >= block.Slot {
+		return fmt.Errorf("block slot %d is not after parent slot %d", block.Slot, parentSlot)
+	}
+	finSlot, _ := fc.spec.EpochStartSlot(fc.finalized.Epoch)
+	if block.Slot <= finSlot {
+		return fmt.Errorf("block slot %d is not after finalized slot %d", block.Slot, finSlot)
+	}
+	if unknown, inSubtree := fc.protoArray.InSubtree(fc.finalized.Root, block.ParentRoot); unknown || !inSubtree {
+		return fmt.Errorf("block does not descend from finalized checkpoint %s", fc.finalized)
+	}
+	stateSlot, err := state.Slot()
+	if err != nil {
+		return err
+	}
+	if stateSlot != block.Slot {
+		return fmt.Errorf("post-state slot %d does not match block slot %d", stateSlot, block.Slot)
+	}
+	justifiedCh, err := state.CurrentJustifiedCheckpoint()
+	if err != nil {
+		return err
+	}
+	justified, err := justifiedCh.Raw()
+	if err != nil {
+		return err
+	}
+	finalizedCh, err := state.FinalizedCheckpoint()
+	if err != nil {
+		return err
+	}
+	finalized, err := finalizedCh.Raw()
+	if err != nil {
+		return err
+	}
+
+	blockRoot := block.HashTreeRoot(fc.spec, tree.GetHashFn())
+	if !fc.protoArray.ProcessBlock(block.ParentRoot, blockRoot, block.Slot, justified.Epoch, finalized.Epoch) {
+		return fmt.Errorf("failed to add block %s to forkchoice", blockRoot)
+	}
+
+	balances := func() ([]Gwei, error) {
+		balancesView, err := state.Balances()
+		if err != nil {
+			return nil, err
+		}
+		return balancesView.AllBalances()
+	}
+	// Only move the checkpoints forward
+	if finalized.Epoch <= fc.finalized.Epoch {
+		finalized = fc.finalized
+		if justified.Epoch > fc.justified.Epoch && !fc.shouldUpdateJustified(justified) {
+			// Too late in the epoch to switch to a conflicting justified checkpoint, apply it at the next epoch.
+			fc.recordUnrealized(justified, finalized, balances)
+			justified = fc.justified
+		}
+	}
+	if justified.Epoch <= fc.justified.Epoch {
+		justified = fc.justified
+	}
+	return fc.applyCheckpoints(ctx, justified, finalized, balances)
+}
+
+// shouldUpdateJustified is the equivalent of `should_update_justified_checkpoint` in the eth2 spec:
+// during the first SAFE_SLOTS_TO_UPDATE_JUSTIFIED slots of an epoch the justified checkpoint can always be updated,
+// later only if the new justified checkpoint descends from the current justified checkpoint.
+func (fc *ProtoForkChoice) shouldUpdateJustified(newJustified Checkpoint) bool {
+	epochStart, _ := fc.spec.EpochStartSlot(fc.spec.SlotToEpoch(fc.currentSlot))
+	if uint64(fc.currentSlot-epochStart) < fc.spec.SAFE_SLOTS_TO_UPDATE_JUSTIFIED {
+		return true
+	}
+	_, inSubtree := fc.protoArray.InSubtree(fc.justified.Root, newJustified.Root)
+	return inSubtree
+}
+
+// applyCheckpoints updates the justified and finalized checkpoints, if they changed,
+// and prunes the forkchoice if the finalized checkpoint changed.
+func (fc *ProtoForkChoice) applyCheckpoints(ctx context.Context, justified Checkpoint, finalized Checkpoint,
+	justifiedStateBalances func() ([]Gwei, error)) error {
+	if justified == fc.justified && finalized == fc.finalized {
+		return nil
+	}
+	prevFinalized := fc.finalized
+	if err := fc.updateJustified(finalized, justified, justifiedStateBalances); err != nil {
+		return err
+	}
+	// prune if we finalized something, and undo the pin.
+	if prevFinalized != finalized {
+		fc.pin = nil
+		finSlot, _ := fc.spec.EpochStartSlot(finalized.Epoch)
+		if err := fc.protoArray.OnPrune(ctx, finalized.Root, finSlot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateUnrealized records the unrealized justified and finalized checkpoints of a block:
+// the checkpoints that the post-state of the block would have after processing justification and finalization,
+// see beacon.Spec.ProcessJustificationAndFinalization. Checkpoints that are not newer are ignored.
+// The checkpoints are applied by OnTick at the start of the next epoch.
+func (fc *ProtoForkChoice) UpdateUnrealized(justified Checkpoint, finalized Checkpoint,
+	justifiedStateBalances func() ([]Gwei, error)) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.recordUnrealized(justified, finalized, justifiedStateBalances)
+}
+
+func (fc *ProtoForkChoice) recordUnrealized(justified Checkpoint, finalized Checkpoint,
+	justifiedStateBalances func() ([]Gwei, error)) {
+	if justified.Epoch > fc.unrealizedJustified.Epoch {
+		fc.unrealizedJustified = justified
+		fc.unrealizedBalances = justifiedStateBalances
+	}
+	if finalized.Epoch > fc.unrealizedFinalized.Epoch {
+		fc.unrealizedFinalized = finalized
+	}
+}
+
+// ProcessBlockUnrealized sets the unrealized justified and finalized checkpoints of a block:
+// the checkpoints that the post-state of the block would have after processing justification and finalization.
+// Once the epoch of the block has passed, the block is viable for the head based on these checkpoints,
+// and they are applied at the start of the next epoch, like UpdateUnrealized.
+// If the block is of a previous epoch already, the checkpoints are applied immediately.
+func (fc *ProtoForkChoice) ProcessBlockUnrealized(blockRoot Root, justified Checkpoint, finalized Checkpoint) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	blockSlot, ok := fc.protoArray.GetSlot(blockRoot)
+	if !ok || !fc.protoArray.ProcessBlockUnrealized(blockRoot, justified.Epoch, finalized.Epoch) {
+		return fmt.Errorf("unknown block %s", blockRoot)
+	}
+	// Without balances, the current balances are used when applying the checkpoints.
+	fc.recordUnrealized(justified, finalized, nil)
+	if fc.spec.SlotToEpoch(blockSlot) >= fc.spec.SlotToEpoch(fc.currentSlot) {
+		return nil
+	}
+	if justified.Epoch <= fc.justified.Epoch {
+		justified = fc.justified
+	}
+	if finalized.Epoch <= fc.finalized.Epoch {
+		finalized = fc.finalized
+	}
+	return fc.applyCheckpoints(context.Background(), justified, finalized, func() ([]Gwei, error) {
+		return fc.balances, nil
+	})
+}
+
+// OnTick advances the current slot to the slot of the given time, one slot at a time.
+// At the start of every slot the proposer boost is reset, and at the start of every epoch
+// the unrealized checkpoints are applied, if they are newer than the justified and finalized checkpoints.
+// Time does not go backwards: ticks of earlier slots are ignored.
+func (fc *ProtoForkChoice) OnTick(time Timestamp) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	slot := fc.spec.TimeToSlot(time, fc.genesisTime)
+	for fc.currentSlot < slot {
+		fc.currentSlot++
+		fc.proposerBoost = nil
+		if fc.currentSlot%fc.spec.SLOTS_PER_EPOCH != 0 {
+			continue
+		}
+		fc.protoArray.SetCurrentEpochStart(fc.currentSlot)
+		justified, finalized := fc.justified, fc.finalized
+		balances := func() ([]Gwei, error) {
+			return fc.balances, nil
+		}
+		if fc.unrealizedJustified.Epoch > justified.Epoch {
+			justified = fc.unrealizedJustified
+			if fc.unrealizedBalances != nil {
+				balances = fc.unrealizedBalances
+			}
+		}
+		if fc.unrealizedFinalized.Epoch > finalized.Epoch {
+			finalized = fc.unrealizedFinalized
+		}
+		if err := fc.applyCheckpoints(context.Background(), justified, finalized, balances); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurrentSlot returns the current slot, as advanced by OnTick.
+func (fc *ProtoForkChoice) CurrentSlot() Slot {
+	fc.mu.RLock()
+	defer fc.mu.RUnlock()
+	return fc.currentSlot
+}
+
 func (fc *ProtoForkChoice) InSubtree(anchor Root, root Root) (unknown bool, inSubtree bool) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
@@ -241,6 +546,9 @@ func (fc *ProtoForkChoice) FindHead(anchorRoot Root, anchorSlot Slot) (NodeRef,
 	return fc.protoArray.FindHead(anchorRoot, anchorSlot)
 }
 
+// Head finds the head, starting from the justified checkpoint, or the pin if any.
+// The proposer boost and checkpoints are only as recent as the last OnTick:
+// call OnTick with the current time first, to not get a head boosted by a block of a previous slot.
 func (fc *ProtoForkChoice) Head() (NodeRef, error) {
 	fc.mu.Lock()
 	defer fc.mu.Unlock()
@@ -10,6 +10,7 @@ type Epoch = beacon.Epoch
 type Slot = beacon.Slot
 type ValidatorIndex = beacon.ValidatorIndex
 type Gwei = beacon.Gwei
+type Timestamp = beacon.Timestamp
 type Checkpoint = beacon.Checkpoint
 type NodeRef = beacon.NodeRef
 type ExtendedNodeRef = beacon.ExtendedNodeRef
@@ -36,6 +37,11 @@ type ForkchoiceGraph interface {
 	ForkchoiceNodeInput
 	Indices() map[NodeRef]NodeIndex
 	ApplyScoreChanges(deltas []SignedGwei, justifiedEpoch Epoch, finalizedEpoch Epoch) error
+	// ProcessBlockUnrealized sets the unrealized justified and finalized epochs of a block. Returns false if unknown.
+	ProcessBlockUnrealized(blockRoot Root, justifiedEpoch Epoch, finalizedEpoch Epoch) (ok bool)
+	// SetCurrentEpochStart changes the start of the current epoch: nodes of earlier slots are viable for the head
+	// based on their unrealized justification and finalization.
+	SetCurrentEpochStart(slot Slot)
 	OnPrune(ctx context.Context, anchorRoot Root, anchorSlot Slot) error
 }
 
@@ -48,6 +54,9 @@ type VoteInput interface {
 
 type VoteStore interface {
 	VoteInput
+	// OnAttesterSlashing marks the validators as equivocating, the weight of their votes is removed
+	// with the next ComputeDeltas, and any later votes of them are ignored.
+	OnAttesterSlashing(slashed []ValidatorIndex)
 	HasChanges() bool
 	ComputeDeltas(indices map[NodeRef]NodeIndex, oldBalances []Gwei, newBalances []Gwei) []SignedGwei
 }
@@ -58,9 +67,38 @@ type Forkchoice interface {
 	VoteInput
 	UpdateJustified(ctx context.Context, trigger Root, justified Checkpoint, finalized Checkpoint,
 		justifiedStateBalances func() ([]Gwei, error)) error
+	// UpdateBalances replaces the balances that weigh the votes, without changing the justified checkpoint.
+	// Scores are adjusted incrementally, only for the votes of validators with a changed balance.
+	UpdateBalances(newBalances []Gwei) error
 	Pin() *NodeRef
 	SetPin(root Root, slot Slot) error
+	// SetGenesisTime sets the genesis time that OnTick computes the current slot with. Zero if not set.
+	SetGenesisTime(genesisTime Timestamp)
 	Justified() Checkpoint
 	Finalized() Checkpoint
+	// Head finds the head, as of the last OnTick.
 	Head() (NodeRef, error)
+	// ApplyProposerBoost boosts the score of the given block, and thus its ancestors,
+	// until ResetProposerBoost is called. Replaces any previous boost.
+	// The caller is responsible to only boost timely blocks, see IsTimelyBlock,
+	// and to reset the boost at the start of the next slot.
+	ApplyProposerBoost(root Root, slot Slot)
+	ResetProposerBoost()
+	// OnAttesterSlashing marks the slashed validators as equivocating:
+	// their votes do not count anymore, starting with the next head computation.
+	OnAttesterSlashing(slashed []ValidatorIndex)
+	// OnBlock validates and adds a block, given its post-state, and applies the justification
+	// and finalization of the post-state.
+	OnBlock(ctx context.Context, block *beacon.SignedBeaconBlock, state *beacon.BeaconStateView) error
+	// UpdateUnrealized records the unrealized justified and finalized checkpoints of a block,
+	// to apply them at the start of the next epoch.
+	UpdateUnrealized(justified Checkpoint, finalized Checkpoint, justifiedStateBalances func() ([]Gwei, error))
+	// OnTick advances the current slot to the given time. At the start of every slot the proposer boost is reset,
+	// and at the start of every epoch the unrealized checkpoints are applied.
+	// Call OnTick before Head, for the head to account for the current time.
+	OnTick(time Timestamp) error
+	// ProcessBlockUnrealized sets the unrealized justified and finalized checkpoints of a block,
+	// to pull up the block once its epoch has passed.
+	ProcessBlockUnrealized(blockRoot Root, justified Checkpoint, finalized Checkpoint) error
+	CurrentSlot() Slot
 }
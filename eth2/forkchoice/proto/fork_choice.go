@@ -7,8 +7,8 @@ import (
 
 func NewProtoForkChoice(spec *beacon.Spec, finalized Checkpoint, justified Checkpoint,
 	anchorRoot Root, anchorSlot Slot, anchorParent Root,
-	initialBalances []Gwei, sink NodeSink) (Forkchoice, error) {
+	initialBalances []Gwei, sink NodeSink, opts ...ProtoArrayOption) (Forkchoice, error) {
 	return NewForkChoice(spec, finalized, justified, anchorRoot, anchorSlot,
-		NewProtoArray(anchorParent, anchorRoot, anchorSlot, justified.Epoch, finalized.Epoch, sink),
+		NewProtoArray(anchorParent, anchorRoot, anchorSlot, justified.Epoch, finalized.Epoch, sink, opts...),
 		NewProtoVoteStore(spec), initialBalances)
 }
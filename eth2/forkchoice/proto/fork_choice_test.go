@@ -2,9 +2,13 @@ package proto
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
 	"github.com/protolambda/zrnt/eth2/forkchoice"
 	"github.com/protolambda/zrnt/eth2/forkchoice/internal/fctest"
+	"github.com/protolambda/ztyp/tree"
 	"testing"
 )
 
@@ -29,3 +33,470 @@ func TestProtoArray(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestProposerBoost(t *testing.T) {
+	spec := configs.Mainnet
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	// Enough validators for the boost to outweigh a single vote.
+	balances := make([]forkchoice.Gwei, 128)
+	for i := range balances {
+		balances[i] = spec.MAX_EFFECTIVE_BALANCE
+	}
+	fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), balances,
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHead := func(expected forkchoice.NodeRef) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head != expected {
+			t.Fatalf("unexpected head: %s <> %s", head, expected)
+		}
+	}
+	// Block 1 at slot 1 gets a vote, competing block 2 at slot 2 arrives in time for a proposer boost.
+	//
+	//          0
+	//         / \
+	//        1   *
+	//            |
+	//            2
+	fc.ProcessBlock(hash(0), hash(1), 1, 0, 0)
+	if !fc.ProcessAttestation(0, hash(1), 1) {
+		t.Fatal("failed to add vote")
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+	fc.ProcessBlock(hash(0), hash(2), 2, 0, 0)
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+
+	genesisTime := forkchoice.Timestamp(1000)
+	if forkchoice.IsTimelyBlock(spec, genesisTime, 2, genesisTime+2*spec.SECONDS_PER_SLOT+spec.SECONDS_PER_SLOT/2) {
+		t.Fatal("block is not timely after the first interval of its slot")
+	}
+	if !forkchoice.IsTimelyBlock(spec, genesisTime, 2, genesisTime+2*spec.SECONDS_PER_SLOT+1) {
+		t.Fatal("block is timely in the first interval of its slot")
+	}
+	fc.ApplyProposerBoost(hash(2), 2)
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+
+	// The boost is removed at the next slot, and the vote decides again.
+	fc.ResetProposerBoost()
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+}
+
+func TestTieBreaker(t *testing.T) {
+	spec := configs.Mainnet
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	balances := make([]forkchoice.Gwei, 8)
+	for i := range balances {
+		balances[i] = spec.MAX_EFFECTIVE_BALANCE
+	}
+	sink := NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+		return nil
+	})
+	lowerRoot := func(a, b *ProtoNode) bool {
+		return !HigherRootTieBreaker(a, b)
+	}
+	// Two competing blocks of the same parent, each with the vote of one validator:
+	// validator 0 votes for block 1, validator 1 for block 2.
+	//
+	//          0
+	//         / \
+	//        1   2
+	for _, c := range []struct {
+		name string
+		opts []ProtoArrayOption
+		// The block that wins the tie, the other block is the loser.
+		winner, loser uint64
+	}{
+		{"default", nil, 2, 1},
+		{"lower root", []ProtoArrayOption{WithTieBreaker(lowerRoot)}, 1, 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), balances, sink, c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectHead := func(expected forkchoice.NodeRef) {
+				t.Helper()
+				head, err := fc.Head()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if head != expected {
+					t.Fatalf("unexpected head: %s <> %s", head, expected)
+				}
+			}
+			fc.ProcessBlock(hash(0), hash(1), 1, 0, 0)
+			fc.ProcessBlock(hash(0), hash(2), 1, 0, 0)
+			for i := uint64(0); i < 2; i++ {
+				if !fc.ProcessAttestation(forkchoice.ValidatorIndex(i), hash(i+1), 1) {
+					t.Fatal("failed to add vote")
+				}
+			}
+			expectHead(forkchoice.NodeRef{Root: hash(c.winner), Slot: 1})
+
+			// The voter of the winner moves to a child of the loser, in the next epoch:
+			// the weight decides, not the tie-breaker.
+			nextEpoch, _ := spec.EpochStartSlot(1)
+			fc.ProcessBlock(hash(c.loser), hash(3), nextEpoch, 0, 0)
+			if !fc.ProcessAttestation(forkchoice.ValidatorIndex(c.winner-1), hash(3), nextEpoch) {
+				t.Fatal("failed to move vote")
+			}
+			expectHead(forkchoice.NodeRef{Root: hash(3), Slot: nextEpoch})
+		})
+	}
+}
+
+func TestAttesterSlashing(t *testing.T) {
+	spec := configs.Mainnet
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	// Validator 0 outweighs the other validators together.
+	balances := []forkchoice.Gwei{3 * spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE}
+	fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), balances,
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHead := func(expected forkchoice.NodeRef) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head != expected {
+			t.Fatalf("unexpected head: %s <> %s", head, expected)
+		}
+	}
+	//          0
+	//         / \
+	//        1   *
+	//        |   |
+	//        .   2
+	//        |
+	//        3
+	fc.ProcessBlock(hash(0), hash(1), 1, 0, 0)
+	fc.ProcessBlock(hash(0), hash(2), 2, 0, 0)
+	fc.ProcessAttestation(0, hash(1), 1)
+	fc.ProcessAttestation(1, hash(2), 2)
+	fc.ProcessAttestation(2, hash(2), 2)
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+
+	// The heavy voter is slashed, and the other votes decide.
+	fc.OnAttesterSlashing([]forkchoice.ValidatorIndex{0})
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+
+	// Later votes of the slashed validator are ignored.
+	fc.ProcessBlock(hash(1), hash(3), spec.SLOTS_PER_EPOCH, 0, 0)
+	if !fc.ProcessAttestation(0, hash(3), spec.SLOTS_PER_EPOCH) {
+		t.Fatal("attestation of slashed validator should still be accepted")
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+
+	// Slashing again does not remove any more weight.
+	fc.OnAttesterSlashing([]forkchoice.ValidatorIndex{0})
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+}
+
+func TestUpdateBalances(t *testing.T) {
+	spec := configs.Mainnet
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	balances := []forkchoice.Gwei{3 * spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE}
+	fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), balances,
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHead := func(expected forkchoice.NodeRef) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head != expected {
+			t.Fatalf("unexpected head: %s <> %s", head, expected)
+		}
+	}
+	fc.ProcessBlock(hash(0), hash(1), 1, 0, 0)
+	fc.ProcessBlock(hash(0), hash(2), 2, 0, 0)
+	fc.ProcessAttestation(0, hash(1), 1)
+	fc.ProcessAttestation(1, hash(2), 2)
+	fc.ProcessAttestation(2, hash(2), 2)
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+
+	// The heavy voter loses balance, and is outweighed by the other votes.
+	if err := fc.UpdateBalances([]forkchoice.Gwei{spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE}); err != nil {
+		t.Fatal(err)
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+
+	// The heavy voter regains balance. Validator 1 loses its balance,
+	// and the balance of a new validator without a vote does not count.
+	if err := fc.UpdateBalances([]forkchoice.Gwei{3 * spec.MAX_EFFECTIVE_BALANCE, 0, spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE}); err != nil {
+		t.Fatal(err)
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+}
+
+func TestOnBlock(t *testing.T) {
+	spec := configs.Minimal
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	validators := make([]beacon.KickstartValidatorData, 64)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	genesisState, epc, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The post-states are only checked for their slot and checkpoints, empty slots are good enough.
+	postState := func(slot beacon.Slot, justified beacon.Checkpoint) *beacon.BeaconStateView {
+		state, err := beacon.AsBeaconStateView(genesisState.Copy())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.ProcessSlots(context.Background(), epc.Clone(), state, slot); err != nil {
+			t.Fatal(err)
+		}
+		justifiedCh, err := state.CurrentJustifiedCheckpoint()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := justifiedCh.Set(&justified); err != nil {
+			t.Fatal(err)
+		}
+		return state
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), make([]forkchoice.Gwei, 64),
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	hFn := tree.GetHashFn()
+
+	block1 := &beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: 1, ParentRoot: hash(0)}}
+	if err := fc.OnBlock(ctx, block1, postState(1, genesis)); err == nil {
+		t.Fatal("expected block from the future to be rejected")
+	}
+	if err := fc.OnTick(spec.SECONDS_PER_SLOT); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.OnBlock(ctx, block1, postState(2, genesis)); err == nil {
+		t.Fatal("expected post-state of different slot to be rejected")
+	}
+	if err := fc.OnBlock(ctx, block1, postState(1, genesis)); err != nil {
+		t.Fatal(err)
+	}
+	root1 := block1.Message.HashTreeRoot(spec, hFn)
+	if slot, ok := fc.GetSlot(root1); !ok || slot != 1 {
+		t.Fatalf("expected block to be added at slot 1, got %d (known: %v)", slot, ok)
+	}
+
+	unknownParent := &beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: 1, ParentRoot: hash(42)}}
+	if err := fc.OnBlock(ctx, unknownParent, postState(1, genesis)); err == nil {
+		t.Fatal("expected block with unknown parent to be rejected")
+	}
+
+	// A later block justifies the first block
+	justified := forkchoice.Checkpoint{Root: root1, Epoch: 1}
+	block2 := &beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: spec.SLOTS_PER_EPOCH + 1, ParentRoot: root1}}
+	if err := fc.OnTick(forkchoice.Timestamp(spec.SLOTS_PER_EPOCH+1) * spec.SECONDS_PER_SLOT); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.OnBlock(ctx, block2, postState(spec.SLOTS_PER_EPOCH+1, justified)); err != nil {
+		t.Fatal(err)
+	}
+	if got := fc.Justified(); got != justified {
+		t.Fatalf("expected justified checkpoint %s, got %s", justified, got)
+	}
+	head, err := fc.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root2 := block2.Message.HashTreeRoot(spec, hFn)
+	if expected := (forkchoice.NodeRef{Root: root2, Slot: spec.SLOTS_PER_EPOCH + 1}); head != expected {
+		t.Fatalf("unexpected head: %s <> %s", head, expected)
+	}
+}
+
+func TestOnTick(t *testing.T) {
+	spec := configs.Mainnet
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	genesisTime := forkchoice.Timestamp(1000)
+	slotTime := func(slot forkchoice.Slot) forkchoice.Timestamp {
+		return genesisTime + forkchoice.Timestamp(slot)*spec.SECONDS_PER_SLOT
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	balances := make([]forkchoice.Gwei, 128)
+	for i := range balances {
+		balances[i] = spec.MAX_EFFECTIVE_BALANCE
+	}
+	fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), balances,
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc.SetGenesisTime(genesisTime)
+	expectHead := func(expected forkchoice.NodeRef) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head != expected {
+			t.Fatalf("unexpected head: %s <> %s", head, expected)
+		}
+	}
+	// Block 2 is boosted in its own slot, and outweighs the vote for block 1.
+	fc.ProcessBlock(hash(0), hash(1), 1, 0, 0)
+	fc.ProcessBlock(hash(0), hash(2), 2, 0, 0)
+	fc.ProcessAttestation(0, hash(1), 1)
+	if err := fc.OnTick(slotTime(2)); err != nil {
+		t.Fatal(err)
+	}
+	fc.ApplyProposerBoost(hash(2), 2)
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+
+	// Ticks within the same slot keep the boost
+	if err := fc.OnTick(slotTime(2) + 1); err != nil {
+		t.Fatal(err)
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: 2})
+
+	// The next slot resets the boost
+	if err := fc.OnTick(slotTime(3)); err != nil {
+		t.Fatal(err)
+	}
+	if fc.CurrentSlot() != 3 {
+		t.Fatalf("unexpected current slot: %d", fc.CurrentSlot())
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(1), Slot: 1})
+
+	// Unrealized checkpoints are only applied at the start of the next epoch.
+	justified := forkchoice.Checkpoint{Root: hash(1), Epoch: 1}
+	fc.UpdateUnrealized(justified, genesis, func() ([]forkchoice.Gwei, error) {
+		return balances, nil
+	})
+	if err := fc.OnTick(slotTime(spec.SLOTS_PER_EPOCH - 1)); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Justified() != genesis {
+		t.Fatalf("unrealized checkpoint applied too early: %s", fc.Justified())
+	}
+	if err := fc.OnTick(slotTime(spec.SLOTS_PER_EPOCH)); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Justified() != justified {
+		t.Fatalf("expected unrealized checkpoint %s to be applied, got %s", justified, fc.Justified())
+	}
+	// Older unrealized checkpoints are ignored.
+	fc.UpdateUnrealized(genesis, genesis, nil)
+	if err := fc.OnTick(slotTime(2 * spec.SLOTS_PER_EPOCH)); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Justified() != justified {
+		t.Fatalf("expected justified checkpoint to stay %s, got %s", justified, fc.Justified())
+	}
+}
+
+func TestPullUpTips(t *testing.T) {
+	spec := configs.Mainnet
+	hash := func(i uint64) (out forkchoice.Root) {
+		binary.LittleEndian.PutUint64(out[:8], i)
+		return
+	}
+	slotTime := func(slot forkchoice.Slot) forkchoice.Timestamp {
+		return forkchoice.Timestamp(slot) * spec.SECONDS_PER_SLOT
+	}
+	genesis := forkchoice.Checkpoint{Root: hash(0), Epoch: 0}
+	balances := []forkchoice.Gwei{spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE, spec.MAX_EFFECTIVE_BALANCE}
+	fc, err := NewProtoForkChoice(spec, genesis, genesis, hash(0), 0, hash(0), balances,
+		NodeSinkFn(func(ctx context.Context, ref forkchoice.NodeRef, canonical bool) error {
+			return nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHead := func(expected forkchoice.NodeRef) {
+		t.Helper()
+		head, err := fc.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head != expected {
+			t.Fatalf("unexpected head: %s <> %s", head, expected)
+		}
+	}
+	//          0
+	//          |
+	//          1
+	//         / \
+	//        2   3
+	// Block 2 justifies epoch 1 with the attestations it includes, but that is only realized at the next epoch.
+	epoch1 := spec.SLOTS_PER_EPOCH
+	fc.ProcessBlock(hash(0), hash(1), 1, 0, 0)
+	fc.ProcessBlock(hash(1), hash(2), epoch1+1, 0, 0)
+	fc.ProcessBlock(hash(1), hash(3), epoch1+2, 0, 0)
+	fc.ProcessAttestation(0, hash(3), epoch1+2)
+	fc.ProcessAttestation(1, hash(3), epoch1+2)
+	if err := fc.OnTick(slotTime(epoch1 + 2)); err != nil {
+		t.Fatal(err)
+	}
+	justified := forkchoice.Checkpoint{Root: hash(1), Epoch: 1}
+	if err := fc.ProcessBlockUnrealized(hash(2), justified, genesis); err != nil {
+		t.Fatal(err)
+	}
+	if err := fc.ProcessBlockUnrealized(hash(42), justified, genesis); err == nil {
+		t.Fatal("expected error for unknown block")
+	}
+	// Within the epoch, the unrealized justification does not matter yet.
+	expectHead(forkchoice.NodeRef{Root: hash(3), Slot: epoch1 + 2})
+
+	// At the next epoch, block 2 is pulled up and justifies epoch 1, block 3 is not viable anymore.
+	if err := fc.OnTick(slotTime(2 * epoch1)); err != nil {
+		t.Fatal(err)
+	}
+	if fc.Justified() != justified {
+		t.Fatalf("expected justified checkpoint %s, got %s", justified, fc.Justified())
+	}
+	expectHead(forkchoice.NodeRef{Root: hash(2), Slot: epoch1 + 1})
+}
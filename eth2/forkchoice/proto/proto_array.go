@@ -22,7 +22,12 @@ type ProtoNode struct {
 	ParentRoot     Root
 	JustifiedEpoch Epoch
 	FinalizedEpoch Epoch
-	Weight         SignedGwei
+	// The justified and finalized epochs that the state of the node would have after the epoch processing,
+	// i.e. including the justification and finalization by the attestations in the current epoch.
+	// The node is viable for the head based on these once its epoch has passed.
+	UnrealizedJustifiedEpoch Epoch
+	UnrealizedFinalizedEpoch Epoch
+	Weight                   SignedGwei
 	// Relative to ForkchoiceParent relations
 	BestChild NodeIndex
 	// Relative to ForkchoiceParent relations
@@ -39,6 +44,23 @@ type NodeSink interface {
 	OnPrunedNode(ctx context.Context, ref NodeRef, canonical bool) error
 }
 
+// TieBreaker decides the best child between two children of equal weight: it returns true if a is better than b.
+type TieBreaker func(a, b *ProtoNode) bool
+
+// HigherRootTieBreaker prefers the child with the higher root. This is the default tie-breaker.
+func HigherRootTieBreaker(a, b *ProtoNode) bool {
+	return bytes.Compare(a.Ref.Root[:], b.Ref.Root[:]) > 0
+}
+
+type ProtoArrayOption func(pr *ProtoArray)
+
+// WithTieBreaker changes the tie-breaker of children with equal weight, see HigherRootTieBreaker for the default.
+func WithTieBreaker(tieBreaker TieBreaker) ProtoArrayOption {
+	return func(pr *ProtoArray) {
+		pr.tieBreaker = tieBreaker
+	}
+}
+
 // Tracks slots and blocks as nodes.
 // Every block has two nodes: with and without the block. The node with the block is the child of that without it.
 // Gap slots just have a single node.
@@ -48,18 +70,22 @@ type ProtoArray struct {
 	indexOffset    NodeIndex
 	justifiedEpoch Epoch
 	finalizedEpoch Epoch
-	nodes          []ProtoNode
+	// Nodes before the start slot of the current epoch are viable for the head based on
+	// their unrealized justification and finalization.
+	currentEpochStart Slot
+	nodes             []ProtoNode
 	// maintains only nodes that are actually part of the tree starting from finalized point.
 	indices map[NodeRef]NodeIndex
 	// Tracks the first slot at or after the block root that the array knows of.
 	// The lowest slot for a block does not equal the block.slot itself, that may have been pruned.
 	blockSlots         map[Root]Slot
 	updatedConnections bool
+	tieBreaker         TieBreaker
 }
 
 var _ ForkchoiceGraph = (*ProtoArray)(nil)
 
-func NewProtoArray(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch, sink NodeSink) *ProtoArray {
+func NewProtoArray(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch Epoch, finalizedEpoch Epoch, sink NodeSink, opts ...ProtoArrayOption) *ProtoArray {
 	blockRef := NodeRef{Root: blockRoot, Slot: blockSlot}
 	pr := ProtoArray{
 		sink:               sink,
@@ -70,19 +96,25 @@ func NewProtoArray(parent Root, blockRoot Root, blockSlot Slot, justifiedEpoch E
 		indices:            make(map[NodeRef]NodeIndex, 100),
 		blockSlots:         make(map[Root]Slot, 100),
 		updatedConnections: true,
+		tieBreaker:         HigherRootTieBreaker,
+	}
+	for _, opt := range opts {
+		opt(&pr)
 	}
 	pr.blockSlots[blockRoot] = blockSlot
 	pr.indices[blockRef] = 0
 	pr.nodes = append(pr.nodes, ProtoNode{
-		Ref:              blockRef,
-		TransitionParent: NONE,
-		ForkchoiceParent: NONE,
-		ParentRoot:       parent,
-		JustifiedEpoch:   justifiedEpoch,
-		FinalizedEpoch:   finalizedEpoch,
-		Weight:           0,
-		BestChild:        NONE,
-		BestDescendant:   NONE,
+		Ref:                      blockRef,
+		TransitionParent:         NONE,
+		ForkchoiceParent:         NONE,
+		ParentRoot:               parent,
+		JustifiedEpoch:           justifiedEpoch,
+		FinalizedEpoch:           finalizedEpoch,
+		UnrealizedJustifiedEpoch: justifiedEpoch,
+		UnrealizedFinalizedEpoch: finalizedEpoch,
+		Weight:                   0,
+		BestChild:                NONE,
+		BestDescendant:           NONE,
 	})
 	return &pr
 }
@@ -364,16 +396,19 @@ func (pr *ProtoArray) ProcessSlot(parent Root, slot Slot, justifiedEpoch Epoch,
 			nodeIndex = pr.indexOffset + NodeIndex(len(pr.nodes))
 			pr.indices[nodeRef] = nodeIndex
 			pr.nodes = append(pr.nodes, ProtoNode{
-				Ref:              nodeRef,
-				TransitionParent: parentIndex,
-				ForkchoiceParent: parentIndex,
-				ParentRoot:       parent,
-				JustifiedEpoch:   justifiedEpoch,
-				FinalizedEpoch:   finalizedEpoch,
-				Weight:           0,
-				BestChild:        NONE,
-				BestDescendant:   NONE,
+				Ref:                      nodeRef,
+				TransitionParent:         parentIndex,
+				ForkchoiceParent:         parentIndex,
+				ParentRoot:               parent,
+				JustifiedEpoch:           justifiedEpoch,
+				FinalizedEpoch:           finalizedEpoch,
+				UnrealizedJustifiedEpoch: justifiedEpoch,
+				UnrealizedFinalizedEpoch: finalizedEpoch,
+				Weight:                   0,
+				BestChild:                NONE,
+				BestDescendant:           NONE,
 			})
+			pr.inheritUnrealized(&pr.nodes[len(pr.nodes)-1])
 			// remember the node as parent for the next
 			parentIndex = nodeIndex
 		}
@@ -382,20 +417,71 @@ func (pr *ProtoArray) ProcessSlot(parent Root, slot Slot, justifiedEpoch Epoch,
 	nodeIndex := pr.indexOffset + NodeIndex(len(pr.nodes))
 	pr.indices[nodeRef] = nodeIndex
 	pr.nodes = append(pr.nodes, ProtoNode{
-		Ref:              nodeRef,
-		TransitionParent: parentIndex,
-		ForkchoiceParent: parentIndex,
-		ParentRoot:       parent,
-		JustifiedEpoch:   justifiedEpoch,
-		FinalizedEpoch:   finalizedEpoch,
-		Weight:           0,
-		BestChild:        NONE,
-		BestDescendant:   NONE,
+		Ref:                      nodeRef,
+		TransitionParent:         parentIndex,
+		ForkchoiceParent:         parentIndex,
+		ParentRoot:               parent,
+		JustifiedEpoch:           justifiedEpoch,
+		FinalizedEpoch:           finalizedEpoch,
+		UnrealizedJustifiedEpoch: justifiedEpoch,
+		UnrealizedFinalizedEpoch: finalizedEpoch,
+		Weight:                   0,
+		BestChild:                NONE,
+		BestDescendant:           NONE,
 	})
+	pr.inheritUnrealized(&pr.nodes[len(pr.nodes)-1])
 	// Connections are out of sync, i.e. array needs work before next find-head can return the proper head.
 	pr.updatedConnections = false
 }
 
+// inheritUnrealized applies the unrealized justification of the forkchoice parent to an empty slot node of the same block.
+func (pr *ProtoArray) inheritUnrealized(node *ProtoNode) {
+	if node.ForkchoiceParent == NONE {
+		return
+	}
+	parent, err := pr.getNode(node.ForkchoiceParent)
+	if err != nil || parent.Ref.Root != node.Ref.Root {
+		return
+	}
+	if parent.UnrealizedJustifiedEpoch > node.UnrealizedJustifiedEpoch {
+		node.UnrealizedJustifiedEpoch = parent.UnrealizedJustifiedEpoch
+	}
+	if parent.UnrealizedFinalizedEpoch > node.UnrealizedFinalizedEpoch {
+		node.UnrealizedFinalizedEpoch = parent.UnrealizedFinalizedEpoch
+	}
+}
+
+// ProcessBlockUnrealized sets the unrealized justified and finalized epochs of a block,
+// and of the empty slot nodes after the block. Returns false if the block is unknown.
+func (pr *ProtoArray) ProcessBlockUnrealized(blockRoot Root, justifiedEpoch Epoch, finalizedEpoch Epoch) (ok bool) {
+	if _, ok := pr.blockSlots[blockRoot]; !ok {
+		return false
+	}
+	for i := range pr.nodes {
+		node := &pr.nodes[i]
+		if node.Ref.Root != blockRoot {
+			continue
+		}
+		if justifiedEpoch > node.UnrealizedJustifiedEpoch {
+			node.UnrealizedJustifiedEpoch = justifiedEpoch
+		}
+		if finalizedEpoch > node.UnrealizedFinalizedEpoch {
+			node.UnrealizedFinalizedEpoch = finalizedEpoch
+		}
+	}
+	pr.updatedConnections = false
+	return true
+}
+
+// SetCurrentEpochStart changes the start slot of the current epoch:
+// nodes before it are viable for the head based on their unrealized justification and finalization.
+func (pr *ProtoArray) SetCurrentEpochStart(slot Slot) {
+	if slot != pr.currentEpochStart {
+		pr.currentEpochStart = slot
+		pr.updatedConnections = false
+	}
+}
+
 // Register a block with the fork choice. Calls OnSlot to add any missing slot nodes.
 // If justified or finalized in-between, make sure to call OnSlot with accurate details first.
 //
@@ -429,15 +515,17 @@ func (pr *ProtoArray) ProcessBlock(parent Root, blockRoot Root, blockSlot Slot,
 	pr.blockSlots[blockRoot] = blockSlot
 	pr.indices[blockRef] = nodeIndex
 	pr.nodes = append(pr.nodes, ProtoNode{
-		Ref:              blockRef,
-		TransitionParent: transitionParentIndex,
-		ForkchoiceParent: forkchoiceParentIndex,
-		ParentRoot:       parent,
-		JustifiedEpoch:   justifiedEpoch,
-		FinalizedEpoch:   finalizedEpoch,
-		Weight:           0,
-		BestChild:        NONE,
-		BestDescendant:   NONE,
+		Ref:                      blockRef,
+		TransitionParent:         transitionParentIndex,
+		ForkchoiceParent:         forkchoiceParentIndex,
+		ParentRoot:               parent,
+		JustifiedEpoch:           justifiedEpoch,
+		FinalizedEpoch:           finalizedEpoch,
+		UnrealizedJustifiedEpoch: justifiedEpoch,
+		UnrealizedFinalizedEpoch: finalizedEpoch,
+		Weight:                   0,
+		BestChild:                NONE,
+		BestDescendant:           NONE,
 	})
 	// Connections are out of sync, i.e. array needs work before next find-head can return the proper head.
 	pr.updatedConnections = false
@@ -686,8 +774,8 @@ func (pr *ProtoArray) maybeUpdateBestChildAndDescendant(parentIndex NodeIndex, c
 				// The best child leads to a viable head, but the child doesn't.
 				// *No change*
 			} else if child.Weight == bestChild.Weight {
-				// Tie-breaker of equal weights by root.
-				if bytes.Compare(child.Ref.Root[:], bestChild.Ref.Root[:]) > 0 {
+				// Tie-breaker of equal weights, by root by default.
+				if pr.tieBreaker(child, bestChild) {
 					changeToChild()
 				}
 				// otherwise *no change*
@@ -724,12 +812,17 @@ func (pr *ProtoArray) nodeLeadsToViableHead(node *ProtoNode) (bool, error) {
 	}
 }
 
-//This is the equivalent to the `filter_block_tree` function in the eth2 spec:
+// This is the equivalent to the `filter_block_tree` function in the eth2 spec:
 //
-//https://github.com/ethereum/eth2.0-specs/blob/v0.11.1/specs/phase0/fork-choice.md#filter_block_tree
+// https://github.com/ethereum/eth2.0-specs/blob/v0.11.1/specs/phase0/fork-choice.md#filter_block_tree
 //
-//Any node that has a different finalized or justified epoch should not be viable for the head.
+// Any node that has a different finalized or justified epoch should not be viable for the head.
+// Nodes of previous epochs are pulled up: their unrealized justified and finalized epochs are used instead.
 func (pr *ProtoArray) isNodeViableForHead(node *ProtoNode) bool {
-	return (node.JustifiedEpoch == pr.justifiedEpoch || pr.justifiedEpoch == beacon.GENESIS_EPOCH) &&
-		(node.FinalizedEpoch == pr.finalizedEpoch || pr.finalizedEpoch == beacon.GENESIS_EPOCH)
+	justifiedEpoch, finalizedEpoch := node.JustifiedEpoch, node.FinalizedEpoch
+	if node.Ref.Slot < pr.currentEpochStart {
+		justifiedEpoch, finalizedEpoch = node.UnrealizedJustifiedEpoch, node.UnrealizedFinalizedEpoch
+	}
+	return (justifiedEpoch == pr.justifiedEpoch || pr.justifiedEpoch == beacon.GENESIS_EPOCH) &&
+		(finalizedEpoch == pr.finalizedEpoch || pr.finalizedEpoch == beacon.GENESIS_EPOCH)
 }
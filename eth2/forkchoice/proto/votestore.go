@@ -16,16 +16,22 @@ type ProtoVoteStore struct {
 	spec    *beacon.Spec
 	votes   []VoteTracker
 	changed bool
+	// Validators that were slashed for equivocating, their votes are removed and not counted anymore.
+	equivocating map[ValidatorIndex]struct{}
 }
 
 var _ VoteStore = (*ProtoVoteStore)(nil)
 
 func NewProtoVoteStore(spec *beacon.Spec) VoteStore {
-	return &ProtoVoteStore{spec: spec, changed: true}
+	return &ProtoVoteStore{spec: spec, changed: true, equivocating: make(map[ValidatorIndex]struct{})}
 }
 
 // Process an attestation. (Note that the head slot may be for a gap slot after the block root)
 func (st *ProtoVoteStore) ProcessAttestation(index ValidatorIndex, blockRoot Root, headSlot Slot) (ok bool) {
+	// votes of equivocating validators are ignored, but the attestation is still valid.
+	if _, ok := st.equivocating[index]; ok {
+		return true
+	}
 	if index >= ValidatorIndex(len(st.votes)) {
 		if index < ValidatorIndex(cap(st.votes)) {
 			st.votes = st.votes[:index+1]
@@ -46,6 +52,18 @@ func (st *ProtoVoteStore) ProcessAttestation(index ValidatorIndex, blockRoot Roo
 	return true
 }
 
+// OnAttesterSlashing marks the validators as equivocating:
+// their current vote weight is removed with the next deltas, and their later votes are ignored.
+func (st *ProtoVoteStore) OnAttesterSlashing(slashed []ValidatorIndex) {
+	for _, index := range slashed {
+		if _, ok := st.equivocating[index]; ok {
+			continue
+		}
+		st.equivocating[index] = struct{}{}
+		st.changed = true
+	}
+}
+
 func (st *ProtoVoteStore) HasChanges() bool {
 	return st.changed
 }
@@ -63,6 +81,21 @@ func (st *ProtoVoteStore) ComputeDeltas(indices map[NodeRef]NodeIndex, oldBalanc
 			continue
 		}
 
+		if _, ok := st.equivocating[ValidatorIndex(i)]; ok {
+			// Remove the weight of the vote that was applied, if any, and forget the vote.
+			if vote.Current != (NodeRef{}) {
+				oldBal := Gwei(0)
+				if i < len(oldBalances) {
+					oldBal = oldBalances[i]
+				}
+				if currentIndex, ok := indices[vote.Current]; ok {
+					deltas[currentIndex] -= SignedGwei(oldBal)
+				}
+			}
+			*vote = VoteTracker{}
+			continue
+		}
+
 		// Validator sets may have different sizes (but attesters are not different, activation only under finality)
 		oldBal := Gwei(0)
 		if i < len(oldBalances) {
@@ -120,9 +120,9 @@ func ValidateAttestation(ctx context.Context, subnet uint64, att *beacon.Attesta
 
 	// [REJECT] The committee index is within the expected range --
 	// i.e. data.index < get_committee_count_per_slot(state, data.target.epoch).
-	committeeCountPerSlot, err := targetEpc.GetCommitteeCountAtSlot(att.Data.Slot)
+	committeeCountPerSlot, err := targetEpc.CommitteesPerSlot(att.Data.Target.Epoch)
 	if err != nil {
-		return GossipValidatorResult{REJECT, fmt.Errorf("cannot get commitee count for slot %d: %w", att.Data.Slot, err)}
+		return GossipValidatorResult{REJECT, fmt.Errorf("cannot get commitee count for epoch %d: %w", att.Data.Target.Epoch, err)}
 	}
 	if uint64(att.Data.Index) >= committeeCountPerSlot {
 		return GossipValidatorResult{REJECT, fmt.Errorf("committee index %d out of range %d", att.Data.Index, committeeCountPerSlot)}
@@ -38,10 +38,7 @@ func ValidateAttesterSlashing(ctx context.Context, attSl *beacon.AttesterSlashin
 	}
 
 	// [IGNORE] At least one index in the intersection of the attesting indices of each attestation has not yet been seen in any prior attester_slashing
-	slashable := make(beacon.ValidatorSet, 0, len(indices1))
-	indices1.ZigZagJoin(indices2, func(i beacon.ValidatorIndex) {
-		slashable = append(slashable, i)
-	}, nil)
+	slashable := indices1.Intersect(indices2)
 
 	if attSlVal.AttesterSlashableAllSeen(slashable) {
 		return GossipValidatorResult{IGNORE, errors.New("no unseen slashable attester indices")}
@@ -9,6 +9,10 @@ import (
 type ProposerSlashingValBackend interface {
 	Spec
 	HeadInfo
+	// Check if a proposer slashing for the given proposer index has been seen before, return true if so.
+	// May not be an index within valid range.
+	// It is up to the topic subscriber to mark the proposer as seen, only after the slashing passed validation.
+	// It is recommended to regard any proposer which was finalized as slashed, as seen.
 	SeenProposerSlashing(proposer beacon.ValidatorIndex) bool
 }
 
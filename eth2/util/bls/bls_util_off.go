@@ -2,6 +2,8 @@
 
 package bls
 
+import "errors"
+
 const BLS_ACTIVE = false
 
 func Verify(pubkey *CachedPubkey, message [32]byte, signature BLSSignature) bool {
@@ -15,3 +17,18 @@ func FastAggregateVerify(pubkeys []*CachedPubkey, message [32]byte, signature BL
 	// Temporary: just allow it.
 	return true
 }
+
+func BatchFastAggregateVerify(pubkeys [][]*CachedPubkey, messages [][32]byte, signatures []BLSSignature) bool {
+	// TODO BLS verify batch
+	// Temporary: just allow it.
+	return true
+}
+
+func AggregateSignatures(signatures []BLSSignature) (BLSSignature, error) {
+	if len(signatures) == 0 {
+		return BLSSignature{}, errors.New("no signatures to aggregate")
+	}
+	// TODO BLS aggregate
+	// Temporary: signatures are not checked, any signature will do.
+	return signatures[0], nil
+}
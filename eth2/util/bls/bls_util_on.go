@@ -3,6 +3,8 @@
 package bls
 
 import (
+	"crypto/rand"
+	"errors"
 	hbls "github.com/herumi/bls-eth-go-binary/bls"
 )
 
@@ -30,6 +32,25 @@ func Verify(pubkey *CachedPubkey, message [32]byte, signature BLSSignature) bool
 	return parsedSig.VerifyHash(parsedPubkey, message[:])
 }
 
+// AggregateSignatures combines the signatures into a single signature,
+// e.g. to combine attestations of different validators that signed the same data.
+func AggregateSignatures(signatures []BLSSignature) (BLSSignature, error) {
+	if len(signatures) == 0 {
+		return BLSSignature{}, errors.New("no signatures to aggregate")
+	}
+	sigs := make([]hbls.Sign, len(signatures), len(signatures))
+	for i := range signatures {
+		if err := sigs[i].Deserialize(signatures[i][:]); err != nil {
+			return BLSSignature{}, err
+		}
+	}
+	var agg hbls.Sign
+	agg.Aggregate(sigs)
+	var out BLSSignature
+	copy(out[:], agg.Serialize())
+	return out, nil
+}
+
 func parsePubkeys(pubkeys []*CachedPubkey) []hbls.PublicKey {
 	pubs := make([]hbls.PublicKey, len(pubkeys), len(pubkeys))
 	for i, p := range pubkeys {
@@ -55,3 +76,50 @@ func FastAggregateVerify(pubkeys []*CachedPubkey, message [32]byte, signature BL
 
 	return parsedSig.FastAggregateVerify(pubs, message[:])
 }
+
+// BatchFastAggregateVerify verifies a batch of fast-aggregate signatures in one go:
+// signature i is checked against the aggregate of pubkeys[i] and messages[i].
+// Each entry is weighted with a random non-zero 64 bit scalar,
+// so that invalid signatures cannot cancel each other out in the aggregate.
+// If the batch fails, it does not tell which entry is invalid:
+// fall back to FastAggregateVerify per entry to find it.
+func BatchFastAggregateVerify(pubkeys [][]*CachedPubkey, messages [][32]byte, signatures []BLSSignature) bool {
+	n := len(pubkeys)
+	if n == 0 || len(messages) != n || len(signatures) != n {
+		return false
+	}
+	aggPubs := make([]hbls.PublicKey, n, n)
+	sigs := make([]hbls.G2, n, n)
+	scalars := make([]hbls.Fr, n, n)
+	msgs := make([]byte, 0, 32*n)
+	for i := 0; i < n; i++ {
+		pubs := parsePubkeys(pubkeys[i])
+		if len(pubs) == 0 { // also if parsePubkeys errors and returns nil
+			return false
+		}
+		var aggPub hbls.PublicKey
+		aggPub = pubs[0]
+		for j := 1; j < len(pubs); j++ {
+			aggPub.Add(&pubs[j])
+		}
+		var parsedSig hbls.Sign
+		if err := parsedSig.Deserialize(signatures[i][:]); err != nil {
+			return false
+		}
+		var r [8]byte
+		if _, err := rand.Read(r[:]); err != nil {
+			return false
+		}
+		r[0] |= 1 // never zero
+		if err := scalars[i].SetLittleEndian(r[:]); err != nil {
+			return false
+		}
+		hbls.G1Mul(hbls.CastFromPublicKey(&aggPubs[i]), hbls.CastFromPublicKey(&aggPub), &scalars[i])
+		sigs[i] = *hbls.CastFromSign(&parsedSig)
+		msgs = append(msgs, messages[i][:]...)
+	}
+	var aggSig hbls.G2
+	hbls.G2MulVec(&aggSig, sigs, scalars)
+	// Messages may repeat (e.g. attestations with the same data), the random scalars make that safe.
+	return hbls.CastToSign(&aggSig).AggregateVerifyNoCheck(aggPubs, msgs)
+}
@@ -0,0 +1,42 @@
+// +build !bls_off
+
+package bls
+
+import (
+	hbls "github.com/herumi/bls-eth-go-binary/bls"
+	"testing"
+)
+
+func TestBatchFastAggregateVerify(t *testing.T) {
+	var pubkeys [][]*CachedPubkey
+	var messages [][32]byte
+	var signatures []BLSSignature
+	for i := 0; i < 4; i++ {
+		msg := [32]byte{byte(i)}
+		var sigs []hbls.Sign
+		var pubs []*CachedPubkey
+		for j := 0; j < 3; j++ {
+			var sec hbls.SecretKey
+			sec.SetByCSPRNG()
+			p := new(CachedPubkey)
+			copy(p.Compressed[:], sec.GetPublicKey().Serialize())
+			pubs = append(pubs, p)
+			sigs = append(sigs, *sec.SignHash(msg[:]))
+		}
+		var agg hbls.Sign
+		agg.Aggregate(sigs)
+		var sig BLSSignature
+		copy(sig[:], agg.Serialize())
+		pubkeys = append(pubkeys, pubs)
+		messages = append(messages, msg)
+		signatures = append(signatures, sig)
+	}
+	if !BatchFastAggregateVerify(pubkeys, messages, signatures) {
+		t.Fatal("expected valid batch")
+	}
+	// swapped signatures are individually invalid, but would sum up to the same aggregate.
+	signatures[0], signatures[1] = signatures[1], signatures[0]
+	if BatchFastAggregateVerify(pubkeys, messages, signatures) {
+		t.Fatal("expected invalid batch")
+	}
+}
@@ -0,0 +1,111 @@
+package bls
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Order of the BLS12-381 curve group, secret keys are smaller than this.
+var curveOrder, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+func hkdfExtract(salt []byte, ikm []byte) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	return mac.Sum(nil)
+}
+
+func hkdfExpand(prk []byte, info []byte, length int) []byte {
+	out := make([]byte, 0, length+sha256.Size)
+	var prev []byte
+	for i := byte(1); len(out) < length; i++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(prev)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		prev = mac.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:length]
+}
+
+func hkdfModR(ikm []byte) (out [32]byte) {
+	salt := []byte("BLS-SIG-KEYGEN-SALT-")
+	sk := new(big.Int)
+	for sk.Sign() == 0 {
+		h := sha256.Sum256(salt)
+		salt = h[:]
+		prk := hkdfExtract(salt, append(append([]byte(nil), ikm...), 0))
+		okm := hkdfExpand(prk, []byte{0, 48}, 48)
+		sk.SetBytes(okm)
+		sk.Mod(sk, curveOrder)
+	}
+	b := sk.Bytes()
+	copy(out[32-len(b):], b)
+	return
+}
+
+func ikmToLamportSK(ikm []byte, salt []byte) []byte {
+	return hkdfExpand(hkdfExtract(salt, ikm), nil, 255*32)
+}
+
+func parentSKToLamportPK(parentSK [32]byte, index uint32) [32]byte {
+	var salt [4]byte
+	binary.BigEndian.PutUint32(salt[:], index)
+	lamport0 := ikmToLamportSK(parentSK[:], salt[:])
+	var notIKM [32]byte
+	for i := range parentSK {
+		notIKM[i] = ^parentSK[i]
+	}
+	lamport1 := ikmToLamportSK(notIKM[:], salt[:])
+	h := sha256.New()
+	for _, lamport := range [][]byte{lamport0, lamport1} {
+		for i := 0; i < 255; i++ {
+			chunk := sha256.Sum256(lamport[i*32 : (i+1)*32])
+			h.Write(chunk[:])
+		}
+	}
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// DeriveMasterSK derives the master secret key from a seed of at least 32 bytes, following EIP-2333.
+// Secret keys are encoded as 32 bytes, big-endian.
+func DeriveMasterSK(seed []byte) ([32]byte, error) {
+	if len(seed) < 32 {
+		return [32]byte{}, errors.New("seed must be at least 32 bytes")
+	}
+	return hkdfModR(seed), nil
+}
+
+// DeriveChildSK derives the child secret key at the given index, following EIP-2333.
+func DeriveChildSK(parentSK [32]byte, index uint32) [32]byte {
+	lamportPK := parentSKToLamportPK(parentSK, index)
+	return hkdfModR(lamportPK[:])
+}
+
+// DeriveSKFromPath derives the secret key for an EIP-2334 path, e.g. "m/12381/3600/0/0/0".
+func DeriveSKFromPath(seed []byte, path string) ([32]byte, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return [32]byte{}, fmt.Errorf("key path %q must start with \"m\"", path)
+	}
+	sk, err := DeriveMasterSK(seed)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	for _, p := range parts[1:] {
+		index, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return [32]byte{}, fmt.Errorf("invalid index %q in key path %q: %v", p, path, err)
+		}
+		sk = DeriveChildSK(sk, uint32(index))
+	}
+	return sk, nil
+}
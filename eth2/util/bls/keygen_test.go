@@ -0,0 +1,52 @@
+package bls
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestDeriveSK(t *testing.T) {
+	// Test cases from EIP-2333
+	for i, c := range []struct {
+		seed   string
+		master string
+		index  uint32
+		child  string
+	}{
+		{
+			seed:   "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+			master: "6083874454709270928345386274498605044986640685124978867557563392430687146096",
+			index:  0,
+			child:  "20397789859736650942317412262472558107875392172444076792671091975210932703118",
+		},
+		{
+			seed:   "3141592653589793238462643383279502884197169399375105820974944592",
+			master: "29757020647961307431480504535336562678282505419141012933316116377660817309383",
+			index:  3141592653,
+			child:  "25457201688850691947727629385191704516744796114925897962676248250929345014287",
+		},
+	} {
+		seed, err := hex.DecodeString(c.seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		master, err := DeriveMasterSK(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := new(big.Int).SetBytes(master[:]).String(); v != c.master {
+			t.Fatalf("case %d: unexpected master key: %s", i, v)
+		}
+		child := DeriveChildSK(master, c.index)
+		if v := new(big.Int).SetBytes(child[:]).String(); v != c.child {
+			t.Fatalf("case %d: unexpected child key: %s", i, v)
+		}
+	}
+	if _, err := DeriveMasterSK(make([]byte, 31)); err == nil {
+		t.Fatal("expected error for short seed")
+	}
+	if _, err := DeriveSKFromPath(make([]byte, 32), "x/0"); err == nil {
+		t.Fatal("expected error for path without master key")
+	}
+}
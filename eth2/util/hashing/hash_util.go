@@ -17,11 +17,13 @@ type NewHashFn func() HashFn
 // re-uses the sha256 working variables for each new call of a allocated hash-function.
 func Sha256Repeat() HashFn {
 	h := sha256.New()
-	hashFn := func(in []byte) (out [32]byte) {
+	// re-use the output buffer too, to not allocate a new slice for every hash.
+	var sum [32]byte
+	hashFn := func(in []byte) [32]byte {
 		h.Reset()
 		h.Write(in)
-		copy(out[:], h.Sum(nil))
-		return
+		h.Sum(sum[:0])
+		return sum
 	}
 	return hashFn
 }
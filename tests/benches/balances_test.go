@@ -0,0 +1,48 @@
+package benches
+
+import (
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"testing"
+)
+
+func BenchmarkBalancesSum(b *testing.B) {
+	state, _ := CreateTestState(500000, MAX_EFFECTIVE_BALANCE)
+	bals, err := state.Balances()
+	if err != nil {
+		b.Fatal(err)
+	}
+	count, err := bals.Length()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("get", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sum := beacon.Gwei(0)
+			for j := uint64(0); j < count; j++ {
+				bal, err := bals.GetBalance(beacon.ValidatorIndex(j))
+				if err != nil {
+					b.Fatal(err)
+				}
+				sum += bal
+			}
+		}
+	})
+	b.Run("iter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			sum := beacon.Gwei(0)
+			iter := bals.IterBalances()
+			for {
+				_, bal, ok, err := iter.Next()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if !ok {
+					break
+				}
+				sum += bal
+			}
+		}
+	})
+}
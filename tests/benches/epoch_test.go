@@ -0,0 +1,80 @@
+package benches
+
+import (
+	"context"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func BenchmarkPrepareEpochProcess(b *testing.B) {
+	state, epc := CreateTestState(500000, MAX_EFFECTIVE_BALANCE)
+	b.Run("serial", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := spec.PrepareEpochProcess(context.Background(), epc, state); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := spec.PrepareEpochProcessParallel(context.Background(), epc, state); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestPrepareEpochProcessParallel(t *testing.T) {
+	// force multiple shards, even on single-core machines
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	state, epc := CreateTestState(stateValidatorFill, MAX_EFFECTIVE_BALANCE)
+	serial, err := spec.PrepareEpochProcess(context.Background(), epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := spec.PrepareEpochProcessParallel(context.Background(), epc, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(serial, parallel) {
+		t.Fatal("parallel epoch process differs from serial epoch process")
+	}
+}
+
+func BenchmarkFilterParticipants(b *testing.B) {
+	// A full epoch of mainnet attestations: 64 committees of 128 validators per slot, half participating.
+	committeeSize := uint64(128)
+	attCount := 64 * int(spec.SLOTS_PER_EPOCH)
+	committee := make([]beacon.ValidatorIndex, committeeSize)
+	for i := range committee {
+		committee[i] = beacon.ValidatorIndex(i)
+	}
+	bits := make(beacon.CommitteeBits, (committeeSize>>3)+1)
+	bits[committeeSize>>3] = 1 // length delimiter bit
+	for i := uint64(0); i < committeeSize; i += 2 {
+		bits.SetBit(i, true)
+	}
+	b.Run("copy", func(b *testing.B) {
+		b.ReportAllocs()
+		participants := make([]beacon.ValidatorIndex, 0, committeeSize)
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < attCount; j++ {
+				participants = append(participants[:0], committee...)
+				participants = bits.FilterParticipants(participants)
+			}
+		}
+	})
+	b.Run("into", func(b *testing.B) {
+		b.ReportAllocs()
+		participants := make([]beacon.ValidatorIndex, 0, committeeSize)
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < attCount; j++ {
+				participants = bits.FilterParticipantsInto(participants, committee)
+			}
+		}
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"github.com/minio/sha256-simd"
+	. "github.com/protolambda/zrnt/eth2/beacon"
 	"github.com/protolambda/zrnt/eth2/configs"
 	"github.com/protolambda/ztyp/codec"
 	"github.com/protolambda/ztyp/tree"
@@ -234,3 +235,46 @@ func TestRawHashTreeRoot(t *testing.T) {
 		t.Fatal("unexpected zero root")
 	}
 }
+
+// Re-hash the registry after changing the effective balance of a handful of validators,
+// like an epoch transition typically does.
+func BenchmarkRegistryRehash(b *testing.B) {
+	stateTree, _ := CreateTestState(stateValidatorFill, MAX_EFFECTIVE_BALANCE)
+	hFn := tree.GetHashFn()
+	const changed = 8
+	b.Run("tree", func(b *testing.B) {
+		vals, err := stateTree.Validators()
+		if err != nil {
+			b.Fatal(err)
+		}
+		vals.HashTreeRoot(hFn)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < changed; j++ {
+				val, err := vals.Validator(ValidatorIndex(j * (stateValidatorFill / changed)))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if err := val.SetEffectiveBalance(Gwei(i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			vals.HashTreeRoot(hFn)
+		}
+	})
+	b.Run("raw", func(b *testing.B) {
+		state, err := stateTree.Raw(spec)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < changed; j++ {
+				state.Validators[j*(stateValidatorFill/changed)].EffectiveBalance = Gwei(i)
+			}
+			state.Validators.HashTreeRoot(spec, hFn)
+		}
+	})
+}
@@ -21,7 +21,8 @@ func (c *DepositTestCase) Run() error {
 	if err != nil {
 		return err
 	}
-	return c.Spec.ProcessDeposit(epc, c.Pre, &c.Deposit, false)
+	_, _, err = c.Spec.ProcessDeposit(epc, c.Pre, &c.Deposit, false)
+	return err
 }
 
 func TestDeposit(t *testing.T) {
@@ -0,0 +1,125 @@
+package test_util
+
+import (
+	"context"
+	"fmt"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/ztyp/tree"
+	"testing"
+)
+
+// ScenarioStep is either a block to apply, or, if the block is nil, a slot to process empty slots up to.
+type ScenarioStep struct {
+	Slot  beacon.Slot
+	Block *beacon.SignedBeaconBlock
+}
+
+// EpochRoot is the state root at the start of an epoch, right after the epoch transition.
+type EpochRoot struct {
+	Epoch beacon.Epoch
+	Root  beacon.Root
+	// Index of the step that processed the epoch transition.
+	Step int
+}
+
+// ScenarioError is the failure of a step of a scenario.
+type ScenarioError struct {
+	Step int
+	Err  error
+}
+
+func (e *ScenarioError) Error() string {
+	return fmt.Sprintf("scenario step %d: %v", e.Step, e.Err)
+}
+
+func (e *ScenarioError) Unwrap() error {
+	return e.Err
+}
+
+// EpochScenario runs a sequence of slot and block steps on top of a state,
+// and captures the state root after every epoch transition.
+type EpochScenario struct {
+	Spec  *beacon.Spec
+	Pre   *beacon.BeaconStateView
+	Steps []ScenarioStep
+	// Verify the block signatures and state roots.
+	ValidateBlocks bool
+}
+
+func NewEpochScenario(spec *beacon.Spec, pre *beacon.BeaconStateView) *EpochScenario {
+	return &EpochScenario{Spec: spec, Pre: pre}
+}
+
+// ProcessSlots adds a step to process empty slots up to the given slot.
+func (s *EpochScenario) ProcessSlots(slot beacon.Slot) *EpochScenario {
+	s.Steps = append(s.Steps, ScenarioStep{Slot: slot})
+	return s
+}
+
+// ApplyBlock adds a step to process empty slots up to the slot of the block, and then the block.
+func (s *EpochScenario) ApplyBlock(block *beacon.SignedBeaconBlock) *EpochScenario {
+	s.Steps = append(s.Steps, ScenarioStep{Slot: block.Message.Slot, Block: block})
+	return s
+}
+
+// Run the scenario on a copy of the pre-state. Returns the post-state and the state root after every epoch transition.
+// A failing step is returned as ScenarioError.
+func (s *EpochScenario) Run(ctx context.Context) (*beacon.BeaconStateView, []EpochRoot, error) {
+	state, err := beacon.AsBeaconStateView(s.Pre.Copy())
+	if err != nil {
+		return nil, nil, err
+	}
+	epc, err := s.Spec.NewEpochsContext(state)
+	if err != nil {
+		return nil, nil, err
+	}
+	hFn := tree.GetHashFn()
+	var roots []EpochRoot
+	for i, step := range s.Steps {
+		slot, err := state.Slot()
+		if err != nil {
+			return nil, nil, &ScenarioError{Step: i, Err: err}
+		}
+		// Process slots up to every epoch start on the way, to capture the roots.
+		for slot < step.Slot {
+			next, err := s.Spec.EpochStartSlot(s.Spec.SlotToEpoch(slot) + 1)
+			if err != nil {
+				return nil, nil, &ScenarioError{Step: i, Err: err}
+			}
+			if next > step.Slot {
+				next = step.Slot
+			}
+			if err := s.Spec.ProcessSlots(ctx, epc, state, next); err != nil {
+				return nil, nil, &ScenarioError{Step: i, Err: err}
+			}
+			if next%s.Spec.SLOTS_PER_EPOCH == 0 {
+				roots = append(roots, EpochRoot{Epoch: s.Spec.SlotToEpoch(next), Root: state.HashTreeRoot(hFn), Step: i})
+			}
+			slot = next
+		}
+		if step.Block != nil {
+			if err := s.Spec.PostSlotTransition(ctx, epc, state, step.Block, s.ValidateBlocks); err != nil {
+				return nil, nil, &ScenarioError{Step: i, Err: err}
+			}
+		}
+	}
+	return state, roots, nil
+}
+
+// Check runs the scenario, and reports the first epoch where the state root diverges from the expected roots.
+func (s *EpochScenario) Check(t *testing.T, expected []EpochRoot) {
+	_, roots, err := s.Run(context.Background())
+	Check(t, err)
+	for i, r := range roots {
+		if i >= len(expected) {
+			t.Fatalf("scenario produced more epochs than expected: %d > %d", len(roots), len(expected))
+		}
+		if e := expected[i]; r.Epoch != e.Epoch || r.Root != e.Root {
+			t.Fatalf("scenario diverged at step %d, epoch %d: got root %s, expected epoch %d root %s",
+				r.Step, r.Epoch, r.Root, e.Epoch, e.Root)
+		}
+	}
+	if len(roots) < len(expected) {
+		t.Fatalf("scenario produced fewer epochs than expected: %d < %d", len(roots), len(expected))
+	}
+}
@@ -0,0 +1,44 @@
+package test_util
+
+import (
+	"context"
+	"errors"
+	"github.com/protolambda/zrnt/eth2/beacon"
+	"github.com/protolambda/zrnt/eth2/configs"
+	"testing"
+)
+
+func TestEpochScenario(t *testing.T) {
+	spec := configs.Minimal
+	validators := make([]beacon.KickstartValidatorData, 64)
+	for i := range validators {
+		validators[i].Pubkey[0] = byte(i)
+		validators[i].WithdrawalCredentials[0] = byte(i)
+		validators[i].Balance = spec.MAX_EFFECTIVE_BALANCE
+	}
+	pre, _, err := spec.KickStartState(beacon.Root{123}, 1564000000, validators)
+	Check(t, err)
+	scenario := NewEpochScenario(spec, pre).
+		ProcessSlots(3).
+		ProcessSlots(spec.SLOTS_PER_EPOCH*3 + 2)
+	_, roots, err := scenario.Run(context.Background())
+	Check(t, err)
+	if len(roots) != 3 {
+		t.Fatalf("expected 3 epoch roots, got %d", len(roots))
+	}
+	for i, r := range roots {
+		if r.Epoch != beacon.Epoch(i+1) || r.Step != 1 {
+			t.Fatalf("unexpected epoch root %d: epoch %d, step %d", i, r.Epoch, r.Step)
+		}
+	}
+	// Runs on a copy of the pre-state, the scenario can be repeated.
+	scenario.Check(t, roots)
+
+	// A block that does not match the state fails at its step.
+	scenario.ApplyBlock(&beacon.SignedBeaconBlock{Message: beacon.BeaconBlock{Slot: spec.SLOTS_PER_EPOCH * 4}})
+	_, _, err = scenario.Run(context.Background())
+	var sErr *ScenarioError
+	if !errors.As(err, &sErr) || sErr.Step != 2 {
+		t.Fatalf("expected scenario error at step 2, got: %v", err)
+	}
+}
@@ -103,10 +103,11 @@ func RunTransitionTest(t *testing.T, runnerName string, handlerName string, mkr
 		}
 		c.Check(t)
 	})
-	t.Run("minimal", func(t *testing.T) {
-		RunHandler(t, runnerName+"/"+handlerName, caseRunner, configs.Minimal)
-	})
-	t.Run("mainnet", func(t *testing.T) {
-		RunHandler(t, runnerName+"/"+handlerName, caseRunner, configs.Mainnet)
-	})
+	for _, preset := range []string{"minimal", "mainnet"} {
+		spec, err := configs.PresetByName(preset)
+		Check(t, err)
+		t.Run(preset, func(t *testing.T) {
+			RunHandler(t, runnerName+"/"+handlerName, caseRunner, spec)
+		})
+	}
 }